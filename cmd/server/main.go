@@ -12,6 +12,7 @@ import (
 	"github.com/OpenSysKit/backend/internal/ipc"
 	rpcserver "github.com/OpenSysKit/backend/internal/rpc"
 	"github.com/OpenSysKit/backend/internal/security"
+	"github.com/OpenSysKit/backend/internal/service"
 )
 
 const devicePath = `\\.\OpenSysKit`
@@ -51,6 +52,10 @@ func main() {
 	log.Printf("OpenSysKit 后端服务正在启动... (版本: %s, 构建时间: %s)", version, buildTime)
 
 	security.SetTrustedFrontendHash(frontendSHA256)
+	if images := protectedImagesFromEnv(); len(images) > 0 {
+		service.AddProtectedImages(images)
+		log.Printf("已加载用户保护映像: %s", strings.Join(images, ","))
+	}
 
 	// 打开内核驱动设备
 	var drv driver.Device
@@ -121,6 +126,11 @@ func main() {
 		log.Fatalf("创建 RPC 服务器失败: %v", err)
 	}
 
+	// 后台资源采样（默认关闭，由前端 ConfigureMetricsSampler 开启），供前端绘制进程 CPU/内存/句柄曲线
+	if drv != nil {
+		service.StartMetricsSampler(drv)
+	}
+
 	go func() {
 		if err := srv.Serve(ln); err != nil {
 			log.Printf("RPC 服务器错误: %v", err)
@@ -142,6 +152,8 @@ func main() {
 			log.Printf("警告: 启动前端失败 (%v)，继续以无头模式运行", startErr)
 		} else {
 			log.Printf("前端守护已激活，前端 PID = %d", guard.pidOf())
+			// 目标保护策略拒绝结束/冻结/隐藏前端进程（force 可绕过）
+			service.SetFrontendProcessId(uint32(guard.pidOf()))
 			// 自保护暂时禁用
 			_ = guard.pidOf()
 		}
@@ -176,6 +188,27 @@ func main() {
 
 	log.Println("正在关闭服务...")
 
+	service.StopMetricsSampler()
+	service.StopHandleLeakDetector()
+	service.StopDeleteRetryQueue()
+	service.CancelAllJobs()
+
+	// 在释放驱动句柄前按策略恢复本进程造成的内核状态修改（解冻/取消隐藏等），
+	// 避免后端退出后进程永久处于隐藏或冻结状态。
+	if drv != nil {
+		if enabled, safeOnly := shutdownRevertPolicy(); enabled {
+			res := service.RevertModifiedState(drv, safeOnly)
+			log.Printf("退出前恢复内核状态: safe_only=%t reverted=%d failed=%d skipped=%d", safeOnly, res.Reverted, res.Failed, res.Skipped)
+			for _, r := range res.Results {
+				if !r.Success {
+					log.Printf("警告: 恢复失败 kind=%s pid=%d: %s", r.Kind, r.ProcessId, r.Error)
+				}
+			}
+		} else {
+			log.Println("退出前状态恢复已禁用 (OPENSYSKIT_SHUTDOWN_REVERT)")
+		}
+	}
+
 	// 显式关闭设备句柄，确保在 TerminateProcess 前释放
 	if drv != nil {
 		if c, ok := drv.(*driver.Client); ok {
@@ -243,3 +276,35 @@ func autoUninstallEnabled() bool {
 		return true
 	}
 }
+
+// shutdownRevertPolicy 读取 OPENSYSKIT_SHUTDOWN_REVERT：
+//   - 空值/safe => 仅解冻、取消隐藏
+//   - all       => 额外取消保护
+//   - 0/false/off/no/none => 不恢复
+func shutdownRevertPolicy() (enabled bool, safeOnly bool) {
+	raw := strings.TrimSpace(strings.ToLower(os.Getenv("OPENSYSKIT_SHUTDOWN_REVERT")))
+	switch raw {
+	case "0", "false", "off", "no", "none":
+		return false, false
+	case "all":
+		return true, false
+	default:
+		return true, true
+	}
+}
+
+// protectedImagesFromEnv 读取 OPENSYSKIT_PROTECTED_IMAGES（逗号分隔的映像名），
+// 作为目标保护策略的用户列表初始值。
+func protectedImagesFromEnv() []string {
+	raw := strings.TrimSpace(os.Getenv("OPENSYSKIT_PROTECTED_IMAGES"))
+	if raw == "" {
+		return nil
+	}
+	out := make([]string, 0, 4)
+	for _, part := range strings.Split(raw, ",") {
+		if part = strings.TrimSpace(part); part != "" {
+			out = append(out, part)
+		}
+	}
+	return out
+}
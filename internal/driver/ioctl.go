@@ -90,7 +90,10 @@ type ProcessKillResult struct {
 	Reserved        uint32
 }
 
-// ProcessInfo 对应内核中 PROCESS_INFO 结构体。
+// ProcessInfo 对应内核中 PROCESS_INFO 结构体，每条 552 字节。
+// CreateTime 为 EPROCESS 的创建时间（FILETIME），需要 PROCESS_INFO 末尾带 CreateTime 的驱动版本；
+// 旧版驱动的记录不含该字段，长度为 ProcessInfoLegacySize。两种版本都必须在
+// PROCESS_LIST_HEADER.TotalSize 中填写 头部 + Count×记录长度，后端据此确定记录长度。
 type ProcessInfo struct {
 	ProcessId       uint32
 	ParentProcessId uint32
@@ -98,8 +101,12 @@ type ProcessInfo struct {
 	Padding0        uint32
 	WorkingSetSize  uint64
 	ImageName       [260]uint16
+	CreateTime      uint64
 }
 
+// ProcessInfoLegacySize 不含 CreateTime 的旧版 PROCESS_INFO 长度。
+const ProcessInfoLegacySize = 544
+
 // ProcessListHeader 对应内核中 PROCESS_LIST_HEADER 结构体。
 type ProcessListHeader struct {
 	Count     uint32
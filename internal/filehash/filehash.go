@@ -0,0 +1,107 @@
+// Package filehash 计算文件摘要（MD5/SHA1/SHA256）与 PE 导入表哈希（imphash），
+// 供管道鉴权与 HashFiles 等接口共用。
+package filehash
+
+import (
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"strings"
+)
+
+// 支持的算法名。
+const (
+	MD5     = "md5"
+	SHA1    = "sha1"
+	SHA256  = "sha256"
+	Imphash = "imphash"
+)
+
+// ParseAlgorithms 校验并去重算法名（不区分大小写），为空时默认 sha256。
+func ParseAlgorithms(names []string) ([]string, error) {
+	if len(names) == 0 {
+		return []string{SHA256}, nil
+	}
+	seen := make(map[string]struct{}, len(names))
+	out := make([]string, 0, len(names))
+	for _, n := range names {
+		alg := strings.ToLower(strings.TrimSpace(n))
+		switch alg {
+		case MD5, SHA1, SHA256, Imphash:
+		default:
+			return nil, fmt.Errorf("不支持的哈希算法: %s", n)
+		}
+		if _, ok := seen[alg]; ok {
+			continue
+		}
+		seen[alg] = struct{}{}
+		out = append(out, alg)
+	}
+	return out, nil
+}
+
+// Sum 读取一遍文件同时计算 algs 中的摘要，返回算法名到小写十六进制的映射与文件大小。
+// imphash 需要解析 PE 导入表，非 PE 文件时该项省略而不报错。
+func Sum(path string, algs []string) (map[string]string, int64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer f.Close()
+
+	hashers := make(map[string]hash.Hash, len(algs))
+	writers := make([]io.Writer, 0, len(algs))
+	wantImphash := false
+	for _, alg := range algs {
+		var h hash.Hash
+		switch alg {
+		case MD5:
+			h = md5.New()
+		case SHA1:
+			h = sha1.New()
+		case SHA256:
+			h = sha256.New()
+		case Imphash:
+			wantImphash = true
+			continue
+		default:
+			return nil, 0, fmt.Errorf("不支持的哈希算法: %s", alg)
+		}
+		hashers[alg] = h
+		writers = append(writers, h)
+	}
+
+	var size int64
+	if len(writers) > 0 {
+		if size, err = io.Copy(io.MultiWriter(writers...), f); err != nil {
+			return nil, size, err
+		}
+	} else if info, statErr := f.Stat(); statErr == nil {
+		size = info.Size()
+	}
+
+	sums := make(map[string]string, len(algs))
+	for alg, h := range hashers {
+		sums[alg] = hex.EncodeToString(h.Sum(nil))
+	}
+	if wantImphash {
+		if imp, err := ImphashOf(f); err == nil && imp != "" {
+			sums[Imphash] = imp
+		}
+	}
+	return sums, size, nil
+}
+
+// FileSHA256 返回文件的 SHA256（小写十六进制）。
+func FileSHA256(path string) (string, error) {
+	sums, _, err := Sum(path, []string{SHA256})
+	if err != nil {
+		return "", err
+	}
+	return sums[SHA256], nil
+}
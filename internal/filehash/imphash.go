@@ -0,0 +1,67 @@
+package filehash
+
+import (
+	"crypto/md5"
+	"debug/pe"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/OpenSysKit/backend/internal/peinfo"
+)
+
+// ImphashOf 按 pefile 的规则计算 imphash：依导入顺序拼接 "库名.函数名"（小写，库名去掉 .dll/.sys/.ocx），
+// 以逗号连接后取 MD5。按序号导入的函数先查 ws2_32/wsock32/oleaut32 的序号表，查不到时记为 ordN。
+// 没有导入表时返回空串。
+func ImphashOf(r io.ReaderAt) (string, error) {
+	f, err := pe.NewFile(r)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	imports, err := peinfo.Imports(f)
+	if err != nil {
+		return "", err
+	}
+	return ImphashFromImports(imports), nil
+}
+
+// ImphashFromImports 由已解析的导入表计算 imphash，没有导入函数时返回空串。
+func ImphashFromImports(imports []peinfo.Import) string {
+	names := ImportNames(imports)
+	if len(names) == 0 {
+		return ""
+	}
+	sum := md5.Sum([]byte(strings.Join(names, ",")))
+	return hex.EncodeToString(sum[:])
+}
+
+// ImportNames 返回 imphash 所需的 "lib.func" 列表，库名小写并去掉 .dll/.sys/.ocx 扩展名；
+// 序号导入按完整库名查 ordinalNames，与 pefile 的 ordlookup 一致。
+func ImportNames(imports []peinfo.Import) []string {
+	out := make([]string, 0, 64)
+	for _, imp := range imports {
+		lib := strings.ToLower(imp.Library)
+		ordNames := ordinalNames[lib]
+		if dot := strings.LastIndex(lib, "."); dot >= 0 {
+			switch lib[dot+1:] {
+			case "dll", "sys", "ocx":
+				lib = lib[:dot]
+			}
+		}
+		for _, fn := range imp.Functions {
+			if fn.ByOrdinal {
+				if name, ok := ordNames[fn.Ordinal]; ok {
+					out = append(out, lib+"."+strings.ToLower(name))
+				} else {
+					out = append(out, fmt.Sprintf("%s.ord%d", lib, fn.Ordinal))
+				}
+				continue
+			}
+			out = append(out, lib+"."+strings.ToLower(fn.Name))
+		}
+	}
+	return out
+}
@@ -0,0 +1,71 @@
+package filehash
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/OpenSysKit/backend/internal/peinfo"
+)
+
+func byName(names ...string) []peinfo.ImportedFunction {
+	out := make([]peinfo.ImportedFunction, 0, len(names))
+	for _, n := range names {
+		out = append(out, peinfo.ImportedFunction{Name: n})
+	}
+	return out
+}
+
+func byOrdinal(ords ...uint16) []peinfo.ImportedFunction {
+	out := make([]peinfo.ImportedFunction, 0, len(ords))
+	for _, o := range ords {
+		out = append(out, peinfo.ImportedFunction{Ordinal: o, ByOrdinal: true})
+	}
+	return out
+}
+
+// testImports 混合按名称与按序号导入，覆盖三张序号表、表外序号与不查表的库。
+var testImports = []peinfo.Import{
+	{Library: "KERNEL32.dll", Functions: byName("ExitProcess", "GetProcAddress")},
+	{Library: "WS2_32.dll", Functions: byOrdinal(115, 23, 1000)},
+	{Library: "WSOCK32.DLL", Functions: byOrdinal(3)},
+	{Library: "OLEAUT32.dll", Functions: byOrdinal(6, 8)},
+	{Library: "USER32.dll", Functions: byOrdinal(5)},
+	{Library: "msvcrt.dll", Functions: byName("printf")},
+}
+
+func TestImportNames(t *testing.T) {
+	want := []string{
+		"kernel32.exitprocess",
+		"kernel32.getprocaddress",
+		"ws2_32.wsastartup",
+		"ws2_32.socket",
+		"ws2_32.ord1000",
+		"wsock32.closesocket",
+		"oleaut32.sysfreestring",
+		"oleaut32.variantinit",
+		"user32.ord5",
+		"msvcrt.printf",
+	}
+	if got := ImportNames(testImports); !reflect.DeepEqual(got, want) {
+		t.Fatalf("ImportNames =\n%q\nwant\n%q", got, want)
+	}
+}
+
+func TestImportNamesLibraryWithoutExtension(t *testing.T) {
+	// pefile 按完整库名查表，没有扩展名的库名不还原序号
+	got := ImportNames([]peinfo.Import{{Library: "ws2_32", Functions: byOrdinal(23)}})
+	if want := []string{"ws2_32.ord23"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("ImportNames = %q, want %q", got, want)
+	}
+}
+
+func TestImphashFromImports(t *testing.T) {
+	// 期望值为 pefile get_imphash() 对同一导入表的结果：上面 TestImportNames 列表以逗号连接后的 MD5
+	const want = "8353a5577a1cce32107962810882662d"
+	if got := ImphashFromImports(testImports); got != want {
+		t.Fatalf("ImphashFromImports = %s, want %s", got, want)
+	}
+	if got := ImphashFromImports(nil); got != "" {
+		t.Fatalf("ImphashFromImports(nil) = %q, want empty", got)
+	}
+}
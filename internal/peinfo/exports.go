@@ -0,0 +1,83 @@
+package peinfo
+
+import (
+	"debug/pe"
+	"encoding/binary"
+)
+
+// maxExportFunctions 导出函数数量上限。
+const maxExportFunctions = 65536
+
+// ExportedFunction 导出函数；Name 为空表示仅按序号导出，Forwarder 非空表示转发到其他模块。
+type ExportedFunction struct {
+	Name      string
+	Ordinal   uint32
+	RVA       uint32
+	Forwarder string
+}
+
+// Exports 导出目录内容。
+type Exports struct {
+	DllName       string
+	TimeDateStamp uint32
+	Functions     []ExportedFunction
+}
+
+// ReadExports 解析导出目录，没有导出表时返回 nil。
+func ReadExports(f *pe.File) (*Exports, error) {
+	dir := dataDirectory(f, pe.IMAGE_DIRECTORY_ENTRY_EXPORT)
+	if dir.VirtualAddress == 0 {
+		return nil, nil
+	}
+	img := image{f: f}
+	hdr, err := img.read(dir.VirtualAddress, 40)
+	if err != nil {
+		return nil, err
+	}
+	out := &Exports{
+		TimeDateStamp: binary.LittleEndian.Uint32(hdr[4:8]),
+		DllName:       img.cstring(binary.LittleEndian.Uint32(hdr[12:16])),
+	}
+	base := binary.LittleEndian.Uint32(hdr[16:20])
+	numFuncs := min(binary.LittleEndian.Uint32(hdr[20:24]), maxExportFunctions)
+	numNames := min(binary.LittleEndian.Uint32(hdr[24:28]), maxExportFunctions)
+	addrFuncs := binary.LittleEndian.Uint32(hdr[28:32])
+	addrNames := binary.LittleEndian.Uint32(hdr[32:36])
+	addrOrdinals := binary.LittleEndian.Uint32(hdr[36:40])
+	if numFuncs == 0 {
+		return out, nil
+	}
+
+	funcs, err := img.read(addrFuncs, numFuncs*4)
+	if err != nil {
+		return out, nil
+	}
+	names := make(map[uint32]string, numNames)
+	if numNames > 0 {
+		nameRVAs, err1 := img.read(addrNames, numNames*4)
+		ordinals, err2 := img.read(addrOrdinals, numNames*2)
+		if err1 == nil && err2 == nil {
+			for i := uint32(0); i < numNames; i++ {
+				idx := uint32(binary.LittleEndian.Uint16(ordinals[i*2:]))
+				if _, ok := names[idx]; !ok {
+					names[idx] = img.cstring(binary.LittleEndian.Uint32(nameRVAs[i*4:]))
+				}
+			}
+		}
+	}
+
+	out.Functions = make([]ExportedFunction, 0, numFuncs)
+	for i := uint32(0); i < numFuncs; i++ {
+		rva := binary.LittleEndian.Uint32(funcs[i*4:])
+		if rva == 0 {
+			continue
+		}
+		fn := ExportedFunction{Name: names[i], Ordinal: base + i, RVA: rva}
+		// 函数地址落在导出目录内时是 "dll.func" 形式的转发字符串
+		if rva >= dir.VirtualAddress && rva < dir.VirtualAddress+dir.Size {
+			fn.Forwarder = img.cstring(rva)
+		}
+		out.Functions = append(out.Functions, fn)
+	}
+	return out, nil
+}
@@ -0,0 +1,81 @@
+package peinfo
+
+import (
+	"bytes"
+	"debug/pe"
+	"fmt"
+)
+
+// image 按 RVA 读取节数据。
+type image struct {
+	f *pe.File
+}
+
+func (p image) read(rva uint32, n uint32) ([]byte, error) {
+	for _, s := range p.f.Sections {
+		if rva < s.VirtualAddress || rva >= s.VirtualAddress+max(s.VirtualSize, s.Size) {
+			continue
+		}
+		buf := make([]byte, n)
+		if _, err := s.ReadAt(buf, int64(rva-s.VirtualAddress)); err != nil {
+			return nil, err
+		}
+		return buf, nil
+	}
+	return nil, fmt.Errorf("RVA 0x%X 不在任何节内", rva)
+}
+
+func (p image) cstring(rva uint32) string {
+	var b bytes.Buffer
+	for i := uint32(0); i < 512; i += 32 {
+		chunk, err := p.read(rva+i, 32)
+		if err != nil {
+			// 末尾不足 32 字节时逐字节读取
+			for j := uint32(0); j < 32; j++ {
+				c, err := p.read(rva+i+j, 1)
+				if err != nil || c[0] == 0 {
+					return b.String()
+				}
+				b.WriteByte(c[0])
+			}
+			return b.String()
+		}
+		if k := bytes.IndexByte(chunk, 0); k >= 0 {
+			b.Write(chunk[:k])
+			return b.String()
+		}
+		b.Write(chunk)
+	}
+	return b.String()
+}
+
+// sectionOf 返回包含 rva 的节名，不在任何节内时返回空串。
+func (p image) sectionOf(rva uint32) string {
+	for _, s := range p.f.Sections {
+		if rva >= s.VirtualAddress && rva < s.VirtualAddress+max(s.VirtualSize, s.Size) {
+			return s.Name
+		}
+	}
+	return ""
+}
+
+// dataDirectory 返回可选头中的第 i 个数据目录；不存在时返回零值。
+func dataDirectory(f *pe.File, i int) pe.DataDirectory {
+	switch oh := f.OptionalHeader.(type) {
+	case *pe.OptionalHeader32:
+		if uint32(i) < oh.NumberOfRvaAndSizes && i < len(oh.DataDirectory) {
+			return oh.DataDirectory[i]
+		}
+	case *pe.OptionalHeader64:
+		if uint32(i) < oh.NumberOfRvaAndSizes && i < len(oh.DataDirectory) {
+			return oh.DataDirectory[i]
+		}
+	}
+	return pe.DataDirectory{}
+}
+
+// is64 判断是否为 PE32+。
+func is64(f *pe.File) bool {
+	_, ok := f.OptionalHeader.(*pe.OptionalHeader64)
+	return ok
+}
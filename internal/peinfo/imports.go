@@ -0,0 +1,92 @@
+package peinfo
+
+import (
+	"debug/pe"
+	"encoding/binary"
+)
+
+// maxImportFunctions 导入函数总数上限，防止畸形文件让解析无限展开。
+const maxImportFunctions = 20000
+
+// ImportedFunction 导入函数；ByOrdinal 为 true 时 Name 为空，仅 Ordinal 有效。
+type ImportedFunction struct {
+	Name      string
+	Hint      uint16
+	Ordinal   uint16
+	ByOrdinal bool
+}
+
+// Import 一个导入库及其函数，顺序与导入描述符一致。
+type Import struct {
+	Library   string
+	Functions []ImportedFunction
+}
+
+// Imports 遍历导入描述符，返回按导入顺序排列的库与函数。
+// debug/pe 的 ImportedSymbols 会跳过按序号导入的项，因此这里自行解析。
+func Imports(f *pe.File) ([]Import, error) {
+	dir := dataDirectory(f, pe.IMAGE_DIRECTORY_ENTRY_IMPORT)
+	if dir.VirtualAddress == 0 {
+		return nil, nil
+	}
+	wide := is64(f)
+
+	img := image{f: f}
+	out := make([]Import, 0, 16)
+	total := 0
+	for off := dir.VirtualAddress; total < maxImportFunctions; off += 20 {
+		desc, err := img.read(off, 20)
+		if err != nil {
+			return out, nil
+		}
+		originalThunk := binary.LittleEndian.Uint32(desc[0:4])
+		nameRVA := binary.LittleEndian.Uint32(desc[12:16])
+		firstThunk := binary.LittleEndian.Uint32(desc[16:20])
+		if originalThunk == 0 && nameRVA == 0 && firstThunk == 0 {
+			break
+		}
+
+		imp := Import{Library: img.cstring(nameRVA)}
+		thunk := originalThunk
+		if thunk == 0 {
+			thunk = firstThunk
+		}
+		for total < maxImportFunctions {
+			var entry uint64
+			var ordinal bool
+			if wide {
+				b, err := img.read(thunk, 8)
+				if err != nil {
+					break
+				}
+				entry = binary.LittleEndian.Uint64(b)
+				ordinal = entry&(1<<63) != 0
+				thunk += 8
+			} else {
+				b, err := img.read(thunk, 4)
+				if err != nil {
+					break
+				}
+				entry = uint64(binary.LittleEndian.Uint32(b))
+				ordinal = entry&(1<<31) != 0
+				thunk += 4
+			}
+			if entry == 0 {
+				break
+			}
+			total++
+			if ordinal {
+				imp.Functions = append(imp.Functions, ImportedFunction{Ordinal: uint16(entry & 0xFFFF), ByOrdinal: true})
+				continue
+			}
+			// IMAGE_IMPORT_BY_NAME：2 字节 Hint 后为函数名
+			fn := ImportedFunction{Name: img.cstring(uint32(entry) + 2)}
+			if h, err := img.read(uint32(entry), 2); err == nil {
+				fn.Hint = binary.LittleEndian.Uint16(h)
+			}
+			imp.Functions = append(imp.Functions, fn)
+		}
+		out = append(out, imp)
+	}
+	return out, nil
+}
@@ -0,0 +1,106 @@
+package peinfo
+
+import (
+	"debug/pe"
+	"fmt"
+)
+
+// MachineName 返回机器类型的简称，未知类型输出十六进制。
+func MachineName(m uint16) string {
+	switch m {
+	case pe.IMAGE_FILE_MACHINE_I386:
+		return "x86"
+	case pe.IMAGE_FILE_MACHINE_AMD64:
+		return "x64"
+	case pe.IMAGE_FILE_MACHINE_ARM64:
+		return "arm64"
+	case pe.IMAGE_FILE_MACHINE_ARMNT, pe.IMAGE_FILE_MACHINE_ARM, pe.IMAGE_FILE_MACHINE_THUMB:
+		return "arm"
+	case pe.IMAGE_FILE_MACHINE_IA64:
+		return "ia64"
+	}
+	return fmt.Sprintf("0x%04X", m)
+}
+
+// SubsystemName 返回子系统名称，未知值输出十进制。
+func SubsystemName(s uint16) string {
+	switch s {
+	case pe.IMAGE_SUBSYSTEM_NATIVE:
+		return "native"
+	case pe.IMAGE_SUBSYSTEM_WINDOWS_GUI:
+		return "windows_gui"
+	case pe.IMAGE_SUBSYSTEM_WINDOWS_CUI:
+		return "windows_cui"
+	case pe.IMAGE_SUBSYSTEM_WINDOWS_CE_GUI:
+		return "windows_ce_gui"
+	case pe.IMAGE_SUBSYSTEM_EFI_APPLICATION:
+		return "efi_application"
+	case pe.IMAGE_SUBSYSTEM_EFI_BOOT_SERVICE_DRIVER:
+		return "efi_boot_service_driver"
+	case pe.IMAGE_SUBSYSTEM_EFI_RUNTIME_DRIVER:
+		return "efi_runtime_driver"
+	case pe.IMAGE_SUBSYSTEM_WINDOWS_BOOT_APPLICATION:
+		return "windows_boot_application"
+	}
+	return fmt.Sprintf("%d", s)
+}
+
+type flagName struct {
+	bit  uint32
+	name string
+}
+
+var fileCharacteristicNames = []flagName{
+	{pe.IMAGE_FILE_RELOCS_STRIPPED, "RELOCS_STRIPPED"},
+	{pe.IMAGE_FILE_EXECUTABLE_IMAGE, "EXECUTABLE_IMAGE"},
+	{pe.IMAGE_FILE_LARGE_ADDRESS_AWARE, "LARGE_ADDRESS_AWARE"},
+	{pe.IMAGE_FILE_32BIT_MACHINE, "32BIT_MACHINE"},
+	{pe.IMAGE_FILE_DEBUG_STRIPPED, "DEBUG_STRIPPED"},
+	{pe.IMAGE_FILE_SYSTEM, "SYSTEM"},
+	{pe.IMAGE_FILE_DLL, "DLL"},
+}
+
+var dllCharacteristicNames = []flagName{
+	{pe.IMAGE_DLLCHARACTERISTICS_HIGH_ENTROPY_VA, "HIGH_ENTROPY_VA"},
+	{pe.IMAGE_DLLCHARACTERISTICS_DYNAMIC_BASE, "DYNAMIC_BASE"},
+	{pe.IMAGE_DLLCHARACTERISTICS_FORCE_INTEGRITY, "FORCE_INTEGRITY"},
+	{pe.IMAGE_DLLCHARACTERISTICS_NX_COMPAT, "NX_COMPAT"},
+	{pe.IMAGE_DLLCHARACTERISTICS_NO_SEH, "NO_SEH"},
+	{pe.IMAGE_DLLCHARACTERISTICS_APPCONTAINER, "APPCONTAINER"},
+	{pe.IMAGE_DLLCHARACTERISTICS_GUARD_CF, "GUARD_CF"},
+}
+
+func flagNames(v uint32, table []flagName) []string {
+	out := make([]string, 0, len(table))
+	for _, f := range table {
+		if v&f.bit != 0 {
+			out = append(out, f.name)
+		}
+	}
+	return out
+}
+
+// CharacteristicNames 解码文件头 Characteristics 中常用的标志位。
+func CharacteristicNames(c uint16) []string {
+	return flagNames(uint32(c), fileCharacteristicNames)
+}
+
+// DllCharacteristicNames 解码可选头 DllCharacteristics（ASLR、DEP、CFG 等）。
+func DllCharacteristicNames(c uint16) []string {
+	return flagNames(uint32(c), dllCharacteristicNames)
+}
+
+// SectionPermissions 以 "rwx" 形式表示节的内存权限。
+func SectionPermissions(c uint32) string {
+	perm := []byte("---")
+	if c&pe.IMAGE_SCN_MEM_READ != 0 {
+		perm[0] = 'r'
+	}
+	if c&pe.IMAGE_SCN_MEM_WRITE != 0 {
+		perm[1] = 'w'
+	}
+	if c&pe.IMAGE_SCN_MEM_EXECUTE != 0 {
+		perm[2] = 'x'
+	}
+	return string(perm)
+}
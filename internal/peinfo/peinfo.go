@@ -0,0 +1,213 @@
+// Package peinfo 基于 debug/pe 解析 PE 文件：文件头、节与熵、导入导出表、TLS 回调、版本资源与安全目录，
+// 供 InspectPE 与 imphash 计算共用。只读取文件内容，不加载、不执行映像。
+package peinfo
+
+import (
+	"debug/pe"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"os"
+)
+
+// maxTLSCallbacks TLS 回调数组的最大遍历长度。
+const maxTLSCallbacks = 1024
+
+// Section 节信息；Entropy 为原始数据的香农熵（0~8 bit/字节），接近 8 通常意味着压缩或加密。
+type Section struct {
+	Name            string
+	VirtualAddress  uint32
+	VirtualSize     uint32
+	RawOffset       uint32
+	RawSize         uint32
+	Characteristics uint32
+	Entropy         float64
+}
+
+// Info PE 文件解析结果。
+type Info struct {
+	Machine            uint16
+	Is64               bool
+	Characteristics    uint16
+	TimeDateStamp      uint32
+	Subsystem          uint16
+	DllCharacteristics uint16
+	ImageBase          uint64
+	EntryPoint         uint32
+	EntryPointSection  string
+	SizeOfImage        uint32
+	CheckSum           uint32
+	Sections           []Section
+	Imports            []Import
+	Exports            *Exports
+	TLSCallbacks       int
+	Version            *VersionInfo
+	SecurityDirectory  pe.DataDirectory
+
+	// Warnings 记录可选部分（导入、导出、TLS、版本资源）的解析失败，不影响其余字段。
+	Warnings []string
+}
+
+// Inspect 打开 path 并解析；文件不是 PE 或头部损坏时返回错误。
+func Inspect(path string) (*Info, error) {
+	fh, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer fh.Close()
+	return Parse(fh)
+}
+
+// Parse 从 r 解析 PE。畸形文件可能触发 debug/pe 内部越界，统一转为错误返回。
+func Parse(r io.ReaderAt) (info *Info, err error) {
+	defer func() {
+		if rec := recover(); rec != nil {
+			info, err = nil, fmt.Errorf("PE 结构损坏: %v", rec)
+		}
+	}()
+
+	f, err := pe.NewFile(r)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	info = &Info{
+		Machine:         f.Machine,
+		Characteristics: f.Characteristics,
+		TimeDateStamp:   f.TimeDateStamp,
+	}
+	switch oh := f.OptionalHeader.(type) {
+	case *pe.OptionalHeader32:
+		info.Subsystem = oh.Subsystem
+		info.DllCharacteristics = oh.DllCharacteristics
+		info.ImageBase = uint64(oh.ImageBase)
+		info.EntryPoint = oh.AddressOfEntryPoint
+		info.SizeOfImage = oh.SizeOfImage
+		info.CheckSum = oh.CheckSum
+	case *pe.OptionalHeader64:
+		info.Is64 = true
+		info.Subsystem = oh.Subsystem
+		info.DllCharacteristics = oh.DllCharacteristics
+		info.ImageBase = oh.ImageBase
+		info.EntryPoint = oh.AddressOfEntryPoint
+		info.SizeOfImage = oh.SizeOfImage
+		info.CheckSum = oh.CheckSum
+	default:
+		return nil, fmt.Errorf("缺少可选头，不是可执行映像")
+	}
+
+	img := image{f: f}
+	if info.EntryPoint != 0 {
+		info.EntryPointSection = img.sectionOf(info.EntryPoint)
+	}
+	info.Sections = make([]Section, 0, len(f.Sections))
+	for _, s := range f.Sections {
+		info.Sections = append(info.Sections, Section{
+			Name:            s.Name,
+			VirtualAddress:  s.VirtualAddress,
+			VirtualSize:     s.VirtualSize,
+			RawOffset:       s.Offset,
+			RawSize:         s.Size,
+			Characteristics: s.Characteristics,
+			Entropy:         sectionEntropy(s),
+		})
+	}
+
+	if info.Imports, err = Imports(f); err != nil {
+		info.Warnings = append(info.Warnings, fmt.Sprintf("解析导入表失败: %v", err))
+	}
+	if info.Exports, err = ReadExports(f); err != nil {
+		info.Warnings = append(info.Warnings, fmt.Sprintf("解析导出表失败: %v", err))
+	}
+	if info.TLSCallbacks, err = tlsCallbackCount(img, info.ImageBase); err != nil {
+		info.Warnings = append(info.Warnings, fmt.Sprintf("解析 TLS 目录失败: %v", err))
+	}
+	if info.Version, err = ReadVersionInfo(f); err != nil {
+		info.Warnings = append(info.Warnings, fmt.Sprintf("解析版本资源失败: %v", err))
+	}
+	// 安全目录的 VirtualAddress 是文件偏移而不是 RVA，这里只报告是否存在
+	info.SecurityDirectory = dataDirectory(f, pe.IMAGE_DIRECTORY_ENTRY_SECURITY)
+	return info, nil
+}
+
+// sectionEntropy 计算节原始数据的香农熵，读取失败或无原始数据时为 0。
+func sectionEntropy(s *pe.Section) float64 {
+	if s.Size == 0 {
+		return 0
+	}
+	var counts [256]int64
+	var total int64
+	buf := make([]byte, 32<<10)
+	rd := s.Open()
+	for {
+		n, err := rd.Read(buf)
+		for _, c := range buf[:n] {
+			counts[c]++
+		}
+		total += int64(n)
+		if err != nil {
+			break
+		}
+	}
+	if total == 0 {
+		return 0
+	}
+	var h float64
+	for _, c := range counts {
+		if c == 0 {
+			continue
+		}
+		p := float64(c) / float64(total)
+		h -= p * math.Log2(p)
+	}
+	return h
+}
+
+// tlsCallbackCount 读取 IMAGE_TLS_DIRECTORY 的 AddressOfCallBacks（VA），统计以 0 结尾的回调数组长度。
+func tlsCallbackCount(img image, imageBase uint64) (int, error) {
+	dir := dataDirectory(img.f, pe.IMAGE_DIRECTORY_ENTRY_TLS)
+	if dir.VirtualAddress == 0 {
+		return 0, nil
+	}
+	wide := is64(img.f)
+	ptrSize := uint32(4)
+	if wide {
+		ptrSize = 8
+	}
+	hdr, err := img.read(dir.VirtualAddress, ptrSize*4)
+	if err != nil {
+		return 0, err
+	}
+	var callbacksVA uint64
+	if wide {
+		callbacksVA = binary.LittleEndian.Uint64(hdr[24:32])
+	} else {
+		callbacksVA = uint64(binary.LittleEndian.Uint32(hdr[12:16]))
+	}
+	if callbacksVA == 0 {
+		return 0, nil
+	}
+	if callbacksVA < imageBase || callbacksVA-imageBase > math.MaxUint32 {
+		return 0, fmt.Errorf("回调数组地址 0x%X 不在映像内", callbacksVA)
+	}
+	rva := uint32(callbacksVA - imageBase)
+	count := 0
+	for ; count < maxTLSCallbacks; count++ {
+		b, err := img.read(rva+uint32(count)*ptrSize, ptrSize)
+		if err != nil {
+			break
+		}
+		var v uint64
+		if wide {
+			v = binary.LittleEndian.Uint64(b)
+		} else {
+			v = uint64(binary.LittleEndian.Uint32(b))
+		}
+		if v == 0 {
+			break
+		}
+	}
+	return count, nil
+}
@@ -0,0 +1,191 @@
+package peinfo
+
+import (
+	"bytes"
+	"debug/pe"
+	"encoding/binary"
+	"strings"
+	"testing"
+)
+
+const (
+	testSectionRVA = 0x1000
+	testSectionRaw = 0x200
+	testSectionLen = 0x200
+	testTLSArray   = 0x100 // 回调数组在节内的偏移
+)
+
+// testImage 描述 buildTestPE 生成的最小映像：一个节，TLS 目录位于节首。
+type testImage struct {
+	wide      bool
+	imageBase uint64
+	// callbacksVA 写入 AddressOfCallBacks；为 0 时使用 imageBase+节 RVA+testTLSArray
+	callbacksVA uint64
+	callbacks   []uint64
+	noTLS       bool
+}
+
+// buildTestPE 生成只含 TLS 目录与回调数组的 PE32/PE32+ 文件。
+func buildTestPE(t testing.TB, img testImage) []byte {
+	var buf bytes.Buffer
+	dos := make([]byte, 0x40)
+	copy(dos, "MZ")
+	binary.LittleEndian.PutUint32(dos[0x3c:], 0x40)
+	buf.Write(dos)
+	buf.WriteString("PE\x00\x00")
+
+	var tlsDir pe.DataDirectory
+	if !img.noTLS {
+		tlsDir = pe.DataDirectory{VirtualAddress: testSectionRVA, Size: 24}
+	}
+	var oh any
+	machine := uint16(pe.IMAGE_FILE_MACHINE_I386)
+	if img.wide {
+		machine = pe.IMAGE_FILE_MACHINE_AMD64
+		tlsDir.Size = 40
+		o := pe.OptionalHeader64{
+			Magic: 0x20b, ImageBase: img.imageBase, SectionAlignment: 0x1000, FileAlignment: 0x200,
+			SizeOfImage: 0x2000, SizeOfHeaders: 0x200, Subsystem: pe.IMAGE_SUBSYSTEM_WINDOWS_CUI,
+			NumberOfRvaAndSizes: 16,
+		}
+		o.DataDirectory[pe.IMAGE_DIRECTORY_ENTRY_TLS] = tlsDir
+		oh = &o
+	} else {
+		o := pe.OptionalHeader32{
+			Magic: 0x10b, ImageBase: uint32(img.imageBase), SectionAlignment: 0x1000, FileAlignment: 0x200,
+			SizeOfImage: 0x2000, SizeOfHeaders: 0x200, Subsystem: pe.IMAGE_SUBSYSTEM_WINDOWS_CUI,
+			NumberOfRvaAndSizes: 16,
+		}
+		o.DataDirectory[pe.IMAGE_DIRECTORY_ENTRY_TLS] = tlsDir
+		oh = &o
+	}
+	fh := pe.FileHeader{
+		Machine:              machine,
+		NumberOfSections:     1,
+		SizeOfOptionalHeader: uint16(binary.Size(oh)),
+		Characteristics:      pe.IMAGE_FILE_EXECUTABLE_IMAGE,
+	}
+	sh := pe.SectionHeader32{
+		Name:             [8]uint8{'.', 't', 'l', 's'},
+		VirtualSize:      testSectionLen,
+		VirtualAddress:   testSectionRVA,
+		SizeOfRawData:    testSectionLen,
+		PointerToRawData: testSectionRaw,
+		Characteristics:  pe.IMAGE_SCN_CNT_INITIALIZED_DATA | pe.IMAGE_SCN_MEM_READ | pe.IMAGE_SCN_MEM_WRITE,
+	}
+	for _, v := range []any{fh, oh, sh} {
+		if err := binary.Write(&buf, binary.LittleEndian, v); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if buf.Len() > testSectionRaw {
+		t.Fatalf("headers too large: %d", buf.Len())
+	}
+	buf.Write(make([]byte, testSectionRaw-buf.Len()))
+
+	sec := make([]byte, testSectionLen)
+	callbacksVA := img.callbacksVA
+	if callbacksVA == 0 {
+		callbacksVA = img.imageBase + testSectionRVA + testTLSArray
+	}
+	ptrSize := 4
+	if img.wide {
+		ptrSize = 8
+		binary.LittleEndian.PutUint64(sec[24:], callbacksVA)
+	} else {
+		binary.LittleEndian.PutUint32(sec[12:], uint32(callbacksVA))
+	}
+	for i, cb := range img.callbacks {
+		off := testTLSArray + i*ptrSize
+		if off+ptrSize > len(sec) {
+			break
+		}
+		if img.wide {
+			binary.LittleEndian.PutUint64(sec[off:], cb)
+		} else {
+			binary.LittleEndian.PutUint32(sec[off:], uint32(cb))
+		}
+	}
+	buf.Write(sec)
+	return buf.Bytes()
+}
+
+func callbackList(base uint64, n int) []uint64 {
+	out := make([]uint64, n)
+	for i := range out {
+		out[i] = base + testSectionRVA + uint64(0x10*i)
+	}
+	return out
+}
+
+func TestTLSCallbacks(t *testing.T) {
+	const base32 = 0x400000
+	const base64 = 0x140000000
+	cases := []struct {
+		name    string
+		img     testImage
+		want    int
+		wantErr bool
+	}{
+		{"32-bit none", testImage{imageBase: base32, noTLS: true}, 0, false},
+		{"32-bit empty array", testImage{imageBase: base32}, 0, false},
+		{"32-bit two", testImage{imageBase: base32, callbacks: callbackList(base32, 2)}, 2, false},
+		{"32-bit below image base", testImage{imageBase: base32, callbacksVA: 0x1000}, 0, true},
+		// 数组没有结束符时在节末停止
+		{"32-bit unterminated", testImage{imageBase: base32, callbacks: callbackList(base32, 1000)}, (testSectionLen - testTLSArray) / 4, false},
+
+		{"64-bit none", testImage{wide: true, imageBase: base64, noTLS: true}, 0, false},
+		{"64-bit three", testImage{wide: true, imageBase: base64, callbacks: callbackList(base64, 3)}, 3, false},
+		// 高 32 位非零的指针必须按 8 字节读取，否则会被拆成两项
+		{"64-bit high pointers", testImage{wide: true, imageBase: base64, callbacks: []uint64{0x7FF600001000, 0x7FF600002000}}, 2, false},
+		{"64-bit below image base", testImage{wide: true, imageBase: base64, callbacksVA: 0x400000}, 0, true},
+		{"64-bit unterminated", testImage{wide: true, imageBase: base64, callbacks: callbackList(base64, 1000)}, (testSectionLen - testTLSArray) / 8, false},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			info, err := Parse(bytes.NewReader(buildTestPE(t, c.img)))
+			if err != nil {
+				t.Fatalf("Parse: %v", err)
+			}
+			if info.Is64 != c.img.wide {
+				t.Fatalf("Is64 = %v, want %v", info.Is64, c.img.wide)
+			}
+			if info.TLSCallbacks != c.want {
+				t.Errorf("TLSCallbacks = %d, want %d", info.TLSCallbacks, c.want)
+			}
+			gotErr := false
+			for _, w := range info.Warnings {
+				if strings.Contains(w, "TLS") {
+					gotErr = true
+				}
+			}
+			if gotErr != c.wantErr {
+				t.Errorf("TLS warning = %v, want %v (warnings %q)", gotErr, c.wantErr, info.Warnings)
+			}
+		})
+	}
+}
+
+func FuzzParse(f *testing.F) {
+	f.Add(buildTestPE(f, testImage{imageBase: 0x400000, callbacks: callbackList(0x400000, 2)}))
+	f.Add(buildTestPE(f, testImage{wide: true, imageBase: 0x140000000, callbacks: callbackList(0x140000000, 3)}))
+	f.Add(buildTestPE(f, testImage{imageBase: 0x400000, noTLS: true}))
+	f.Add([]byte("MZ"))
+	f.Fuzz(func(t *testing.T, data []byte) {
+		info, err := Parse(bytes.NewReader(data))
+		if err != nil {
+			return
+		}
+		if info == nil {
+			t.Fatal("Parse returned nil info without error")
+		}
+		if info.TLSCallbacks < 0 || info.TLSCallbacks > maxTLSCallbacks {
+			t.Fatalf("TLSCallbacks = %d", info.TLSCallbacks)
+		}
+		for _, s := range info.Sections {
+			if s.Entropy < 0 || s.Entropy > 8 {
+				t.Fatalf("section %q entropy = %v", s.Name, s.Entropy)
+			}
+		}
+	})
+}
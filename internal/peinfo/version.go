@@ -0,0 +1,204 @@
+package peinfo
+
+import (
+	"debug/pe"
+	"encoding/binary"
+	"fmt"
+	"strings"
+	"unicode/utf16"
+)
+
+const (
+	rtVersion          = 16
+	vsFixedFileInfoSig = 0xFEEF04BD
+	maxVersionInfoSize = 64 << 10
+)
+
+// VersionInfo 资源中 VS_VERSIONINFO 的内容。
+// FileVersion/ProductVersion 来自 VS_FIXEDFILEINFO；Strings 为 StringFileInfo 中首个语言表的键值（如 CompanyName）。
+type VersionInfo struct {
+	FileVersion    string
+	ProductVersion string
+	Strings        map[string]string
+}
+
+// ReadVersionInfo 定位 RT_VERSION 资源并解析，没有版本资源时返回 nil。
+func ReadVersionInfo(f *pe.File) (*VersionInfo, error) {
+	dir := dataDirectory(f, pe.IMAGE_DIRECTORY_ENTRY_RESOURCE)
+	if dir.VirtualAddress == 0 {
+		return nil, nil
+	}
+	img := image{f: f}
+	root := dir.VirtualAddress
+
+	// 资源目录三层：类型 → 名称 → 语言，版本资源取类型 16 下的第一个名称与第一个语言
+	off, ok, err := resourceEntry(img, root, 0, rtVersion)
+	if err != nil || !ok {
+		return nil, err
+	}
+	for level := 0; level < 2; level++ {
+		if off&0x80000000 == 0 {
+			return nil, fmt.Errorf("版本资源目录结构异常")
+		}
+		if off, ok, err = resourceEntry(img, root, off&0x7FFFFFFF, -1); err != nil || !ok {
+			return nil, err
+		}
+	}
+	if off&0x80000000 != 0 {
+		return nil, fmt.Errorf("版本资源目录结构异常")
+	}
+	entry, err := img.read(root+off, 8)
+	if err != nil {
+		return nil, err
+	}
+	dataRVA := binary.LittleEndian.Uint32(entry[0:4])
+	size := binary.LittleEndian.Uint32(entry[4:8])
+	if size == 0 || size > maxVersionInfoSize {
+		return nil, fmt.Errorf("版本资源大小异常: %d", size)
+	}
+	data, err := img.read(dataRVA, size)
+	if err != nil {
+		return nil, err
+	}
+	return parseVersionInfo(data)
+}
+
+// resourceEntry 在 root+dirOff 处的资源目录中查找 ID 为 id 的项（id<0 取第一项），返回其 OffsetToData。
+func resourceEntry(img image, root, dirOff uint32, id int) (uint32, bool, error) {
+	hdr, err := img.read(root+dirOff, 16)
+	if err != nil {
+		return 0, false, err
+	}
+	named := uint32(binary.LittleEndian.Uint16(hdr[12:14]))
+	ids := uint32(binary.LittleEndian.Uint16(hdr[14:16]))
+	count := named + ids
+	if count == 0 {
+		return 0, false, nil
+	}
+	entries, err := img.read(root+dirOff+16, count*8)
+	if err != nil {
+		return 0, false, err
+	}
+	for i := uint32(0); i < count; i++ {
+		name := binary.LittleEndian.Uint32(entries[i*8:])
+		data := binary.LittleEndian.Uint32(entries[i*8+4:])
+		if id < 0 || (name&0x80000000 == 0 && name == uint32(id)) {
+			return data, true, nil
+		}
+	}
+	return 0, false, nil
+}
+
+// versionBlock VS_VERSIONINFO 中的通用节点：wLength、wValueLength、wType、szKey，之后按 4 字节对齐为 Value 与子节点。
+type versionBlock struct {
+	key      string
+	value    []byte
+	text     bool
+	children []byte
+	length   int
+}
+
+func parseVersionBlock(b []byte) (versionBlock, error) {
+	if len(b) < 6 {
+		return versionBlock{}, fmt.Errorf("版本资源节点过短")
+	}
+	length := int(binary.LittleEndian.Uint16(b[0:2]))
+	valueLen := int(binary.LittleEndian.Uint16(b[2:4]))
+	text := binary.LittleEndian.Uint16(b[4:6]) == 1
+	if length < 6 || length > len(b) {
+		return versionBlock{}, fmt.Errorf("版本资源节点长度异常")
+	}
+	b = b[:length]
+
+	key, pos := utf16String(b, 6)
+	pos = align4(pos)
+	// 文本值的 wValueLength 以 WCHAR 计
+	if text {
+		valueLen *= 2
+	}
+	blk := versionBlock{key: key, text: text, length: length}
+	if valueLen > 0 && pos < len(b) {
+		end := min(pos+valueLen, len(b))
+		blk.value = b[pos:end]
+		pos = align4(end)
+	}
+	if pos < len(b) {
+		blk.children = b[pos:]
+	}
+	return blk, nil
+}
+
+// eachVersionChild 依次解析 children 中的子节点。
+func eachVersionChild(children []byte, fn func(versionBlock)) {
+	for len(children) >= 6 {
+		blk, err := parseVersionBlock(children)
+		if err != nil {
+			return
+		}
+		fn(blk)
+		next := align4(blk.length)
+		if next >= len(children) {
+			return
+		}
+		children = children[next:]
+	}
+}
+
+func parseVersionInfo(data []byte) (*VersionInfo, error) {
+	rootBlk, err := parseVersionBlock(data)
+	if err != nil {
+		return nil, err
+	}
+	if rootBlk.key != "VS_VERSION_INFO" {
+		return nil, fmt.Errorf("版本资源签名无效")
+	}
+	info := &VersionInfo{Strings: make(map[string]string)}
+	if v := rootBlk.value; len(v) >= 52 && binary.LittleEndian.Uint32(v[0:4]) == vsFixedFileInfoSig {
+		info.FileVersion = fixedVersion(binary.LittleEndian.Uint32(v[8:12]), binary.LittleEndian.Uint32(v[12:16]))
+		info.ProductVersion = fixedVersion(binary.LittleEndian.Uint32(v[16:20]), binary.LittleEndian.Uint32(v[20:24]))
+	}
+
+	eachVersionChild(rootBlk.children, func(sfi versionBlock) {
+		if sfi.key != "StringFileInfo" {
+			return
+		}
+		first := true
+		eachVersionChild(sfi.children, func(table versionBlock) {
+			// 多语言时只取第一个语言表
+			if !first {
+				return
+			}
+			first = false
+			eachVersionChild(table.children, func(s versionBlock) {
+				if s.key == "" {
+					return
+				}
+				val, _ := utf16String(s.value, 0)
+				info.Strings[s.key] = strings.TrimSpace(val)
+			})
+		})
+	})
+	return info, nil
+}
+
+func fixedVersion(ms, ls uint32) string {
+	return fmt.Sprintf("%d.%d.%d.%d", ms>>16, ms&0xFFFF, ls>>16, ls&0xFFFF)
+}
+
+// utf16String 从 b[off:] 读取以 0 结尾的 UTF-16LE 字符串，返回字符串与结束符之后的偏移。
+func utf16String(b []byte, off int) (string, int) {
+	u := make([]uint16, 0, 32)
+	for off+1 < len(b) {
+		c := binary.LittleEndian.Uint16(b[off:])
+		off += 2
+		if c == 0 {
+			break
+		}
+		u = append(u, c)
+	}
+	return string(utf16.Decode(u)), off
+}
+
+func align4(n int) int {
+	return (n + 3) &^ 3
+}
@@ -0,0 +1,138 @@
+package peinfo
+
+import (
+	"encoding/binary"
+	"testing"
+	"unicode/utf16"
+)
+
+// vsBlock 按 VS_VERSIONINFO 的节点格式编码：头部、szKey、对齐后的 Value 与子节点。
+func vsBlock(key string, value []byte, text bool, children ...[]byte) []byte {
+	b := make([]byte, 6)
+	for _, c := range utf16.Encode([]rune(key)) {
+		b = binary.LittleEndian.AppendUint16(b, c)
+	}
+	b = append(b, 0, 0)
+	b = pad4(b)
+	valueLen := len(value)
+	if text {
+		valueLen /= 2
+	}
+	b = append(b, value...)
+	for _, c := range children {
+		b = pad4(b)
+		b = append(b, c...)
+	}
+	binary.LittleEndian.PutUint16(b[0:], uint16(len(b)))
+	binary.LittleEndian.PutUint16(b[2:], uint16(valueLen))
+	if text {
+		binary.LittleEndian.PutUint16(b[4:], 1)
+	}
+	return b
+}
+
+func pad4(b []byte) []byte {
+	for len(b)%4 != 0 {
+		b = append(b, 0)
+	}
+	return b
+}
+
+func utf16z(s string) []byte {
+	var b []byte
+	for _, c := range utf16.Encode([]rune(s)) {
+		b = binary.LittleEndian.AppendUint16(b, c)
+	}
+	return append(b, 0, 0)
+}
+
+func fixedFileInfo(fileMS, fileLS, prodMS, prodLS uint32) []byte {
+	v := make([]byte, 52)
+	binary.LittleEndian.PutUint32(v[0:], vsFixedFileInfoSig)
+	binary.LittleEndian.PutUint32(v[8:], fileMS)
+	binary.LittleEndian.PutUint32(v[12:], fileLS)
+	binary.LittleEndian.PutUint32(v[16:], prodMS)
+	binary.LittleEndian.PutUint32(v[20:], prodLS)
+	return v
+}
+
+func validVersionInfo() []byte {
+	table := vsBlock("040904b0", nil, true,
+		vsBlock("CompanyName", utf16z("Acme Corp "), true),
+		vsBlock("FileVersion", utf16z("1.2.3.4"), true),
+	)
+	return vsBlock("VS_VERSION_INFO", fixedFileInfo(0x00010002, 0x00030004, 0x00050006, 0x00070008), false,
+		vsBlock("StringFileInfo", nil, true, table),
+		vsBlock("VarFileInfo", nil, true),
+	)
+}
+
+func TestParseVersionInfo(t *testing.T) {
+	valid := validVersionInfo()
+
+	// 子节点 wLength 为 0：若按长度前进会原地打转
+	zeroChild := vsBlock("StringFileInfo", nil, true)
+	zeroChild = append(zeroChild, make([]byte, 8)...)
+	binary.LittleEndian.PutUint16(zeroChild[0:], uint16(len(zeroChild)))
+	zeroLen := vsBlock("VS_VERSION_INFO", fixedFileInfo(0x00010000, 0, 0, 0), false, zeroChild)
+
+	// 子节点声明的长度覆盖整个父节点（指回自身），必须被拒绝而不是越界读取
+	selfRef := validVersionInfo()
+	child := align4(6 + len(utf16z("VS_VERSION_INFO")))
+	child = align4(child + 52)
+	binary.LittleEndian.PutUint16(selfRef[child:], uint16(len(selfRef)))
+
+	// wValueLength 超出节点范围：Value 截到节点末尾，子节点随之丢失
+	bigValue := validVersionInfo()
+	binary.LittleEndian.PutUint16(bigValue[2:], 0xFFFF)
+
+	badKey := vsBlock("VS_VERSION_INFX", fixedFileInfo(0, 0, 0, 0), false)
+
+	cases := []struct {
+		name        string
+		data        []byte
+		wantErr     bool
+		fileVersion string
+		strings     map[string]string
+	}{
+		{"valid", valid, false, "1.2.3.4", map[string]string{"CompanyName": "Acme Corp", "FileVersion": "1.2.3.4"}},
+		{"empty", nil, true, "", nil},
+		{"header only", valid[:4], true, "", nil},
+		{"truncated", valid[:len(valid)-10], true, "", nil},
+		{"truncated in value", valid[:40], true, "", nil},
+		{"wLength below header", func() []byte {
+			b := append([]byte(nil), valid...)
+			binary.LittleEndian.PutUint16(b[0:], 4)
+			return b
+		}(), true, "", nil},
+		{"bad key", badKey, true, "", nil},
+		{"zero-length child", zeroLen, false, "1.0.0.0", map[string]string{}},
+		{"self-referential child", selfRef, false, "1.2.3.4", map[string]string{}},
+		{"oversized value", bigValue, false, "1.2.3.4", map[string]string{}},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			info, err := parseVersionInfo(c.data)
+			if c.wantErr {
+				if err == nil {
+					t.Fatalf("expected error, got %+v", info)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseVersionInfo: %v", err)
+			}
+			if info.FileVersion != c.fileVersion {
+				t.Errorf("FileVersion = %q, want %q", info.FileVersion, c.fileVersion)
+			}
+			if len(info.Strings) != len(c.strings) {
+				t.Errorf("Strings = %v, want %v", info.Strings, c.strings)
+			}
+			for k, v := range c.strings {
+				if info.Strings[k] != v {
+					t.Errorf("Strings[%q] = %q, want %q", k, info.Strings[k], v)
+				}
+			}
+		})
+	}
+}
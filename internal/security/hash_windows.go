@@ -3,14 +3,13 @@
 package security
 
 import (
-	"crypto/sha256"
-	"encoding/hex"
 	"fmt"
-	"io"
 	"log"
 	"os"
 	"strings"
 	"sync"
+
+	"github.com/OpenSysKit/backend/internal/filehash"
 )
 
 var (
@@ -55,7 +54,7 @@ func ValidateProcessHash(pid uint32) error {
 		return fmt.Errorf("读取进程路径失败(pid=%d): %w", pid, err)
 	}
 
-	actual, err := fileSHA256(imagePath)
+	actual, err := filehash.FileSHA256(imagePath)
 	if err != nil {
 		return fmt.Errorf("计算进程 hash 失败(pid=%d, path=%s): %w", pid, imagePath, err)
 	}
@@ -66,17 +65,3 @@ func ValidateProcessHash(pid uint32) error {
 
 	return nil
 }
-
-func fileSHA256(path string) (string, error) {
-	f, err := os.Open(path)
-	if err != nil {
-		return "", err
-	}
-	defer f.Close()
-
-	h := sha256.New()
-	if _, err := io.Copy(h, f); err != nil {
-		return "", err
-	}
-	return hex.EncodeToString(h.Sum(nil)), nil
-}
@@ -0,0 +1,259 @@
+package service
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// accessRight 访问掩码中的一个具名位（或位组合）。
+type accessRight struct {
+	name string
+	bits uint32
+}
+
+// standardAccessRights 所有对象类型通用的标准与通用权限位。
+var standardAccessRights = []accessRight{
+	{"DELETE", 0x00010000},
+	{"READ_CONTROL", 0x00020000},
+	{"WRITE_DAC", 0x00040000},
+	{"WRITE_OWNER", 0x00080000},
+	{"SYNCHRONIZE", 0x00100000},
+	{"ACCESS_SYSTEM_SECURITY", 0x01000000},
+	{"MAXIMUM_ALLOWED", 0x02000000},
+	{"GENERIC_ALL", 0x10000000},
+	{"GENERIC_EXECUTE", 0x20000000},
+	{"GENERIC_WRITE", 0x40000000},
+	{"GENERIC_READ", 0x80000000},
+}
+
+// objectAccessTable 某一对象类型的特定权限表。
+type objectAccessTable struct {
+	all    accessRight
+	rights []accessRight
+}
+
+// objectAccessRights 按对象类型（驱动返回的 TypeName）划分的特定权限位，
+// all 为该类型的 *_ALL_ACCESS，掩码恰好等于它时只输出这一个名称。
+var objectAccessRights = map[string]objectAccessTable{
+	"file": {accessRight{"FILE_ALL_ACCESS", 0x001F01FF}, []accessRight{
+		{"FILE_READ_DATA", 0x0001},
+		{"FILE_WRITE_DATA", 0x0002},
+		{"FILE_APPEND_DATA", 0x0004},
+		{"FILE_READ_EA", 0x0008},
+		{"FILE_WRITE_EA", 0x0010},
+		{"FILE_EXECUTE", 0x0020},
+		{"FILE_DELETE_CHILD", 0x0040},
+		{"FILE_READ_ATTRIBUTES", 0x0080},
+		{"FILE_WRITE_ATTRIBUTES", 0x0100},
+	}},
+	"key": {accessRight{"KEY_ALL_ACCESS", 0x000F003F}, []accessRight{
+		{"KEY_QUERY_VALUE", 0x0001},
+		{"KEY_SET_VALUE", 0x0002},
+		{"KEY_CREATE_SUB_KEY", 0x0004},
+		{"KEY_ENUMERATE_SUB_KEYS", 0x0008},
+		{"KEY_NOTIFY", 0x0010},
+		{"KEY_CREATE_LINK", 0x0020},
+		{"KEY_WOW64_64KEY", 0x0100},
+		{"KEY_WOW64_32KEY", 0x0200},
+	}},
+	"process": {accessRight{"PROCESS_ALL_ACCESS", 0x001FFFFF}, []accessRight{
+		{"PROCESS_TERMINATE", 0x0001},
+		{"PROCESS_CREATE_THREAD", 0x0002},
+		{"PROCESS_SET_SESSIONID", 0x0004},
+		{"PROCESS_VM_OPERATION", 0x0008},
+		{"PROCESS_VM_READ", 0x0010},
+		{"PROCESS_VM_WRITE", 0x0020},
+		{"PROCESS_DUP_HANDLE", 0x0040},
+		{"PROCESS_CREATE_PROCESS", 0x0080},
+		{"PROCESS_SET_QUOTA", 0x0100},
+		{"PROCESS_SET_INFORMATION", 0x0200},
+		{"PROCESS_QUERY_INFORMATION", 0x0400},
+		{"PROCESS_SUSPEND_RESUME", 0x0800},
+		{"PROCESS_QUERY_LIMITED_INFORMATION", 0x1000},
+		{"PROCESS_SET_LIMITED_INFORMATION", 0x2000},
+	}},
+	"thread": {accessRight{"THREAD_ALL_ACCESS", 0x001FFFFF}, []accessRight{
+		{"THREAD_TERMINATE", 0x0001},
+		{"THREAD_SUSPEND_RESUME", 0x0002},
+		{"THREAD_ALERT", 0x0004},
+		{"THREAD_GET_CONTEXT", 0x0008},
+		{"THREAD_SET_CONTEXT", 0x0010},
+		{"THREAD_SET_INFORMATION", 0x0020},
+		{"THREAD_QUERY_INFORMATION", 0x0040},
+		{"THREAD_SET_THREAD_TOKEN", 0x0080},
+		{"THREAD_IMPERSONATE", 0x0100},
+		{"THREAD_DIRECT_IMPERSONATION", 0x0200},
+		{"THREAD_SET_LIMITED_INFORMATION", 0x0400},
+		{"THREAD_QUERY_LIMITED_INFORMATION", 0x0800},
+		{"THREAD_RESUME", 0x1000},
+	}},
+	"token": {accessRight{"TOKEN_ALL_ACCESS", 0x000F01FF}, []accessRight{
+		{"TOKEN_ASSIGN_PRIMARY", 0x0001},
+		{"TOKEN_DUPLICATE", 0x0002},
+		{"TOKEN_IMPERSONATE", 0x0004},
+		{"TOKEN_QUERY", 0x0008},
+		{"TOKEN_QUERY_SOURCE", 0x0010},
+		{"TOKEN_ADJUST_PRIVILEGES", 0x0020},
+		{"TOKEN_ADJUST_GROUPS", 0x0040},
+		{"TOKEN_ADJUST_DEFAULT", 0x0080},
+		{"TOKEN_ADJUST_SESSIONID", 0x0100},
+	}},
+	"section": {accessRight{"SECTION_ALL_ACCESS", 0x000F001F}, []accessRight{
+		{"SECTION_QUERY", 0x0001},
+		{"SECTION_MAP_WRITE", 0x0002},
+		{"SECTION_MAP_READ", 0x0004},
+		{"SECTION_MAP_EXECUTE", 0x0008},
+		{"SECTION_EXTEND_SIZE", 0x0010},
+		{"SECTION_MAP_EXECUTE_EXPLICIT", 0x0020},
+	}},
+	"mutant": {accessRight{"MUTANT_ALL_ACCESS", 0x001F0001}, []accessRight{
+		{"MUTANT_QUERY_STATE", 0x0001},
+	}},
+	"event": {accessRight{"EVENT_ALL_ACCESS", 0x001F0003}, []accessRight{
+		{"EVENT_QUERY_STATE", 0x0001},
+		{"EVENT_MODIFY_STATE", 0x0002},
+	}},
+	"semaphore": {accessRight{"SEMAPHORE_ALL_ACCESS", 0x001F0003}, []accessRight{
+		{"SEMAPHORE_QUERY_STATE", 0x0001},
+		{"SEMAPHORE_MODIFY_STATE", 0x0002},
+	}},
+	"directory": {accessRight{"DIRECTORY_ALL_ACCESS", 0x000F000F}, []accessRight{
+		{"DIRECTORY_QUERY", 0x0001},
+		{"DIRECTORY_TRAVERSE", 0x0002},
+		{"DIRECTORY_CREATE_OBJECT", 0x0004},
+		{"DIRECTORY_CREATE_SUBDIRECTORY", 0x0008},
+	}},
+	"symboliclink": {accessRight{"SYMBOLIC_LINK_ALL_ACCESS", 0x000F0001}, []accessRight{
+		{"SYMBOLIC_LINK_QUERY", 0x0001},
+	}},
+	"job": {accessRight{"JOB_OBJECT_ALL_ACCESS", 0x001F003F}, []accessRight{
+		{"JOB_OBJECT_ASSIGN_PROCESS", 0x0001},
+		{"JOB_OBJECT_SET_ATTRIBUTES", 0x0002},
+		{"JOB_OBJECT_QUERY", 0x0004},
+		{"JOB_OBJECT_TERMINATE", 0x0008},
+		{"JOB_OBJECT_SET_SECURITY_ATTRIBUTES", 0x0010},
+		{"JOB_OBJECT_IMPERSONATE", 0x0020},
+	}},
+	"timer": {accessRight{"TIMER_ALL_ACCESS", 0x001F0003}, []accessRight{
+		{"TIMER_QUERY_STATE", 0x0001},
+		{"TIMER_MODIFY_STATE", 0x0002},
+	}},
+	"iocompletion": {accessRight{"IO_COMPLETION_ALL_ACCESS", 0x001F0003}, []accessRight{
+		{"IO_COMPLETION_QUERY_STATE", 0x0001},
+		{"IO_COMPLETION_MODIFY_STATE", 0x0002},
+	}},
+	"desktop": {accessRight{"DESKTOP_ALL_ACCESS", 0x000F01FF}, []accessRight{
+		{"DESKTOP_READOBJECTS", 0x0001},
+		{"DESKTOP_CREATEWINDOW", 0x0002},
+		{"DESKTOP_CREATEMENU", 0x0004},
+		{"DESKTOP_HOOKCONTROL", 0x0008},
+		{"DESKTOP_JOURNALRECORD", 0x0010},
+		{"DESKTOP_JOURNALPLAYBACK", 0x0020},
+		{"DESKTOP_ENUMERATE", 0x0040},
+		{"DESKTOP_WRITEOBJECTS", 0x0080},
+		{"DESKTOP_SWITCHDESKTOP", 0x0100},
+	}},
+	"windowstation": {accessRight{"WINSTA_ALL_ACCESS", 0x000F037F}, []accessRight{
+		{"WINSTA_ENUMDESKTOPS", 0x0001},
+		{"WINSTA_READATTRIBUTES", 0x0002},
+		{"WINSTA_ACCESSCLIPBOARD", 0x0004},
+		{"WINSTA_CREATEDESKTOP", 0x0008},
+		{"WINSTA_WRITEATTRIBUTES", 0x0010},
+		{"WINSTA_ACCESSGLOBALATOMS", 0x0020},
+		{"WINSTA_EXITWINDOWS", 0x0040},
+		{"WINSTA_ENUMERATE", 0x0100},
+		{"WINSTA_READSCREEN", 0x0200},
+	}},
+}
+
+// decodeAccessMask 将句柄的 GrantedAccess 按对象类型解码为权限名列表；
+// 无法识别的位以十六进制原样输出，未知类型只解码标准权限。
+func decodeAccessMask(typeName string, mask uint32) []string {
+	names := make([]string, 0, 8)
+	remaining := mask
+
+	table, ok := objectAccessRights[strings.ToLower(typeName)]
+	if ok && mask == table.all.bits {
+		return append(names, table.all.name)
+	}
+	if ok {
+		for _, r := range table.rights {
+			if remaining&r.bits == r.bits {
+				names = append(names, r.name)
+				remaining &^= r.bits
+			}
+		}
+	}
+	for _, r := range standardAccessRights {
+		if remaining&r.bits == r.bits {
+			names = append(names, r.name)
+			remaining &^= r.bits
+		}
+	}
+	if remaining != 0 {
+		names = append(names, fmt.Sprintf("0x%X", remaining))
+	}
+	return names
+}
+
+// formatAccessMask 以 "A|B|C" 形式输出 decodeAccessMask 的结果，用于句柄列表展示。
+func formatAccessMask(typeName string, mask uint32) string {
+	return strings.Join(decodeAccessMask(typeName, mask), "|")
+}
+
+// encodeAccessMask 将 "PROCESS_TERMINATE|PROCESS_VM_WRITE" 形式的权限名解析为掩码，是 decodeAccessMask 的逆操作。
+// 分隔符可为 | , 或空白，不区分大小写；类型特定权限可省略前缀（如 process 类型下的 VM_WRITE），
+// 也可直接写十六进制/十进制数值。
+func encodeAccessMask(typeName string, spec string) (uint32, error) {
+	var table *objectAccessTable
+	prefix := ""
+	if t, ok := objectAccessRights[strings.ToLower(typeName)]; ok {
+		table = &t
+		prefix = strings.TrimSuffix(t.all.name, "ALL_ACCESS")
+	}
+
+	var mask uint32
+	tokens := strings.FieldsFunc(spec, func(r rune) bool {
+		return r == '|' || r == ',' || r == ' ' || r == '\t'
+	})
+	if len(tokens) == 0 {
+		return 0, fmt.Errorf("访问权限不能为空")
+	}
+	for _, tok := range tokens {
+		name := strings.ToUpper(tok)
+		if v, err := strconv.ParseUint(tok, 0, 32); err == nil {
+			mask |= uint32(v)
+			continue
+		}
+		bits, ok := lookupAccessRight(table, name)
+		if !ok && prefix != "" {
+			bits, ok = lookupAccessRight(table, prefix+name)
+		}
+		if !ok {
+			return 0, fmt.Errorf("未知访问权限: %s", tok)
+		}
+		mask |= bits
+	}
+	return mask, nil
+}
+
+// lookupAccessRight 在类型特定权限（table 可为 nil）与标准权限中按名称查找。
+func lookupAccessRight(table *objectAccessTable, name string) (uint32, bool) {
+	if table != nil {
+		if name == table.all.name {
+			return table.all.bits, true
+		}
+		for _, r := range table.rights {
+			if r.name == name {
+				return r.bits, true
+			}
+		}
+	}
+	for _, r := range standardAccessRights {
+		if r.name == name {
+			return r.bits, true
+		}
+	}
+	return 0, false
+}
@@ -67,13 +67,16 @@ func (s *auditStore) list(limit int) []AuditEntry {
 	return out
 }
 
+// GetAuditLogsArgs 传入 Query 时在全部审计记录（最新在前）上查询，Limit 不再生效。
 type GetAuditLogsArgs struct {
-	Limit int `json:"limit"`
+	Limit int        `json:"limit"`
+	Query *QuerySpec `json:"query,omitempty"`
 }
 
 type GetAuditLogsReply struct {
-	Total   int          `json:"total"`
-	Entries []AuditEntry `json:"entries"`
+	Total   int             `json:"total"`
+	Entries []AuditEntry    `json:"entries"`
+	Page    *QueryPageModel `json:"page"`
 }
 
 func (t *ToolkitService) GetAuditLogs(args *GetAuditLogsArgs, reply *GetAuditLogsReply) error {
@@ -81,9 +84,16 @@ func (t *ToolkitService) GetAuditLogs(args *GetAuditLogsArgs, reply *GetAuditLog
 	if limit <= 0 {
 		limit = 100
 	}
-	entries := globalAuditStore.list(limit)
+	if args.Query != nil {
+		limit = 0
+	}
+	entries, page, err := applyQuery(globalAuditStore.list(limit), args.Query)
+	if err != nil {
+		return err
+	}
 	reply.Total = len(entries)
 	reply.Entries = entries
+	reply.Page = page
 	return nil
 }
 
@@ -0,0 +1,200 @@
+package service
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+const confirmTokenTTL = 2 * time.Minute
+
+// PlanTargetModel 执行计划中的单个目标。
+// Kind 为 process/handle/file/driver，按类型填写对应字段。
+type PlanTargetModel struct {
+	Kind        string `json:"kind"`
+	ProcessId   uint32 `json:"process_id,omitempty"`
+	ImageName   string `json:"image_name,omitempty"`
+	CreateTime  uint64 `json:"create_time,omitempty"`
+	Handle      uint64 `json:"handle,omitempty"`
+	TypeName    string `json:"type_name,omitempty"`
+	ObjectName  string `json:"object_name,omitempty"`
+	Path        string `json:"path,omitempty"`
+	Size        int64  `json:"size,omitempty"`
+	ModTime     string `json:"mod_time,omitempty"`
+	ServiceName string `json:"service_name,omitempty"`
+	ImagePath   string `json:"image_path,omitempty"`
+}
+
+// ConfirmPlanModel dry_run 返回的执行计划与确认令牌
+type ConfirmPlanModel struct {
+	ConfirmToken string            `json:"confirm_token"`
+	ExpiresAt    string            `json:"expires_at"`
+	Targets      []PlanTargetModel `json:"targets"`
+}
+
+// key 目标定位（PID/句柄/路径/服务名）；identity 在定位之外附带用于识别复用的属性。
+func (p PlanTargetModel) key() string {
+	switch p.Kind {
+	case "process":
+		return fmt.Sprintf("process:%d", p.ProcessId)
+	case "handle":
+		return fmt.Sprintf("handle:%d:%d", p.ProcessId, p.Handle)
+	case "file":
+		return "file:" + p.Path
+	default:
+		return p.Kind + ":" + p.ServiceName
+	}
+}
+
+func (p PlanTargetModel) identity() string {
+	return fmt.Sprintf("%s|%s|%d|%s|%s|%d|%s|%s", p.key(), p.ImageName, p.CreateTime, p.TypeName, p.ObjectName, p.Size, p.ModTime, p.ImagePath)
+}
+
+type confirmPlan struct {
+	action    string
+	scope     string
+	targets   []PlanTargetModel
+	expiresAt time.Time
+}
+
+type confirmStore struct {
+	mu    sync.Mutex
+	plans map[string]confirmPlan
+}
+
+var globalConfirmStore = &confirmStore{plans: make(map[string]confirmPlan)}
+
+func (s *confirmStore) issue(action string, scope string, targets []PlanTargetModel) (string, time.Time, error) {
+	raw := make([]byte, 16)
+	if _, err := rand.Read(raw); err != nil {
+		return "", time.Time{}, fmt.Errorf("生成确认令牌失败: %w", err)
+	}
+	token := hex.EncodeToString(raw)
+	now := time.Now()
+	expiresAt := now.Add(confirmTokenTTL)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for k, p := range s.plans {
+		if now.After(p.expiresAt) {
+			delete(s.plans, k)
+		}
+	}
+	s.plans[token] = confirmPlan{action: action, scope: scope, targets: targets, expiresAt: expiresAt}
+	return token, expiresAt, nil
+}
+
+// redeem 取出并作废令牌；令牌一次性有效，校验失败同样作废。
+func (s *confirmStore) redeem(action string, scope string, token string) (confirmPlan, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	plan, ok := s.plans[token]
+	if !ok {
+		return confirmPlan{}, fmt.Errorf("确认令牌无效或已使用")
+	}
+	delete(s.plans, token)
+	if time.Now().After(plan.expiresAt) {
+		return confirmPlan{}, fmt.Errorf("确认令牌已过期，请重新生成计划")
+	}
+	if plan.action != action || plan.scope != scope {
+		return confirmPlan{}, fmt.Errorf("确认令牌与本次请求不匹配")
+	}
+	return plan, nil
+}
+
+// confirmGate 两阶段确认：dry_run 时签发令牌并返回计划；
+// 否则校验令牌，并按当前目标重新比对，返回可执行的目标（保持 current 的顺序）。
+// 计划外出现的新目标或同一位置的目标属性变化（如 PID 被复用）都会拒绝执行；
+// 计划内已消失的目标直接跳过。
+func confirmGate(action string, scope string, dryRun bool, token string, current []PlanTargetModel) (*ConfirmPlanModel, []PlanTargetModel, error) {
+	if dryRun {
+		token, expiresAt, err := globalConfirmStore.issue(action, scope, current)
+		if err != nil {
+			return nil, nil, err
+		}
+		return &ConfirmPlanModel{
+			ConfirmToken: token,
+			ExpiresAt:    expiresAt.Format(time.RFC3339),
+			Targets:      current,
+		}, nil, nil
+	}
+
+	if token == "" {
+		return nil, nil, fmt.Errorf("缺少 confirm_token，请先以 dry_run 生成执行计划")
+	}
+	plan, err := globalConfirmStore.redeem(action, scope, token)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	planned := make(map[string]string, len(plan.targets))
+	for _, p := range plan.targets {
+		planned[p.key()] = p.identity()
+	}
+	targets := make([]PlanTargetModel, 0, len(current))
+	for _, c := range current {
+		id, ok := planned[c.key()]
+		if !ok {
+			return nil, nil, fmt.Errorf("目标已变化（新增 %s），请重新生成计划", c.key())
+		}
+		if id != c.identity() {
+			return nil, nil, fmt.Errorf("目标已变化（%s 已被替换），请重新生成计划", c.key())
+		}
+		targets = append(targets, c)
+	}
+	return nil, targets, nil
+}
+
+// processPlanTarget 以 PID 与创建时间标识计划中的进程，执行前据此识别 PID 复用。
+// 受保护进程打不开时由驱动进程列表提供创建时间；仍取不到时拒绝生成计划，否则复用检查形同虚设。
+func (t *ToolkitService) processPlanTarget(pid uint32, imageName string) (PlanTargetModel, error) {
+	createTime, err := t.lookupCreateTime(pid)
+	if err == nil && createTime == 0 {
+		err = fmt.Errorf("创建时间为空")
+	}
+	if err != nil {
+		return PlanTargetModel{}, fmt.Errorf("无法获取进程 %d 的创建时间，不能校验 PID 复用: %w", pid, err)
+	}
+	return PlanTargetModel{Kind: "process", ProcessId: pid, ImageName: imageName, CreateTime: createTime}, nil
+}
+
+func filePlanTarget(path string) PlanTargetModel {
+	target := PlanTargetModel{Kind: "file", Path: path}
+	if fi, err := os.Lstat(path); err == nil {
+		target.Size = fi.Size()
+		target.ModTime = fi.ModTime().Format(time.RFC3339Nano)
+	}
+	return target
+}
+
+// handlePlanTarget 从驱动句柄表取出目标句柄的对象信息，连同属主进程身份作为计划目标。
+func (t *ToolkitService) handlePlanTarget(pid uint32, handle uint64) (PlanTargetModel, error) {
+	handles, err := listHandlesViaDriver(t.Driver, pid)
+	if err != nil {
+		return PlanTargetModel{}, fmt.Errorf("枚举句柄失败: %w", err)
+	}
+	for _, h := range handles {
+		if h.ProcessId != pid || h.Handle != handle {
+			continue
+		}
+		owner, err := t.processPlanTarget(pid, processImageBaseName(pid))
+		if err != nil {
+			return PlanTargetModel{}, err
+		}
+		return handleEntryPlanTarget(owner, h), nil
+	}
+	return PlanTargetModel{}, fmt.Errorf("句柄不存在: pid=%d handle=0x%X", pid, handle)
+}
+
+// handleEntryPlanTarget 在属主进程的计划目标上补充句柄信息。
+func handleEntryPlanTarget(owner PlanTargetModel, h HandleEntryModel) PlanTargetModel {
+	target := owner
+	target.Kind = "handle"
+	target.Handle = h.Handle
+	target.TypeName = h.TypeName
+	target.ObjectName = h.ObjectName
+	return target
+}
@@ -0,0 +1,259 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// dangerousProcessRight 可用于读取凭据或注入代码的进程访问权限及其风险权重。
+type dangerousProcessRight struct {
+	accessRight
+	weight int
+}
+
+var dangerousProcessRights = []dangerousProcessRight{
+	{accessRight{"PROCESS_CREATE_THREAD", 0x0002}, 40},
+	{accessRight{"PROCESS_VM_WRITE", 0x0020}, 35},
+	{accessRight{"PROCESS_VM_READ", 0x0010}, 30},
+	{accessRight{"PROCESS_DUP_HANDLE", 0x0040}, 25},
+}
+
+// SensitiveTargetModel 参与检测的敏感目标进程。
+type SensitiveTargetModel struct {
+	ProcessId uint32 `json:"process_id"`
+	ImageName string `json:"image_name"`
+	Reason    string `json:"reason"`
+	Resolved  bool   `json:"resolved"`
+}
+
+// HandleRemediationModel 针对一条发现的处置建议。
+type HandleRemediationModel struct {
+	Method    string `json:"method"`
+	ProcessId uint32 `json:"process_id"`
+	Handle    uint64 `json:"handle"`
+}
+
+// DangerousHandleModel 一条危险句柄发现。
+type DangerousHandleModel struct {
+	Rank            int                    `json:"rank"`
+	RiskScore       int                    `json:"risk_score"`
+	RiskLevel       string                 `json:"risk_level"`
+	HolderPid       uint32                 `json:"holder_pid"`
+	HolderName      string                 `json:"holder_name"`
+	HolderPath      string                 `json:"holder_path,omitempty"`
+	ExpectedHolder  bool                   `json:"expected_holder"`
+	Handle          uint64                 `json:"handle"`
+	GrantedAccess   uint32                 `json:"granted_access"`
+	Access          string                 `json:"access"`
+	DangerousRights []string               `json:"dangerous_rights"`
+	TargetPid       uint32                 `json:"target_pid"`
+	TargetName      string                 `json:"target_name"`
+	TargetReason    string                 `json:"target_reason"`
+	Remediation     HandleRemediationModel `json:"remediation"`
+}
+
+// FindDangerousHandlesArgs 危险句柄检测请求参数。
+// 默认不返回位于 System32 下的关键系统进程（csrss、services 等）持有的句柄，IncludeExpected 为 true 时以低分一并返回。
+type FindDangerousHandlesArgs struct {
+	IncludeExpected bool `json:"include_expected"`
+}
+
+// FindDangerousHandlesReply 危险句柄检测响应。
+// Partial 为 true 表示句柄表不完整或有目标未能定位，Findings 为空也不代表没有危险句柄，原因见 Warnings。
+type FindDangerousHandlesReply struct {
+	Targets    []SensitiveTargetModel `json:"targets"`
+	Scanned    int                    `json:"scanned"`
+	Suppressed int                    `json:"suppressed"`
+	Findings   []DangerousHandleModel `json:"findings"`
+	Partial    bool                   `json:"partial"`
+	Warnings   []string               `json:"warnings,omitempty"`
+}
+
+// dangerousHandleLevel 按分值划分风险等级。
+func dangerousHandleLevel(score int) string {
+	switch {
+	case score >= 60:
+		return "critical"
+	case score >= 30:
+		return "high"
+	case score >= 15:
+		return "medium"
+	default:
+		return "low"
+	}
+}
+
+// sensitiveTargets 收集 lsass.exe 以及经 ProtectProcess 保护且尚未恢复的进程。
+func sensitiveTargets(names map[uint32]string) []SensitiveTargetModel {
+	targets := make([]SensitiveTargetModel, 0, 4)
+	seen := make(map[uint32]struct{})
+	for pid, name := range names {
+		if strings.EqualFold(name, "lsass.exe") {
+			targets = append(targets, SensitiveTargetModel{ProcessId: pid, ImageName: name, Reason: "lsass"})
+			seen[pid] = struct{}{}
+		}
+	}
+	for _, e := range globalStateLedger.list() {
+		if e.Kind != StateKindProtected {
+			continue
+		}
+		if _, ok := seen[e.ProcessId]; ok {
+			continue
+		}
+		name := names[e.ProcessId]
+		if name == "" {
+			name = e.ImageName
+		}
+		targets = append(targets, SensitiveTargetModel{ProcessId: e.ProcessId, ImageName: name, Reason: "protected"})
+		seen[e.ProcessId] = struct{}{}
+	}
+	sort.Slice(targets, func(i, j int) bool { return targets[i].ProcessId < targets[j].ProcessId })
+	return targets
+}
+
+// FindDangerousHandles 查找持有 lsass 或受保护进程高危访问权限（读写内存、创建线程、复制句柄）句柄的进程，按风险排序
+func (t *ToolkitService) FindDangerousHandles(args *FindDangerousHandlesArgs, reply *FindDangerousHandlesReply) error {
+	params := map[string]any{"include_expected": args.IncludeExpected}
+	if t.Driver == nil {
+		err := fmt.Errorf("驱动未加载")
+		auditWrite("find_dangerous_handles", params, err)
+		return err
+	}
+
+	names, err := processNameMapViaDriver(t.Driver)
+	if err != nil {
+		retErr := fmt.Errorf("枚举进程失败: %w", err)
+		auditWrite("find_dangerous_handles", params, retErr)
+		return retErr
+	}
+	targets := sensitiveTargets(names)
+	pids := make([]uint32, 0, len(targets))
+	for _, tg := range targets {
+		pids = append(pids, tg.ProcessId)
+	}
+
+	// 探针句柄须在枚举期间保持打开，才能从句柄表中读到目标对象地址
+	probes, release := openProcessProbes(pids)
+	handles, err := listHandlesViaDriver(t.Driver, 0)
+	release()
+	if err != nil && !errors.Is(err, errDriverListTruncated) {
+		retErr := fmt.Errorf("枚举句柄明细失败: %w", err)
+		auditWrite("find_dangerous_handles", params, retErr)
+		return retErr
+	}
+	// 句柄表不完整时仍检测已读到的部分，但结果必须标记为不完整
+	if err != nil {
+		reply.Partial = true
+		reply.Warnings = append(reply.Warnings, err.Error())
+	}
+
+	self := uint32(os.Getpid())
+	objectToPid := make(map[uint64]uint32, len(probes))
+	for _, h := range handles {
+		if h.ProcessId != self {
+			continue
+		}
+		if pid, ok := probes[h.Handle]; ok {
+			objectToPid[h.ObjectAddress] = pid
+		}
+	}
+	targetByPid := make(map[uint32]*SensitiveTargetModel, len(targets))
+	for i := range targets {
+		targetByPid[targets[i].ProcessId] = &targets[i]
+	}
+	for _, pid := range objectToPid {
+		targetByPid[pid].Resolved = true
+	}
+	for _, tg := range targets {
+		if !tg.Resolved {
+			reply.Partial = true
+			reply.Warnings = append(reply.Warnings, fmt.Sprintf("未能定位目标进程 %s (pid=%d) 的内核对象，指向它的句柄未参与检测", tg.ImageName, tg.ProcessId))
+		}
+	}
+
+	findings := make([]DangerousHandleModel, 0)
+	holderPaths := make(map[uint32]string)
+	for _, h := range handles {
+		if h.ProcessId == self || !strings.EqualFold(h.TypeName, "Process") {
+			continue
+		}
+		targetPid, ok := objectToPid[h.ObjectAddress]
+		if !ok || targetPid == h.ProcessId {
+			continue
+		}
+
+		score := 0
+		rights := make([]string, 0, len(dangerousProcessRights))
+		for _, r := range dangerousProcessRights {
+			if h.GrantedAccess&r.bits == r.bits {
+				score += r.weight
+				rights = append(rights, r.name)
+			}
+		}
+		if score == 0 {
+			continue
+		}
+		if score > 100 {
+			score = 100
+		}
+
+		holderName := names[h.ProcessId]
+		holderPath, cached := holderPaths[h.ProcessId]
+		if !cached {
+			holderPath, _ = processImagePath(h.ProcessId)
+			holderPaths[h.ProcessId] = holderPath
+		}
+		expected := isHighRiskProcessName(holderName) && (h.ProcessId == 4 || isSystemImagePath(holderPath))
+		if expected {
+			if !args.IncludeExpected {
+				reply.Suppressed++
+				continue
+			}
+			score /= 4
+		}
+
+		target := targetByPid[targetPid]
+		findings = append(findings, DangerousHandleModel{
+			RiskScore:       score,
+			RiskLevel:       dangerousHandleLevel(score),
+			HolderPid:       h.ProcessId,
+			HolderName:      holderName,
+			HolderPath:      holderPath,
+			ExpectedHolder:  expected,
+			Handle:          h.Handle,
+			GrantedAccess:   h.GrantedAccess,
+			Access:          h.Access,
+			DangerousRights: rights,
+			TargetPid:       targetPid,
+			TargetName:      target.ImageName,
+			TargetReason:    target.Reason,
+			Remediation:     HandleRemediationModel{Method: "Toolkit.CloseHandle", ProcessId: h.ProcessId, Handle: h.Handle},
+		})
+	}
+
+	sort.SliceStable(findings, func(i, j int) bool {
+		if findings[i].RiskScore != findings[j].RiskScore {
+			return findings[i].RiskScore > findings[j].RiskScore
+		}
+		if findings[i].HolderPid != findings[j].HolderPid {
+			return findings[i].HolderPid < findings[j].HolderPid
+		}
+		return findings[i].Handle < findings[j].Handle
+	})
+	for i := range findings {
+		findings[i].Rank = i + 1
+	}
+
+	reply.Targets = targets
+	reply.Scanned = len(handles)
+	reply.Findings = findings
+	params["targets"] = len(targets)
+	params["findings"] = len(findings)
+	params["suppressed"] = reply.Suppressed
+	params["partial"] = reply.Partial
+	auditWrite("find_dangerous_handles", params, nil)
+	return nil
+}
@@ -0,0 +1,11 @@
+//go:build !windows
+
+package service
+
+func openProcessProbes(_ []uint32) (map[uint64]uint32, func()) {
+	return map[uint64]uint32{}, func() {}
+}
+
+func isSystemImagePath(_ string) bool {
+	return false
+}
@@ -0,0 +1,40 @@
+//go:build windows
+
+package service
+
+import (
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/sys/windows"
+)
+
+// openProcessProbes 以最小权限打开目标进程，返回本进程中探针句柄值到目标 PID 的映射。
+// 系统句柄表里探针句柄的 ObjectAddress 即目标 EPROCESS 地址，据此识别其他进程持有的进程句柄指向谁；
+// 调用方在枚举句柄后须调用 release 关闭探针。
+func openProcessProbes(pids []uint32) (probes map[uint64]uint32, release func()) {
+	probes = make(map[uint64]uint32, len(pids))
+	opened := make([]windows.Handle, 0, len(pids))
+	for _, pid := range pids {
+		h, err := windows.OpenProcess(windows.PROCESS_QUERY_LIMITED_INFORMATION, false, pid)
+		if err != nil {
+			continue
+		}
+		opened = append(opened, h)
+		probes[uint64(h)] = pid
+	}
+	return probes, func() {
+		for _, h := range opened {
+			windows.CloseHandle(h)
+		}
+	}
+}
+
+// isSystemImagePath 判断映像是否位于 %SystemRoot%\System32 下，用于排除冒用系统进程名的程序。
+func isSystemImagePath(path string) bool {
+	dir, err := windows.GetSystemDirectory()
+	if err != nil || path == "" {
+		return false
+	}
+	return strings.EqualFold(filepath.Dir(path), dir)
+}
@@ -0,0 +1,609 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	rebootDeleteFileName      = "pending_delete.json"
+	maxRebootDeleteRecords    = 200
+	defaultRetryIntervalSec   = 30
+	minRetryIntervalSec       = 5
+	defaultRetryMaxAttempts   = 20
+	maxRetryMaxAttempts       = 1000
+	maxFinishedDeleteRetries  = 100
+	deleteRetryTickerInterval = time.Second
+)
+
+// 重试删除项状态。
+const (
+	DeleteRetryPending   = "pending"
+	DeleteRetryDeleted   = "deleted"
+	DeleteRetryGaveUp    = "gave_up"
+	DeleteRetryCancelled = "cancelled"
+)
+
+// rebootDeleteRecord 持久化的重启删除登记，后端重启后据此核对是否已完成。
+type rebootDeleteRecord struct {
+	Path        string `json:"path"`
+	ScheduledAt string `json:"scheduled_at"`
+}
+
+// rebootDeleteStore 重启删除登记表，保存在可执行文件同目录的 pending_delete.json。
+type rebootDeleteStore struct {
+	mu      sync.Mutex
+	loaded  bool
+	file    string
+	records []rebootDeleteRecord
+}
+
+var globalRebootDeletes = &rebootDeleteStore{}
+
+func (s *rebootDeleteStore) loadLocked() error {
+	if s.loaded {
+		return nil
+	}
+	baseDir := "."
+	if exePath, err := os.Executable(); err == nil {
+		baseDir = filepath.Dir(exePath)
+	}
+	file := filepath.Join(baseDir, rebootDeleteFileName)
+	records := make([]rebootDeleteRecord, 0)
+	data, err := os.ReadFile(file)
+	switch {
+	case err == nil:
+		if err := json.Unmarshal(data, &records); err != nil {
+			return fmt.Errorf("解析重启删除队列失败: %w", err)
+		}
+	case !errors.Is(err, fs.ErrNotExist):
+		return fmt.Errorf("读取重启删除队列失败: %w", err)
+	}
+	s.file = file
+	s.records = records
+	s.loaded = true
+	return nil
+}
+
+func (s *rebootDeleteStore) saveLocked() error {
+	data, err := json.MarshalIndent(s.records, "", "  ")
+	if err != nil {
+		return fmt.Errorf("序列化重启删除队列失败: %w", err)
+	}
+	if err := os.WriteFile(s.file+".tmp", data, 0o600); err != nil {
+		return fmt.Errorf("写入重启删除队列失败: %w", err)
+	}
+	if err := os.Rename(s.file+".tmp", s.file); err != nil {
+		return fmt.Errorf("写入重启删除队列失败: %w", err)
+	}
+	return nil
+}
+
+// addLocked 登记路径；同一路径重复登记时只更新时间，超出上限时丢弃最早的记录。
+func (s *rebootDeleteStore) addLocked(path string, at time.Time) {
+	for i := range s.records {
+		if strings.EqualFold(s.records[i].Path, path) {
+			s.records = append(s.records[:i], s.records[i+1:]...)
+			break
+		}
+	}
+	s.records = append(s.records, rebootDeleteRecord{Path: path, ScheduledAt: at.Format(time.RFC3339)})
+	if len(s.records) > maxRebootDeleteRecords {
+		s.records = append([]rebootDeleteRecord(nil), s.records[len(s.records)-maxRebootDeleteRecords:]...)
+	}
+}
+
+// deleteRetryItem 进程内重试删除队列中的一项。
+type deleteRetryItem struct {
+	id          int64
+	path        string
+	useKernel   bool
+	interval    time.Duration
+	maxAttempts int
+	createdAt   time.Time
+
+	state       string
+	attempts    int
+	method      string
+	lastError   string
+	lastAttempt time.Time
+	nextAttempt time.Time
+	finishedAt  time.Time
+}
+
+// deleteRetryQueue 后端运行期间定期重试删除被占用文件；首次入队时启动协程，退出前由 StopDeleteRetryQueue 停止。
+// stopped 置位后不再接受新项，避免关闭流程中重新拉起协程。
+type deleteRetryQueue struct {
+	mu      sync.Mutex
+	t       *ToolkitService
+	items   []*deleteRetryItem
+	stop    chan struct{}
+	done    chan struct{}
+	stopped bool
+}
+
+var (
+	globalDeleteRetries = &deleteRetryQueue{}
+	deleteRetryIDSeq    atomic.Int64
+)
+
+func (q *deleteRetryQueue) enqueue(t *ToolkitService, item *deleteRetryItem) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.stopped {
+		return fmt.Errorf("删除重试队列已停止")
+	}
+	q.t = t
+	q.items = append(q.items, item)
+	q.pruneLocked()
+	if q.stop == nil {
+		q.stop = make(chan struct{})
+		q.done = make(chan struct{})
+		go q.run(q.stop, q.done)
+	}
+	return nil
+}
+
+// pruneLocked 只保留最近结束的 maxFinishedDeleteRetries 项，等待中的项不受影响。
+func (q *deleteRetryQueue) pruneLocked() {
+	finished := 0
+	for _, it := range q.items {
+		if it.state != DeleteRetryPending {
+			finished++
+		}
+	}
+	if finished <= maxFinishedDeleteRetries {
+		return
+	}
+	drop := finished - maxFinishedDeleteRetries
+	kept := q.items[:0]
+	for _, it := range q.items {
+		if drop > 0 && it.state != DeleteRetryPending {
+			drop--
+			continue
+		}
+		kept = append(kept, it)
+	}
+	q.items = kept
+}
+
+func (q *deleteRetryQueue) run(stop <-chan struct{}, done chan<- struct{}) {
+	defer close(done)
+	ticker := time.NewTicker(deleteRetryTickerInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case now := <-ticker.C:
+			q.tick(now)
+		}
+	}
+}
+
+// tick 对到期的项各尝试一次删除；删除在锁外进行，避免慢速 I/O 阻塞状态查询。
+func (q *deleteRetryQueue) tick(now time.Time) {
+	q.mu.Lock()
+	t := q.t
+	due := make([]*deleteRetryItem, 0)
+	for _, it := range q.items {
+		if it.state == DeleteRetryPending && !now.Before(it.nextAttempt) {
+			due = append(due, it)
+		}
+	}
+	q.mu.Unlock()
+
+	for _, it := range due {
+		method, err := attemptDelete(t, it.path, it.useKernel)
+
+		q.mu.Lock()
+		if it.state != DeleteRetryPending {
+			// 尝试期间已被取消
+			q.mu.Unlock()
+			continue
+		}
+		it.attempts++
+		it.lastAttempt = time.Now()
+		switch {
+		case err == nil:
+			it.state = DeleteRetryDeleted
+			it.method = method
+			it.lastError = ""
+			it.finishedAt = it.lastAttempt
+		case it.attempts >= it.maxAttempts:
+			it.state = DeleteRetryGaveUp
+			it.lastError = err.Error()
+			it.finishedAt = it.lastAttempt
+		default:
+			it.lastError = err.Error()
+			it.nextAttempt = it.lastAttempt.Add(it.interval)
+		}
+		state, path, attempts := it.state, it.path, it.attempts
+		q.mu.Unlock()
+
+		if state != DeleteRetryPending {
+			params := map[string]any{"id": it.id, "path": path, "state": state, "attempts": attempts}
+			if state == DeleteRetryDeleted {
+				params["method"] = method
+				auditWrite("delete_retry_finished", params, nil)
+			} else {
+				auditWrite("delete_retry_finished", params, err)
+			}
+		}
+	}
+}
+
+// attemptDelete 用户态删除，失败且允许时回退驱动删除；文件已不存在视为成功。
+func attemptDelete(t *ToolkitService, path string, useKernel bool) (string, error) {
+	err := removeUserMode(path)
+	if err == nil {
+		return "user", nil
+	}
+	if !useKernel || t == nil || t.Driver == nil {
+		return "", err
+	}
+	if kerr := deleteFileViaDriver(t.Driver, path); kerr != nil {
+		return "", fmt.Errorf("%v; 内核删除文件失败: %w", err, kerr)
+	}
+	if _, statErr := os.Lstat(path); statErr == nil {
+		return "", fmt.Errorf("%v; 内核删除后文件仍存在", err)
+	}
+	return "kernel", nil
+}
+
+// StopDeleteRetryQueue 停止后台重试删除，须在关闭驱动句柄前调用；未完成的项随进程退出丢弃。
+func StopDeleteRetryQueue() {
+	q := globalDeleteRetries
+	q.mu.Lock()
+	stop, done := q.stop, q.done
+	q.stop, q.done = nil, nil
+	q.stopped = true
+	q.mu.Unlock()
+	if stop != nil {
+		close(stop)
+		<-done
+	}
+}
+
+// resolveDeleteTarget 统一路径形式并确认目标存在且不是目录。
+func resolveDeleteTarget(path string) (string, error) {
+	if strings.TrimSpace(path) == "" {
+		return "", fmt.Errorf("path 不能为空")
+	}
+	target, err := win32Path(path)
+	if err != nil {
+		return "", fmt.Errorf("路径解析失败: %w", err)
+	}
+	info, err := os.Lstat(target)
+	if err != nil {
+		return "", fmt.Errorf("文件不存在或不可访问: %s", target)
+	}
+	if info.IsDir() {
+		return "", fmt.Errorf("不支持目录: %s", target)
+	}
+	return target, nil
+}
+
+func (t *ToolkitService) queueDeleteRetry(path string, intervalSec int, maxAttempts int, useKernel bool) (*deleteRetryItem, error) {
+	if intervalSec <= 0 {
+		intervalSec = defaultRetryIntervalSec
+	}
+	if intervalSec < minRetryIntervalSec {
+		intervalSec = minRetryIntervalSec
+	}
+	if maxAttempts <= 0 {
+		maxAttempts = defaultRetryMaxAttempts
+	}
+	if maxAttempts > maxRetryMaxAttempts {
+		maxAttempts = maxRetryMaxAttempts
+	}
+	now := time.Now()
+	item := &deleteRetryItem{
+		id:          deleteRetryIDSeq.Add(1),
+		path:        path,
+		useKernel:   useKernel,
+		interval:    time.Duration(intervalSec) * time.Second,
+		maxAttempts: maxAttempts,
+		createdAt:   now,
+		state:       DeleteRetryPending,
+		nextAttempt: now,
+	}
+	if err := globalDeleteRetries.enqueue(t, item); err != nil {
+		return nil, err
+	}
+	return item, nil
+}
+
+// ScheduleDeleteOnRebootArgs 登记重启删除请求参数。
+// Retry 为 true 时同时加入进程内重试队列，在重启前文件解除占用即可删除；需 dry_run + confirm_token 两阶段确认。
+type ScheduleDeleteOnRebootArgs struct {
+	Path                 string `json:"path"`
+	Retry                bool   `json:"retry"`
+	RetryIntervalSeconds int    `json:"retry_interval_seconds"`
+	MaxAttempts          int    `json:"max_attempts"`
+	UseKernel            bool   `json:"use_kernel"`
+	DryRun               bool   `json:"dry_run"`
+	ConfirmToken         string `json:"confirm_token"`
+}
+
+// ScheduleDeleteOnRebootReply 登记重启删除响应
+type ScheduleDeleteOnRebootReply struct {
+	Path        string            `json:"path"`
+	Scheduled   bool              `json:"scheduled"`
+	ScheduledAt string            `json:"scheduled_at"`
+	RetryId     int64             `json:"retry_id,omitempty"`
+	Plan        *ConfirmPlanModel `json:"plan,omitempty"`
+}
+
+// ScheduleDeleteOnReboot 把文件写入 PendingFileRenameOperations，在下次启动时由系统删除，并记入持久化的重启删除队列
+func (t *ToolkitService) ScheduleDeleteOnReboot(args *ScheduleDeleteOnRebootArgs, reply *ScheduleDeleteOnRebootReply) error {
+	params := map[string]any{"path": args.Path, "retry": args.Retry}
+	target, err := resolveDeleteTarget(args.Path)
+	if err != nil {
+		auditWrite("schedule_delete_on_reboot", params, err)
+		return err
+	}
+	scope := fmt.Sprintf("%s|%t|%d|%d|%t", strings.ToLower(target), args.Retry, args.RetryIntervalSeconds, args.MaxAttempts, args.UseKernel)
+	plan, _, err := confirmGate("schedule_delete_on_reboot", scope, args.DryRun, args.ConfirmToken, []PlanTargetModel{filePlanTarget(target)})
+	if err != nil {
+		auditWrite("schedule_delete_on_reboot", params, err)
+		return err
+	}
+	if plan != nil {
+		reply.Path = target
+		reply.Plan = plan
+		params["dry_run"] = true
+		auditWrite("schedule_delete_on_reboot", params, nil)
+		return nil
+	}
+	if err := scheduleDeleteOnReboot(target); err != nil {
+		retErr := fmt.Errorf("登记重启删除失败: %w", err)
+		auditWrite("schedule_delete_on_reboot", params, retErr)
+		return retErr
+	}
+
+	now := time.Now()
+	globalRebootDeletes.mu.Lock()
+	err = globalRebootDeletes.loadLocked()
+	if err == nil {
+		globalRebootDeletes.addLocked(target, now)
+		err = globalRebootDeletes.saveLocked()
+	}
+	globalRebootDeletes.mu.Unlock()
+	if err != nil {
+		// 系统侧已登记，仅本地队列写入失败
+		retErr := fmt.Errorf("已登记重启删除，但%w", err)
+		auditWrite("schedule_delete_on_reboot", params, retErr)
+		return retErr
+	}
+
+	reply.Path = target
+	reply.Scheduled = true
+	reply.ScheduledAt = now.Format(time.RFC3339)
+	if args.Retry {
+		item, err := t.queueDeleteRetry(target, args.RetryIntervalSeconds, args.MaxAttempts, args.UseKernel)
+		if err != nil {
+			retErr := fmt.Errorf("已登记重启删除，但加入重试队列失败: %w", err)
+			auditWrite("schedule_delete_on_reboot", params, retErr)
+			return retErr
+		}
+		reply.RetryId = item.id
+		params["retry_id"] = reply.RetryId
+	}
+	auditWrite("schedule_delete_on_reboot", params, nil)
+	return nil
+}
+
+// QueueDeleteRetryArgs 加入重试删除队列请求参数。
+// IntervalSeconds 默认 30、最小 5；MaxAttempts 默认 20；UseKernel 为 true 且驱动已加载时每次重试都回退驱动删除；
+// 需 dry_run + confirm_token 两阶段确认。
+type QueueDeleteRetryArgs struct {
+	Path            string `json:"path"`
+	IntervalSeconds int    `json:"interval_seconds"`
+	MaxAttempts     int    `json:"max_attempts"`
+	UseKernel       bool   `json:"use_kernel"`
+	DryRun          bool   `json:"dry_run"`
+	ConfirmToken    string `json:"confirm_token"`
+}
+
+// QueueDeleteRetryReply 加入重试删除队列响应
+type QueueDeleteRetryReply struct {
+	Item DeleteRetryModel  `json:"item"`
+	Plan *ConfirmPlanModel `json:"plan,omitempty"`
+}
+
+// QueueDeleteRetry 把被占用的文件加入进程内重试队列，后端运行期间按间隔重试删除
+func (t *ToolkitService) QueueDeleteRetry(args *QueueDeleteRetryArgs, reply *QueueDeleteRetryReply) error {
+	params := map[string]any{"path": args.Path, "interval_seconds": args.IntervalSeconds, "max_attempts": args.MaxAttempts, "use_kernel": args.UseKernel}
+	target, err := resolveDeleteTarget(args.Path)
+	if err != nil {
+		auditWrite("queue_delete_retry", params, err)
+		return err
+	}
+	scope := fmt.Sprintf("%s|%d|%d|%t", strings.ToLower(target), args.IntervalSeconds, args.MaxAttempts, args.UseKernel)
+	plan, _, err := confirmGate("queue_delete_retry", scope, args.DryRun, args.ConfirmToken, []PlanTargetModel{filePlanTarget(target)})
+	if err != nil {
+		auditWrite("queue_delete_retry", params, err)
+		return err
+	}
+	if plan != nil {
+		reply.Plan = plan
+		params["dry_run"] = true
+		auditWrite("queue_delete_retry", params, nil)
+		return nil
+	}
+	item, err := t.queueDeleteRetry(target, args.IntervalSeconds, args.MaxAttempts, args.UseKernel)
+	if err != nil {
+		auditWrite("queue_delete_retry", params, err)
+		return err
+	}
+	globalDeleteRetries.mu.Lock()
+	reply.Item = item.model()
+	globalDeleteRetries.mu.Unlock()
+	params["id"] = item.id
+	auditWrite("queue_delete_retry", params, nil)
+	return nil
+}
+
+// CancelDeleteRetryArgs 取消重试删除请求参数
+type CancelDeleteRetryArgs struct {
+	Id int64 `json:"id"`
+}
+
+// CancelDeleteRetryReply 取消重试删除响应
+type CancelDeleteRetryReply struct {
+	Item DeleteRetryModel `json:"item"`
+}
+
+// CancelDeleteRetry 取消等待中的重试删除项
+func (t *ToolkitService) CancelDeleteRetry(args *CancelDeleteRetryArgs, reply *CancelDeleteRetryReply) error {
+	params := map[string]any{"id": args.Id}
+	q := globalDeleteRetries
+	q.mu.Lock()
+	var item *deleteRetryItem
+	for _, it := range q.items {
+		if it.id == args.Id {
+			item = it
+			break
+		}
+	}
+	var err error
+	switch {
+	case item == nil:
+		err = fmt.Errorf("重试删除项不存在: %d", args.Id)
+	case item.state != DeleteRetryPending:
+		err = fmt.Errorf("重试删除项已结束: %d (%s)", args.Id, item.state)
+	default:
+		item.state = DeleteRetryCancelled
+		item.finishedAt = time.Now()
+		reply.Item = item.model()
+		params["path"] = item.path
+	}
+	q.mu.Unlock()
+	auditWrite("cancel_delete_retry", params, err)
+	return err
+}
+
+// RebootDeleteModel 重启删除登记的当前状态。
+// State 为 pending（仍在 PendingFileRenameOperations 中）、completed（文件已不存在）
+// 或 lost（系统登记已消失但文件仍在，例如重启时删除失败或登记被清除）。
+type RebootDeleteModel struct {
+	Path        string `json:"path"`
+	ScheduledAt string `json:"scheduled_at"`
+	State       string `json:"state"`
+	Exists      bool   `json:"exists"`
+}
+
+// DeleteRetryModel 重试删除项的当前状态
+type DeleteRetryModel struct {
+	Id              int64  `json:"id"`
+	Path            string `json:"path"`
+	State           string `json:"state"`
+	Attempts        int    `json:"attempts"`
+	MaxAttempts     int    `json:"max_attempts"`
+	IntervalSeconds int    `json:"interval_seconds"`
+	UseKernel       bool   `json:"use_kernel"`
+	Method          string `json:"method,omitempty"`
+	LastError       string `json:"last_error,omitempty"`
+	CreatedAt       string `json:"created_at"`
+	LastAttemptAt   string `json:"last_attempt_at,omitempty"`
+	NextAttemptAt   string `json:"next_attempt_at,omitempty"`
+	FinishedAt      string `json:"finished_at,omitempty"`
+}
+
+// model 须在持有队列锁时调用。
+func (it *deleteRetryItem) model() DeleteRetryModel {
+	m := DeleteRetryModel{
+		Id:              it.id,
+		Path:            it.path,
+		State:           it.state,
+		Attempts:        it.attempts,
+		MaxAttempts:     it.maxAttempts,
+		IntervalSeconds: int(it.interval / time.Second),
+		UseKernel:       it.useKernel,
+		Method:          it.method,
+		LastError:       it.lastError,
+		CreatedAt:       it.createdAt.Format(time.RFC3339),
+	}
+	if !it.lastAttempt.IsZero() {
+		m.LastAttemptAt = it.lastAttempt.Format(time.RFC3339)
+	}
+	if it.state == DeleteRetryPending {
+		m.NextAttemptAt = it.nextAttempt.Format(time.RFC3339)
+	}
+	if !it.finishedAt.IsZero() {
+		m.FinishedAt = it.finishedAt.Format(time.RFC3339)
+	}
+	return m
+}
+
+// GetDeleteQueueStatusArgs 删除队列状态请求参数
+type GetDeleteQueueStatusArgs struct{}
+
+// GetDeleteQueueStatusReply 删除队列状态响应
+type GetDeleteQueueStatusReply struct {
+	RebootPending int                 `json:"reboot_pending"`
+	Reboot        []RebootDeleteModel `json:"reboot"`
+	RetryRunning  bool                `json:"retry_running"`
+	RetryPending  int                 `json:"retry_pending"`
+	Retry         []DeleteRetryModel  `json:"retry"`
+	RegistryError string              `json:"registry_error,omitempty"`
+}
+
+// GetDeleteQueueStatus 返回重启删除登记（与 PendingFileRenameOperations 核对）与进程内重试队列的状态
+func (t *ToolkitService) GetDeleteQueueStatus(_ *GetDeleteQueueStatusArgs, reply *GetDeleteQueueStatusReply) error {
+	globalRebootDeletes.mu.Lock()
+	err := globalRebootDeletes.loadLocked()
+	records := append([]rebootDeleteRecord(nil), globalRebootDeletes.records...)
+	globalRebootDeletes.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	pending, regErr := pendingRebootDeletes()
+	if regErr != nil {
+		reply.RegistryError = regErr.Error()
+	}
+	reply.Reboot = make([]RebootDeleteModel, 0, len(records))
+	for i := len(records) - 1; i >= 0; i-- {
+		r := records[i]
+		m := RebootDeleteModel{Path: r.Path, ScheduledAt: r.ScheduledAt}
+		_, statErr := os.Lstat(r.Path)
+		m.Exists = statErr == nil
+		switch {
+		case pending[strings.ToLower(r.Path)]:
+			m.State = "pending"
+			reply.RebootPending++
+		case !m.Exists:
+			m.State = "completed"
+		case regErr != nil:
+			m.State = "unknown"
+		default:
+			m.State = "lost"
+		}
+		reply.Reboot = append(reply.Reboot, m)
+	}
+
+	q := globalDeleteRetries
+	q.mu.Lock()
+	reply.RetryRunning = q.stop != nil
+	reply.Retry = make([]DeleteRetryModel, 0, len(q.items))
+	for _, it := range q.items {
+		if it.state == DeleteRetryPending {
+			reply.RetryPending++
+		}
+		reply.Retry = append(reply.Retry, it.model())
+	}
+	q.mu.Unlock()
+	sort.Slice(reply.Retry, func(i, j int) bool { return reply.Retry[i].Id > reply.Retry[j].Id })
+	return nil
+}
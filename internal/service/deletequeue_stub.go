@@ -0,0 +1,13 @@
+//go:build !windows
+
+package service
+
+import "fmt"
+
+func scheduleDeleteOnReboot(_ string) error {
+	return fmt.Errorf("仅支持 Windows")
+}
+
+func pendingRebootDeletes() (map[string]bool, error) {
+	return nil, fmt.Errorf("仅支持 Windows")
+}
@@ -0,0 +1,50 @@
+//go:build windows
+
+package service
+
+import (
+	"strings"
+
+	"golang.org/x/sys/windows"
+	"golang.org/x/sys/windows/registry"
+)
+
+const sessionManagerKey = `SYSTEM\CurrentControlSet\Control\Session Manager`
+
+// scheduleDeleteOnReboot 通过 MoveFileEx(MOVEFILE_DELAY_UNTIL_REBOOT) 登记重启删除，
+// 系统会把它写入 PendingFileRenameOperations，需要管理员权限。
+func scheduleDeleteOnReboot(path string) error {
+	p, err := windows.UTF16PtrFromString(path)
+	if err != nil {
+		return err
+	}
+	return windows.MoveFileEx(p, nil, windows.MOVEFILE_DELAY_UNTIL_REBOOT)
+}
+
+// pendingRebootDeletes 读取 PendingFileRenameOperations 中目标为空（即删除）的源路径，返回小写盘符路径集合。
+func pendingRebootDeletes() (map[string]bool, error) {
+	k, err := registry.OpenKey(registry.LOCAL_MACHINE, sessionManagerKey, registry.QUERY_VALUE)
+	if err != nil {
+		return nil, err
+	}
+	defer k.Close()
+
+	out := make(map[string]bool)
+	// 值为“源、目标”成对的 REG_MULTI_SZ，删除操作的目标为空串
+	items, _, err := k.GetStringsValue("PendingFileRenameOperations")
+	if err == registry.ErrNotExist {
+		return out, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	for i := 0; i+1 < len(items); i += 2 {
+		src, dst := items[i], items[i+1]
+		if src == "" || dst != "" {
+			continue
+		}
+		src = strings.TrimPrefix(src, `\??\`)
+		out[strings.ToLower(src)] = true
+	}
+	return out, nil
+}
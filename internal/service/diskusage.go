@@ -0,0 +1,283 @@
+package service
+
+import (
+	"container/heap"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	defaultDiskUsageDepth    = 3
+	maxDiskUsageDepth        = 16
+	defaultDiskUsageTopN     = 20
+	maxDiskUsageTopN         = 1000
+	maxDiskUsageChildren     = 100
+	maxDiskUsageErrorSamples = 50
+	diskUsageReportInterval  = 250 * time.Millisecond
+)
+
+// AnalyzeDiskUsageArgs 磁盘占用分析请求参数。
+// Depth 为结果树展开的目录层数（更深的目录只计入上层汇总），TopN 为最大文件榜单长度；
+// IncludeHidden 缺省为 true：隐藏/系统项（pagefile.sys、hiberfil.sys、System Volume Information 等）
+// 往往是占用大头，跳过会让统计明显偏小；显式传 false 时才跳过。
+type AnalyzeDiskUsageArgs struct {
+	Path          string `json:"path"`
+	Depth         int    `json:"depth"`
+	TopN          int    `json:"top_n"`
+	IncludeHidden *bool  `json:"include_hidden"`
+}
+
+// AnalyzeDiskUsageReply 磁盘占用分析响应，结果通过 GetJob 获取
+type AnalyzeDiskUsageReply struct {
+	JobId int64  `json:"job_id"`
+	Path  string `json:"path"`
+}
+
+// DiskUsageNodeModel 结果树中的一个目录节点，Size 含全部子孙。
+type DiskUsageNodeModel struct {
+	Name            string                `json:"name"`
+	Path            string                `json:"path"`
+	Size            int64                 `json:"size"`
+	FileBytes       int64                 `json:"file_bytes"`
+	Files           int64                 `json:"files"`
+	Dirs            int64                 `json:"dirs"`
+	Errors          int64                 `json:"errors"`
+	Children        []*DiskUsageNodeModel `json:"children,omitempty"`
+	OmittedChildren int                   `json:"omitted_children,omitempty"`
+}
+
+// DiskUsageFileModel 最大文件榜单中的一项。
+type DiskUsageFileModel struct {
+	Path    string `json:"path"`
+	Size    int64  `json:"size"`
+	ModTime string `json:"mod_time"`
+}
+
+// DiskUsageErrorModel 无法读取的目录项。
+type DiskUsageErrorModel struct {
+	Path  string `json:"path"`
+	Error string `json:"error"`
+}
+
+// DiskUsageProgressModel 分析进度。
+type DiskUsageProgressModel struct {
+	ScannedFiles        int64   `json:"scanned_files"`
+	ScannedDirs         int64   `json:"scanned_dirs"`
+	ScannedBytes        int64   `json:"scanned_bytes"`
+	Errors              int64   `json:"errors"`
+	CurrentDir          string  `json:"current_dir"`
+	EstimatedTotalBytes uint64  `json:"estimated_total_bytes,omitempty"`
+	Percent             float64 `json:"percent,omitempty"`
+}
+
+// DiskUsageResultModel 分析结果；任务被取消时为已扫描部分的结果。
+type DiskUsageResultModel struct {
+	Root         *DiskUsageNodeModel   `json:"root"`
+	TopFiles     []DiskUsageFileModel  `json:"top_files"`
+	ErrorSamples []DiskUsageErrorModel `json:"error_samples"`
+	Partial      bool                  `json:"partial"`
+}
+
+// diskUsageHeap 按大小排列的最小堆，用于维护前 N 大文件。
+type diskUsageHeap []DiskUsageFileModel
+
+func (h diskUsageHeap) Len() int           { return len(h) }
+func (h diskUsageHeap) Less(i, j int) bool { return h[i].Size < h[j].Size }
+func (h diskUsageHeap) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+func (h *diskUsageHeap) Push(x any)        { *h = append(*h, x.(DiskUsageFileModel)) }
+func (h *diskUsageHeap) Pop() any {
+	old := *h
+	x := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return x
+}
+
+type diskUsageWalker struct {
+	ctx           context.Context
+	report        func(any)
+	depth         int
+	topN          int
+	includeHidden bool
+
+	top        diskUsageHeap
+	errors     []DiskUsageErrorModel
+	progress   DiskUsageProgressModel
+	lastReport time.Time
+}
+
+func (w *diskUsageWalker) addError(path string, err error) {
+	w.progress.Errors++
+	if len(w.errors) < maxDiskUsageErrorSamples {
+		w.errors = append(w.errors, DiskUsageErrorModel{Path: path, Error: err.Error()})
+	}
+}
+
+func (w *diskUsageWalker) maybeReport(force bool) {
+	if !force && time.Since(w.lastReport) < diskUsageReportInterval {
+		return
+	}
+	w.lastReport = time.Now()
+	p := w.progress
+	if p.EstimatedTotalBytes > 0 {
+		p.Percent = float64(p.ScannedBytes) * 100 / float64(p.EstimatedTotalBytes)
+		if p.Percent > 100 {
+			p.Percent = 100
+		}
+	}
+	w.report(p)
+}
+
+// walk 递归统计目录；level 小于 depth 时保留子目录节点，否则只汇总数值。
+// 重解析点（符号链接、联接）不跟随，避免重复统计与循环。
+func (w *diskUsageWalker) walk(path string, level int) (*DiskUsageNodeModel, error) {
+	node := &DiskUsageNodeModel{Name: filepath.Base(path), Path: path}
+	if filepath.Dir(path) == path {
+		node.Name = path
+	}
+	if err := w.ctx.Err(); err != nil {
+		return node, err
+	}
+	w.progress.ScannedDirs++
+	w.progress.CurrentDir = path
+	w.maybeReport(false)
+
+	entries, err := os.ReadDir(path)
+	if err != nil {
+		node.Errors++
+		w.addError(path, err)
+		return node, nil
+	}
+
+	for _, entry := range entries {
+		full := filepath.Join(path, entry.Name())
+		info, err := entry.Info()
+		if err != nil {
+			node.Errors++
+			w.addError(full, err)
+			continue
+		}
+		ext := queryFileExtInfo(full, info)
+		if !w.includeHidden && ext.attributes&(fileAttributeHidden|fileAttributeSystem) != 0 {
+			continue
+		}
+		if ext.attributes&fileAttributeReparsePoint != 0 || info.Mode()&os.ModeSymlink != 0 {
+			continue
+		}
+
+		if entry.IsDir() {
+			child, err := w.walk(full, level+1)
+			node.Size += child.Size
+			node.Files += child.Files
+			node.Dirs += child.Dirs + 1
+			node.Errors += child.Errors
+			if level < w.depth {
+				node.Children = append(node.Children, child)
+			}
+			if err != nil {
+				return node, err
+			}
+			continue
+		}
+
+		size := info.Size()
+		node.Size += size
+		node.FileBytes += size
+		node.Files++
+		w.progress.ScannedFiles++
+		w.progress.ScannedBytes += size
+		if len(w.top) < w.topN {
+			heap.Push(&w.top, DiskUsageFileModel{Path: full, Size: size, ModTime: info.ModTime().Format(time.RFC3339)})
+		} else if w.topN > 0 && size > w.top[0].Size {
+			w.top[0] = DiskUsageFileModel{Path: full, Size: size, ModTime: info.ModTime().Format(time.RFC3339)}
+			heap.Fix(&w.top, 0)
+		}
+	}
+
+	sort.Slice(node.Children, func(i, j int) bool { return node.Children[i].Size > node.Children[j].Size })
+	if len(node.Children) > maxDiskUsageChildren {
+		node.OmittedChildren = len(node.Children) - maxDiskUsageChildren
+		node.Children = node.Children[:maxDiskUsageChildren]
+	}
+	return node, nil
+}
+
+// volumeUsedBytes 路径恰为盘符根目录时返回该卷已用空间，作为进度估算的分母。
+func volumeUsedBytes(path string) uint64 {
+	volumes, err := listVolumes()
+	if err != nil {
+		return 0
+	}
+	for _, v := range volumes {
+		if strings.EqualFold(v.Root, path) && v.TotalBytes >= v.FreeBytes {
+			return v.TotalBytes - v.FreeBytes
+		}
+	}
+	return 0
+}
+
+// AnalyzeDiskUsage 以后台任务递归统计目录占用，立即返回 job_id；通过 GetJob 查看进度与结果，CancelJob 取消
+func (t *ToolkitService) AnalyzeDiskUsage(args *AnalyzeDiskUsageArgs, reply *AnalyzeDiskUsageReply) error {
+	includeHidden := args.IncludeHidden == nil || *args.IncludeHidden
+	params := map[string]any{"path": args.Path, "depth": args.Depth, "top_n": args.TopN, "include_hidden": includeHidden}
+	if strings.TrimSpace(args.Path) == "" {
+		err := fmt.Errorf("path 不能为空")
+		auditWrite("analyze_disk_usage", params, err)
+		return err
+	}
+	absPath, err := filepath.Abs(filepath.Clean(args.Path))
+	if err != nil {
+		retErr := fmt.Errorf("路径解析失败: %w", err)
+		auditWrite("analyze_disk_usage", params, retErr)
+		return retErr
+	}
+	if info, err := os.Stat(absPath); err != nil || !info.IsDir() {
+		retErr := fmt.Errorf("目录不存在或不可访问: %s", absPath)
+		auditWrite("analyze_disk_usage", params, retErr)
+		return retErr
+	}
+
+	depth := args.Depth
+	if depth <= 0 {
+		depth = defaultDiskUsageDepth
+	}
+	if depth > maxDiskUsageDepth {
+		depth = maxDiskUsageDepth
+	}
+	topN := args.TopN
+	if topN <= 0 {
+		topN = defaultDiskUsageTopN
+	}
+	if topN > maxDiskUsageTopN {
+		topN = maxDiskUsageTopN
+	}
+
+	jobParams := map[string]any{"path": absPath, "depth": depth, "top_n": topN, "include_hidden": includeHidden}
+	reply.JobId = startJob("analyze_disk_usage", jobParams, func(ctx context.Context, report func(any)) (any, error) {
+		w := &diskUsageWalker{
+			ctx:           ctx,
+			report:        report,
+			depth:         depth,
+			topN:          topN,
+			includeHidden: includeHidden,
+			top:           make(diskUsageHeap, 0, topN),
+			errors:        make([]DiskUsageErrorModel, 0),
+		}
+		w.progress.EstimatedTotalBytes = volumeUsedBytes(absPath)
+		root, err := w.walk(absPath, 0)
+		w.progress.CurrentDir = ""
+		w.maybeReport(true)
+
+		top := append([]DiskUsageFileModel(nil), w.top...)
+		sort.Slice(top, func(i, j int) bool { return top[i].Size > top[j].Size })
+		return &DiskUsageResultModel{Root: root, TopFiles: top, ErrorSamples: w.errors, Partial: err != nil}, err
+	})
+	reply.Path = absPath
+	params["job_id"] = reply.JobId
+	auditWrite("analyze_disk_usage", params, nil)
+	return nil
+}
@@ -3,11 +3,13 @@ package service
 import (
 	"bytes"
 	"encoding/binary"
+	"errors"
 	"fmt"
 	"net"
 	"sort"
 	"strings"
 	"syscall"
+	"time"
 
 	"github.com/OpenSysKit/backend/internal/driver"
 )
@@ -15,31 +17,73 @@ import (
 const (
 	driverEnumModulesOutSize       uint32 = 512 * 1024
 	driverEnumThreadsOutSize       uint32 = 256 * 1024
+	driverEnumThreadsMaxOutSize    uint32 = 16 * 1024 * 1024
 	driverEnumKernelModulesOutSize uint32 = 512 * 1024
 	driverEnumHandlesOutSize       uint32 = 8 * 1024 * 1024
+	driverEnumHandlesMaxOutSize    uint32 = 128 * 1024 * 1024
 	driverEnumConnectionsOutSize   uint32 = 2 * 1024 * 1024
 )
 
+// errDriverListTruncated 枚举结果在缓冲区上限内仍放不下，驱动返回的列表只是一部分。
+// 调用方拿到包装了它的错误时不得把结果当作完整列表使用。
+var errDriverListTruncated = errors.New("驱动返回的列表不完整")
+
+// ioctlEnumList 发送返回 "Count/TotalSize 头 + 定长记录" 的枚举 IOCTL。
+// 驱动报告缓冲区不足、TotalSize 超出返回长度、Count 多于返回的记录或缓冲区被写满时，按需扩大缓冲区重试；
+// 到 maxSize 仍放不下时连同已返回的数据一起返回包装了 errDriverListTruncated 的错误。
+func ioctlEnumList(dev driver.Device, code uint32, inBuf []byte, size, maxSize uint32, entrySize int) ([]byte, error) {
+	const headerSize = 8
+	for {
+		outBuf, err := dev.IoControl(code, inBuf, size)
+		var want uint64
+		if err != nil {
+			if !errors.Is(err, syscall.ERROR_MORE_DATA) && !errors.Is(err, syscall.ERROR_INSUFFICIENT_BUFFER) {
+				return nil, err
+			}
+			outBuf = nil
+		} else {
+			if len(outBuf) < headerSize {
+				return outBuf, nil
+			}
+			count := binary.LittleEndian.Uint32(outBuf[0:4])
+			total := binary.LittleEndian.Uint32(outBuf[4:8])
+			want = max(uint64(total), headerSize+uint64(count)*uint64(entrySize))
+			if want <= uint64(len(outBuf)) && uint64(len(outBuf))+uint64(entrySize) <= uint64(size) {
+				return outBuf, nil
+			}
+		}
+		if size >= maxSize {
+			return outBuf, fmt.Errorf("%w: 缓冲区已达上限 %d MB", errDriverListTruncated, maxSize>>20)
+		}
+		// 两次调用之间可能新增记录，按需求量再留 1/4 余量
+		size = uint32(min(max(uint64(size)*2, want+want/4), uint64(maxSize)))
+	}
+}
+
 // HandleEntryModel 句柄明细。
 type HandleEntryModel struct {
 	ProcessId       uint32 `json:"process_id"`
 	Handle          uint64 `json:"handle"`
 	ObjectTypeIndex uint32 `json:"object_type_index"`
 	GrantedAccess   uint32 `json:"granted_access"`
+	Access          string `json:"access"`
 	ObjectAddress   uint64 `json:"object_address"`
 	TypeName        string `json:"type_name"`
 	ObjectName      string `json:"object_name"`
+	DosPath         string `json:"dos_path,omitempty"`
 }
 
 // ListHandlesArgs 句柄明细请求参数。
 type ListHandlesArgs struct {
-	ProcessId uint32 `json:"process_id"`
+	ProcessId uint32     `json:"process_id"`
+	Query     *QuerySpec `json:"query,omitempty"`
 }
 
 // ListHandlesReply 句柄明细响应。
 type ListHandlesReply struct {
 	ProcessId uint32             `json:"process_id"`
 	Handles   []HandleEntryModel `json:"handles"`
+	Page      *QueryPageModel    `json:"page"`
 }
 
 // KernelModuleModel 内核模块信息。
@@ -51,26 +95,36 @@ type KernelModuleModel struct {
 }
 
 // EnumKernelModulesArgs 内核模块枚举请求参数。
-type EnumKernelModulesArgs struct{}
+type EnumKernelModulesArgs struct {
+	Query *QuerySpec `json:"query,omitempty"`
+}
 
 // EnumKernelModulesReply 内核模块枚举响应。
 type EnumKernelModulesReply struct {
 	Modules []KernelModuleModel `json:"modules"`
+	Page    *QueryPageModel     `json:"page"`
 }
 
 // FreezeProcessArgs 冻结进程请求参数。
+// AutoRevertAfterMs 非 0 时到期自动执行逆操作，可通过 ExtendRevertLease/CancelRevertLease 续期或取消。
 type FreezeProcessArgs struct {
-	ProcessId uint32 `json:"process_id"`
+	ProcessId         uint32 `json:"process_id"`
+	ProcessKey        string `json:"process_key,omitempty"`
+	AutoRevertAfterMs uint32 `json:"auto_revert_after_ms,omitempty"`
+	Force             bool   `json:"force"`
 }
 
 // FreezeProcessReply 冻结进程响应。
 type FreezeProcessReply struct {
-	Success bool `json:"success"`
+	Success  bool   `json:"success"`
+	LeaseId  int64  `json:"lease_id,omitempty"`
+	RevertAt string `json:"revert_at,omitempty"`
 }
 
 // UnfreezeProcessArgs 解冻进程请求参数。
 type UnfreezeProcessArgs struct {
-	ProcessId uint32 `json:"process_id"`
+	ProcessId  uint32 `json:"process_id"`
+	ProcessKey string `json:"process_key,omitempty"`
 }
 
 // UnfreezeProcessReply 解冻进程响应。
@@ -79,18 +133,25 @@ type UnfreezeProcessReply struct {
 }
 
 // HideProcessArgs 隐藏进程请求参数。
+// AutoRevertAfterMs 非 0 时到期自动执行逆操作，可通过 ExtendRevertLease/CancelRevertLease 续期或取消。
 type HideProcessArgs struct {
-	ProcessId uint32 `json:"process_id"`
+	ProcessId         uint32 `json:"process_id"`
+	ProcessKey        string `json:"process_key,omitempty"`
+	AutoRevertAfterMs uint32 `json:"auto_revert_after_ms,omitempty"`
+	Force             bool   `json:"force"`
 }
 
 // HideProcessReply 隐藏进程响应。
 type HideProcessReply struct {
-	Success bool `json:"success"`
+	Success  bool   `json:"success"`
+	LeaseId  int64  `json:"lease_id,omitempty"`
+	RevertAt string `json:"revert_at,omitempty"`
 }
 
 // UnhideProcessArgs 恢复隐藏进程请求参数。
 type UnhideProcessArgs struct {
-	ProcessId uint32 `json:"process_id"`
+	ProcessId  uint32 `json:"process_id"`
+	ProcessKey string `json:"process_key,omitempty"`
 }
 
 // UnhideProcessReply 恢复隐藏进程响应。
@@ -100,8 +161,9 @@ type UnhideProcessReply struct {
 
 // InjectDllArgs DLL 注入请求参数。
 type InjectDllArgs struct {
-	ProcessId uint32 `json:"process_id"`
-	DllPath   string `json:"dll_path"`
+	ProcessId  uint32 `json:"process_id"`
+	ProcessKey string `json:"process_key,omitempty"`
+	DllPath    string `json:"dll_path"`
 }
 
 // InjectDllReply DLL 注入响应。
@@ -111,23 +173,29 @@ type InjectDllReply struct {
 
 // CloseHandleArgs 强制关闭句柄请求参数。
 type CloseHandleArgs struct {
-	ProcessId uint32 `json:"process_id"`
-	Handle    uint64 `json:"handle"`
+	ProcessId    uint32 `json:"process_id"`
+	Handle       uint64 `json:"handle"`
+	DryRun       bool   `json:"dry_run"`
+	ConfirmToken string `json:"confirm_token"`
 }
 
 // CloseHandleReply 强制关闭句柄响应。
 type CloseHandleReply struct {
-	Success bool `json:"success"`
+	Success bool              `json:"success"`
+	Plan    *ConfirmPlanModel `json:"plan,omitempty"`
 }
 
 // UnloadDriverArgs 卸载驱动请求参数。
 type UnloadDriverArgs struct {
-	ServiceName string `json:"service_name"`
+	ServiceName  string `json:"service_name"`
+	DryRun       bool   `json:"dry_run"`
+	ConfirmToken string `json:"confirm_token"`
 }
 
 // UnloadDriverReply 卸载驱动响应。
 type UnloadDriverReply struct {
-	Success bool `json:"success"`
+	Success bool              `json:"success"`
+	Plan    *ConfirmPlanModel `json:"plan,omitempty"`
 }
 
 func encodeBinary(v any) ([]byte, error) {
@@ -165,12 +233,39 @@ func parseDriverProcessList(outBuf []byte) ([]ProcessInfoModel, error) {
 		return nil, err
 	}
 
-	entrySize := binary.Size(driver.ProcessInfo{})
+	if header.Count == 0 {
+		return make([]ProcessInfoModel, 0), nil
+	}
+	// 记录长度取自 TotalSize / Count：新版驱动每条记录末尾带 CreateTime，旧版没有；两者都不是则拒绝解析，
+	// 避免按错误的步长把后续记录读成乱码
+	fullSize := binary.Size(driver.ProcessInfo{})
+	entrySize := 0
+	// TotalSize 通常含头部；也兼容只统计记录部分的驱动
+	totals := []uint32{header.TotalSize}
+	if header.TotalSize >= uint32(headerSize) {
+		totals = []uint32{header.TotalSize - uint32(headerSize), header.TotalSize}
+	}
+	for _, total := range totals {
+		if total%header.Count != 0 {
+			continue
+		}
+		if size := int(total / header.Count); size == fullSize || size == driver.ProcessInfoLegacySize {
+			entrySize = size
+			break
+		}
+	}
+	if entrySize == 0 {
+		return nil, fmt.Errorf("无法识别驱动进程记录长度（count=%d total_size=%d，应为每条 %d 或 %d 字节），请确认驱动版本",
+			header.Count, header.TotalSize, fullSize, driver.ProcessInfoLegacySize)
+	}
+	record := make([]byte, fullSize)
 	offset := headerSize
 	processes := make([]ProcessInfoModel, 0, header.Count)
 	for i := uint32(0); i < header.Count && offset+entrySize <= len(outBuf); i++ {
+		clear(record)
+		copy(record, outBuf[offset:offset+entrySize])
 		var info driver.ProcessInfo
-		if err := binary.Read(bytes.NewReader(outBuf[offset:offset+entrySize]), binary.LittleEndian, &info); err != nil {
+		if err := binary.Read(bytes.NewReader(record), binary.LittleEndian, &info); err != nil {
 			return nil, err
 		}
 		processes = append(processes, ProcessInfoModel{
@@ -179,18 +274,24 @@ func parseDriverProcessList(outBuf []byte) ([]ProcessInfoModel, error) {
 			ThreadCount:     info.ThreadCount,
 			WorkingSetSize:  info.WorkingSetSize,
 			ImageName:       decodeUTF16Fixed(info.ImageName[:]),
+			CreateTime:      info.CreateTime,
 		})
 		offset += entrySize
 	}
 	return processes, nil
 }
 
-func processNameMapViaDriver(dev driver.Device) (map[uint32]string, error) {
+// enumProcessesViaDriver 取驱动进程列表，不补充用户态信息。
+func enumProcessesViaDriver(dev driver.Device) ([]ProcessInfoModel, error) {
 	outBuf, err := dev.IoControl(driver.IOCTL_ENUM_PROCESSES, nil, 1024*1024)
 	if err != nil {
 		return nil, err
 	}
-	processes, err := parseDriverProcessList(outBuf)
+	return parseDriverProcessList(outBuf)
+}
+
+func processNameMapViaDriver(dev driver.Device) (map[uint32]string, error) {
+	processes, err := enumProcessesViaDriver(dev)
 	if err != nil {
 		return nil, err
 	}
@@ -249,16 +350,20 @@ func enumProcessModulesViaDriver(dev driver.Device, pid uint32) ([]ProcessModule
 	return modules, nil
 }
 
+// enumThreadsViaDriver 经驱动枚举线程，pid 为 0 时为全系统。
+// 线程表在缓冲区上限内仍放不下时返回已解析的部分与包装了 errDriverListTruncated 的错误。
 func enumThreadsViaDriver(dev driver.Device, pid uint32) ([]ThreadInfoModel, error) {
 	inBuf, err := encodeBinary(driver.ProcessRequest{ProcessId: pid})
 	if err != nil {
 		return nil, fmt.Errorf("构造请求失败: %w", err)
 	}
 
-	outBuf, err := dev.IoControl(driver.IOCTL_ENUM_THREADS, inBuf, driverEnumThreadsOutSize)
-	if err != nil {
+	entrySize := binary.Size(driver.ThreadInfo{})
+	outBuf, err := ioctlEnumList(dev, driver.IOCTL_ENUM_THREADS, inBuf, driverEnumThreadsOutSize, driverEnumThreadsMaxOutSize, entrySize)
+	if err != nil && (!errors.Is(err, errDriverListTruncated) || outBuf == nil) {
 		return nil, err
 	}
+	truncErr := err
 
 	headerSize := binary.Size(driver.ThreadListHeader{})
 	if len(outBuf) < headerSize {
@@ -270,7 +375,6 @@ func enumThreadsViaDriver(dev driver.Device, pid uint32) ([]ThreadInfoModel, err
 		return nil, err
 	}
 
-	entrySize := binary.Size(driver.ThreadInfo{})
 	offset := headerSize
 	threads := make([]ThreadInfoModel, 0, header.Count)
 	for i := uint32(0); i < header.Count && offset+entrySize <= len(outBuf); i++ {
@@ -290,19 +394,29 @@ func enumThreadsViaDriver(dev driver.Device, pid uint32) ([]ThreadInfoModel, err
 	}
 
 	sort.SliceStable(threads, func(i, j int) bool { return threads[i].ThreadId < threads[j].ThreadId })
+	if truncErr == nil && uint32(len(threads)) < header.Count {
+		truncErr = errDriverListTruncated
+	}
+	if truncErr != nil {
+		return threads, fmt.Errorf("线程表不完整（已解析 %d 项，驱动报告 %d 项）: %w", len(threads), header.Count, truncErr)
+	}
 	return threads, nil
 }
 
+// listHandlesViaDriver 经驱动枚举句柄，pid 为 0 时为全系统。
+// 句柄表在缓冲区上限内仍放不下时返回已解析的部分与包装了 errDriverListTruncated 的错误。
 func listHandlesViaDriver(dev driver.Device, pid uint32) ([]HandleEntryModel, error) {
 	inBuf, err := encodeBinary(driver.HandleEnumRequest{ProcessId: pid})
 	if err != nil {
 		return nil, fmt.Errorf("构造请求失败: %w", err)
 	}
 
-	outBuf, err := dev.IoControl(driver.IOCTL_ENUM_HANDLES, inBuf, driverEnumHandlesOutSize)
-	if err != nil {
+	entrySize := binary.Size(driver.HandleInfo{})
+	outBuf, err := ioctlEnumList(dev, driver.IOCTL_ENUM_HANDLES, inBuf, driverEnumHandlesOutSize, driverEnumHandlesMaxOutSize, entrySize)
+	if err != nil && (!errors.Is(err, errDriverListTruncated) || outBuf == nil) {
 		return nil, err
 	}
+	truncErr := err
 
 	headerSize := binary.Size(driver.HandleListHeader{})
 	if len(outBuf) < headerSize {
@@ -314,7 +428,6 @@ func listHandlesViaDriver(dev driver.Device, pid uint32) ([]HandleEntryModel, er
 		return nil, err
 	}
 
-	entrySize := binary.Size(driver.HandleInfo{})
 	offset := headerSize
 	handles := make([]HandleEntryModel, 0, header.Count)
 	for i := uint32(0); i < header.Count && offset+entrySize <= len(outBuf); i++ {
@@ -322,14 +435,22 @@ func listHandlesViaDriver(dev driver.Device, pid uint32) ([]HandleEntryModel, er
 		if err := binary.Read(bytes.NewReader(outBuf[offset:offset+entrySize]), binary.LittleEndian, &info); err != nil {
 			return nil, err
 		}
+		typeName := decodeUTF16Fixed(info.TypeName[:])
+		objectName := decodeUTF16Fixed(info.ObjectName[:])
+		dosPath := ""
+		if strings.EqualFold(typeName, "File") {
+			dosPath = ntPathToDos(objectName)
+		}
 		handles = append(handles, HandleEntryModel{
 			ProcessId:       info.ProcessId,
 			Handle:          info.Handle,
 			ObjectTypeIndex: info.ObjectTypeIndex,
 			GrantedAccess:   info.GrantedAccess,
+			Access:          formatAccessMask(typeName, info.GrantedAccess),
 			ObjectAddress:   info.ObjectAddress,
-			TypeName:        decodeUTF16Fixed(info.TypeName[:]),
-			ObjectName:      decodeUTF16Fixed(info.ObjectName[:]),
+			TypeName:        typeName,
+			ObjectName:      objectName,
+			DosPath:         dosPath,
 		})
 		offset += entrySize
 	}
@@ -340,6 +461,13 @@ func listHandlesViaDriver(dev driver.Device, pid uint32) ([]HandleEntryModel, er
 		}
 		return handles[i].Handle < handles[j].Handle
 	})
+	if truncErr == nil && uint32(len(handles)) < header.Count {
+		truncErr = errDriverListTruncated
+	}
+	if truncErr != nil {
+		// 返回已解析的部分，供能够容忍不完整视图的调用方（如隐藏进程检测）标记后使用
+		return handles, fmt.Errorf("句柄表不完整（已解析 %d 项，驱动报告 %d 项）: %w", len(handles), header.Count, truncErr)
+	}
 	return handles, nil
 }
 
@@ -372,6 +500,36 @@ func buildHandleStats(entries []HandleEntryModel) (uint32, []HandleTypeStat) {
 	return uint32(len(entries)), stats
 }
 
+// closeHandleViaDriver 通过驱动关闭目标进程中的句柄。
+func closeHandleViaDriver(dev driver.Device, pid uint32, handle uint64) error {
+	inBuf, err := encodeBinary(driver.CloseHandleRequest{ProcessId: pid, Handle: handle})
+	if err != nil {
+		return fmt.Errorf("构造请求失败: %w", err)
+	}
+	_, err = dev.IoControl(driver.IOCTL_CLOSE_HANDLE, inBuf, 0)
+	return err
+}
+
+// deleteFileViaDriver 通过驱动删除文件（或空目录），path 可为 Win32 路径。
+func deleteFileViaDriver(dev driver.Device, path string) error {
+	utf16Path, err := syscall.UTF16FromString(normalizeKernelPath(path))
+	if err != nil {
+		return fmt.Errorf("路径编码失败: %w", err)
+	}
+	var req driver.FilePathRequest
+	if len(utf16Path) > len(req.Path) {
+		return fmt.Errorf("路径过长，最大支持 %d UTF-16 字符", len(req.Path)-1)
+	}
+	copy(req.Path[:], utf16Path)
+
+	inBuf, err := encodeBinary(req)
+	if err != nil {
+		return fmt.Errorf("构造请求失败: %w", err)
+	}
+	_, err = dev.IoControl(driver.IOCTL_DELETE_FILE, inBuf, 0)
+	return err
+}
+
 func enumNetworkConnectionsViaDriver(dev driver.Device, protocol string) ([]NetworkConnectionModel, error) {
 	outBuf, err := dev.IoControl(driver.IOCTL_ENUM_CONNECTIONS, nil, driverEnumConnectionsOutSize)
 	if err != nil {
@@ -502,6 +660,18 @@ func (t *ToolkitService) FreezeProcess(args *FreezeProcessArgs, reply *FreezePro
 		auditWrite("freeze_process", map[string]any{"process_id": args.ProcessId}, err)
 		return err
 	}
+	if err := t.verifyProcessKey(args.ProcessId, args.ProcessKey); err != nil {
+		auditWrite("freeze_process", map[string]any{"process_id": args.ProcessId, "process_key": args.ProcessKey}, err)
+		return err
+	}
+	if err := validateAutoRevertMs(args.AutoRevertAfterMs); err != nil {
+		auditWrite("freeze_process", map[string]any{"process_id": args.ProcessId, "auto_revert_after_ms": args.AutoRevertAfterMs}, err)
+		return err
+	}
+	if err := t.guardTarget("freeze_process", args.ProcessId, "", args.Force); err != nil {
+		auditWrite("freeze_process", map[string]any{"process_id": args.ProcessId}, err)
+		return err
+	}
 
 	inBuf, err := encodeBinary(driver.ProcessRequest{ProcessId: args.ProcessId})
 	if err != nil {
@@ -515,16 +685,47 @@ func (t *ToolkitService) FreezeProcess(args *FreezeProcessArgs, reply *FreezePro
 	}
 
 	reply.Success = true
-	auditWrite("freeze_process", map[string]any{"process_id": args.ProcessId}, nil)
+	globalStateLedger.record(StateKindFrozen, args.ProcessId, t.currentProcessKey(args.ProcessId), "running", "frozen")
+	params := map[string]any{"process_id": args.ProcessId}
+	if args.AutoRevertAfterMs > 0 {
+		leaseID, revertAt := globalRevertLeases.schedule(t, StateKindFrozen, args.ProcessId, time.Duration(args.AutoRevertAfterMs)*time.Millisecond)
+		reply.LeaseId = leaseID
+		reply.RevertAt = revertAt.Format(time.RFC3339)
+		params["auto_revert_after_ms"] = args.AutoRevertAfterMs
+		params["lease_id"] = leaseID
+	}
+	pid := args.ProcessId
+	key := t.currentProcessKey(pid)
+	journalPush("freeze_process", map[string]any{"process_id": pid, "process_key": key}, "unfreeze_process", func(t *ToolkitService) error {
+		return t.UnfreezeProcess(&UnfreezeProcessArgs{ProcessId: pid, ProcessKey: key}, &UnfreezeProcessReply{})
+	})
+	auditWrite("freeze_process", params, nil)
 	return nil
 }
 
 func (t *ToolkitService) UnfreezeProcess(args *UnfreezeProcessArgs, reply *UnfreezeProcessReply) error {
+	if err := t.unfreezeProcess(args, reply); err != nil {
+		return err
+	}
+	pid := args.ProcessId
+	key := t.currentProcessKey(pid)
+	journalPush("unfreeze_process", map[string]any{"process_id": pid, "process_key": key}, "freeze_process", func(t *ToolkitService) error {
+		return t.FreezeProcess(&FreezeProcessArgs{ProcessId: pid, ProcessKey: key}, &FreezeProcessReply{})
+	})
+	return nil
+}
+
+// unfreezeProcess 执行解冻并清理台账，不登记撤销日志；租约到期与批量恢复直接调用它。
+func (t *ToolkitService) unfreezeProcess(args *UnfreezeProcessArgs, reply *UnfreezeProcessReply) error {
 	if t.Driver == nil {
 		err := fmt.Errorf("驱动未加载")
 		auditWrite("unfreeze_process", map[string]any{"process_id": args.ProcessId}, err)
 		return err
 	}
+	if err := t.verifyProcessKey(args.ProcessId, args.ProcessKey); err != nil {
+		auditWrite("unfreeze_process", map[string]any{"process_id": args.ProcessId, "process_key": args.ProcessKey}, err)
+		return err
+	}
 
 	inBuf, err := encodeBinary(driver.ProcessRequest{ProcessId: args.ProcessId})
 	if err != nil {
@@ -538,6 +739,7 @@ func (t *ToolkitService) UnfreezeProcess(args *UnfreezeProcessArgs, reply *Unfre
 	}
 
 	reply.Success = true
+	globalStateLedger.clear(StateKindFrozen, args.ProcessId)
 	auditWrite("unfreeze_process", map[string]any{"process_id": args.ProcessId}, nil)
 	return nil
 }
@@ -548,6 +750,18 @@ func (t *ToolkitService) HideProcess(args *HideProcessArgs, reply *HideProcessRe
 		auditWrite("hide_process", map[string]any{"process_id": args.ProcessId}, err)
 		return err
 	}
+	if err := t.verifyProcessKey(args.ProcessId, args.ProcessKey); err != nil {
+		auditWrite("hide_process", map[string]any{"process_id": args.ProcessId, "process_key": args.ProcessKey}, err)
+		return err
+	}
+	if err := validateAutoRevertMs(args.AutoRevertAfterMs); err != nil {
+		auditWrite("hide_process", map[string]any{"process_id": args.ProcessId, "auto_revert_after_ms": args.AutoRevertAfterMs}, err)
+		return err
+	}
+	if err := t.guardTarget("hide_process", args.ProcessId, "", args.Force); err != nil {
+		auditWrite("hide_process", map[string]any{"process_id": args.ProcessId}, err)
+		return err
+	}
 
 	inBuf, err := encodeBinary(driver.ProcessRequest{ProcessId: args.ProcessId})
 	if err != nil {
@@ -561,16 +775,47 @@ func (t *ToolkitService) HideProcess(args *HideProcessArgs, reply *HideProcessRe
 	}
 
 	reply.Success = true
-	auditWrite("hide_process", map[string]any{"process_id": args.ProcessId}, nil)
+	globalStateLedger.record(StateKindHidden, args.ProcessId, t.currentProcessKey(args.ProcessId), "visible", "hidden")
+	params := map[string]any{"process_id": args.ProcessId}
+	if args.AutoRevertAfterMs > 0 {
+		leaseID, revertAt := globalRevertLeases.schedule(t, StateKindHidden, args.ProcessId, time.Duration(args.AutoRevertAfterMs)*time.Millisecond)
+		reply.LeaseId = leaseID
+		reply.RevertAt = revertAt.Format(time.RFC3339)
+		params["auto_revert_after_ms"] = args.AutoRevertAfterMs
+		params["lease_id"] = leaseID
+	}
+	pid := args.ProcessId
+	key := t.currentProcessKey(pid)
+	journalPush("hide_process", map[string]any{"process_id": pid, "process_key": key}, "unhide_process", func(t *ToolkitService) error {
+		return t.UnhideProcess(&UnhideProcessArgs{ProcessId: pid, ProcessKey: key}, &UnhideProcessReply{})
+	})
+	auditWrite("hide_process", params, nil)
 	return nil
 }
 
 func (t *ToolkitService) UnhideProcess(args *UnhideProcessArgs, reply *UnhideProcessReply) error {
+	if err := t.unhideProcess(args, reply); err != nil {
+		return err
+	}
+	pid := args.ProcessId
+	key := t.currentProcessKey(pid)
+	journalPush("unhide_process", map[string]any{"process_id": pid, "process_key": key}, "hide_process", func(t *ToolkitService) error {
+		return t.HideProcess(&HideProcessArgs{ProcessId: pid, ProcessKey: key}, &HideProcessReply{})
+	})
+	return nil
+}
+
+// unhideProcess 执行取消隐藏并清理台账，不登记撤销日志。
+func (t *ToolkitService) unhideProcess(args *UnhideProcessArgs, reply *UnhideProcessReply) error {
 	if t.Driver == nil {
 		err := fmt.Errorf("驱动未加载")
 		auditWrite("unhide_process", map[string]any{"process_id": args.ProcessId}, err)
 		return err
 	}
+	if err := t.verifyProcessKey(args.ProcessId, args.ProcessKey); err != nil {
+		auditWrite("unhide_process", map[string]any{"process_id": args.ProcessId, "process_key": args.ProcessKey}, err)
+		return err
+	}
 
 	inBuf, err := encodeBinary(driver.ProcessRequest{ProcessId: args.ProcessId})
 	if err != nil {
@@ -584,6 +829,7 @@ func (t *ToolkitService) UnhideProcess(args *UnhideProcessArgs, reply *UnhidePro
 	}
 
 	reply.Success = true
+	globalStateLedger.clear(StateKindHidden, args.ProcessId)
 	auditWrite("unhide_process", map[string]any{"process_id": args.ProcessId}, nil)
 	return nil
 }
@@ -594,6 +840,10 @@ func (t *ToolkitService) InjectDll(args *InjectDllArgs, reply *InjectDllReply) e
 		auditWrite("inject_dll", map[string]any{"process_id": args.ProcessId, "dll_path": args.DllPath}, err)
 		return err
 	}
+	if err := t.verifyProcessKey(args.ProcessId, args.ProcessKey); err != nil {
+		auditWrite("inject_dll", map[string]any{"process_id": args.ProcessId, "process_key": args.ProcessKey}, err)
+		return err
+	}
 	if strings.TrimSpace(args.DllPath) == "" {
 		err := fmt.Errorf("dll_path 不能为空")
 		auditWrite("inject_dll", map[string]any{"process_id": args.ProcessId, "dll_path": args.DllPath}, err)
@@ -635,14 +885,21 @@ func (t *ToolkitService) ListHandles(args *ListHandlesArgs, reply *ListHandlesRe
 		auditWrite("list_handles", map[string]any{"process_id": args.ProcessId}, retErr)
 		return retErr
 	}
+	total := len(handles)
+	handles, page, err := applyQuery(handles, args.Query)
+	if err != nil {
+		auditWrite("list_handles", map[string]any{"process_id": args.ProcessId}, err)
+		return err
+	}
 
 	reply.ProcessId = args.ProcessId
 	reply.Handles = handles
-	auditWrite("list_handles", map[string]any{"process_id": args.ProcessId, "count": len(handles)}, nil)
+	reply.Page = page
+	auditWrite("list_handles", map[string]any{"process_id": args.ProcessId, "count": total}, nil)
 	return nil
 }
 
-func (t *ToolkitService) EnumKernelModules(_ *EnumKernelModulesArgs, reply *EnumKernelModulesReply) error {
+func (t *ToolkitService) EnumKernelModules(args *EnumKernelModulesArgs, reply *EnumKernelModulesReply) error {
 	if t.Driver == nil {
 		err := fmt.Errorf("驱动未加载")
 		auditWrite("enum_kernel_modules", nil, err)
@@ -655,9 +912,16 @@ func (t *ToolkitService) EnumKernelModules(_ *EnumKernelModulesArgs, reply *Enum
 		auditWrite("enum_kernel_modules", nil, retErr)
 		return retErr
 	}
+	total := len(modules)
+	modules, page, err := applyQuery(modules, args.Query)
+	if err != nil {
+		auditWrite("enum_kernel_modules", nil, err)
+		return err
+	}
 
 	reply.Modules = modules
-	auditWrite("enum_kernel_modules", map[string]any{"count": len(modules)}, nil)
+	reply.Page = page
+	auditWrite("enum_kernel_modules", map[string]any{"count": total}, nil)
 	return nil
 }
 
@@ -668,11 +932,23 @@ func (t *ToolkitService) CloseHandle(args *CloseHandleArgs, reply *CloseHandleRe
 		return err
 	}
 
-	inBuf, err := encodeBinary(driver.CloseHandleRequest{ProcessId: args.ProcessId, Handle: args.Handle})
+	target, err := t.handlePlanTarget(args.ProcessId, args.Handle)
 	if err != nil {
-		return fmt.Errorf("构造请求失败: %w", err)
+		auditWrite("close_handle", map[string]any{"process_id": args.ProcessId, "handle": args.Handle}, err)
+		return err
 	}
-	if _, err = t.Driver.IoControl(driver.IOCTL_CLOSE_HANDLE, inBuf, 0); err != nil {
+	plan, _, err := confirmGate("close_handle", fmt.Sprintf("%d|%d", args.ProcessId, args.Handle), args.DryRun, args.ConfirmToken, []PlanTargetModel{target})
+	if err != nil {
+		auditWrite("close_handle", map[string]any{"process_id": args.ProcessId, "handle": args.Handle}, err)
+		return err
+	}
+	if plan != nil {
+		reply.Plan = plan
+		auditWrite("close_handle", map[string]any{"process_id": args.ProcessId, "handle": args.Handle, "dry_run": true}, nil)
+		return nil
+	}
+
+	if err = closeHandleViaDriver(t.Driver, args.ProcessId, args.Handle); err != nil {
 		reply.Success = false
 		retErr := fmt.Errorf("关闭句柄失败: %w", err)
 		auditWrite("close_handle", map[string]any{"process_id": args.ProcessId, "handle": args.Handle}, retErr)
@@ -701,6 +977,19 @@ func (t *ToolkitService) UnloadDriver(args *UnloadDriverArgs, reply *UnloadDrive
 		return err
 	}
 
+	imagePath, _ := queryServiceBinaryPath(args.ServiceName)
+	target := PlanTargetModel{Kind: "driver", ServiceName: args.ServiceName, ImagePath: imagePath}
+	plan, _, err := confirmGate("unload_driver", args.ServiceName, args.DryRun, args.ConfirmToken, []PlanTargetModel{target})
+	if err != nil {
+		auditWrite("unload_driver", map[string]any{"service_name": args.ServiceName}, err)
+		return err
+	}
+	if plan != nil {
+		reply.Plan = plan
+		auditWrite("unload_driver", map[string]any{"service_name": args.ServiceName, "dry_run": true}, nil)
+		return nil
+	}
+
 	inBuf, err := encodeBinary(req)
 	if err != nil {
 		return fmt.Errorf("构造请求失败: %w", err)
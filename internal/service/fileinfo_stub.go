@@ -0,0 +1,28 @@
+//go:build !windows
+
+package service
+
+import (
+	"fmt"
+	"io/fs"
+)
+
+func queryFileExtInfo(_ string, _ fs.FileInfo) fileExtInfo {
+	return fileExtInfo{}
+}
+
+func fileOwner(_ string) (string, error) {
+	return "", fmt.Errorf("仅支持 Windows")
+}
+
+func fileSecuritySDDL(_ string) (string, error) {
+	return "", fmt.Errorf("仅支持 Windows")
+}
+
+func applyFileSecuritySDDL(_ string, _ string) error {
+	return fmt.Errorf("仅支持 Windows")
+}
+
+func restrictDirToAdmins(_ string) error {
+	return nil
+}
@@ -0,0 +1,107 @@
+//go:build windows
+
+package service
+
+import (
+	"io/fs"
+	"os"
+	"syscall"
+	"time"
+
+	"golang.org/x/sys/windows"
+)
+
+// queryFileExtInfo 从目录枚举得到的 FileInfo 中取属性与时间，重解析点额外读取标记与目标。
+func queryFileExtInfo(path string, info fs.FileInfo) fileExtInfo {
+	var ext fileExtInfo
+	data, ok := info.Sys().(*syscall.Win32FileAttributeData)
+	if !ok {
+		return ext
+	}
+	ext.attributes = data.FileAttributes
+	ext.createTime = time.Unix(0, data.CreationTime.Nanoseconds())
+	ext.accessTime = time.Unix(0, data.LastAccessTime.Nanoseconds())
+	if ext.attributes&windows.FILE_ATTRIBUTE_REPARSE_POINT == 0 {
+		return ext
+	}
+
+	// 重解析标记只能从 FindFirstFile 的 dwReserved0 取得
+	namePtr, err := windows.UTF16PtrFromString(path)
+	if err != nil {
+		return ext
+	}
+	var fd windows.Win32finddata
+	h, err := windows.FindFirstFile(namePtr, &fd)
+	if err != nil {
+		return ext
+	}
+	windows.FindClose(h)
+	switch fd.Reserved0 {
+	case windows.IO_REPARSE_TAG_SYMLINK:
+		ext.linkType = "symlink"
+	case windows.IO_REPARSE_TAG_MOUNT_POINT:
+		ext.linkType = "junction"
+	default:
+		ext.linkType = "reparse"
+		return ext
+	}
+	if target, err := os.Readlink(path); err == nil {
+		ext.linkTarget = target
+	}
+	return ext
+}
+
+// fileOwner 返回文件或目录所有者的 "域\账户"。
+func fileOwner(path string) (string, error) {
+	sd, err := windows.GetNamedSecurityInfo(path, windows.SE_FILE_OBJECT, windows.OWNER_SECURITY_INFORMATION)
+	if err != nil {
+		return "", err
+	}
+	owner, _, err := sd.Owner()
+	if err != nil {
+		return "", err
+	}
+	return sidAccountName(owner), nil
+}
+
+// fileSecuritySDDL 以 SDDL 形式读取文件的所有者、主组与 DACL。
+func fileSecuritySDDL(path string) (string, error) {
+	sd, err := windows.GetNamedSecurityInfo(path, windows.SE_FILE_OBJECT,
+		windows.OWNER_SECURITY_INFORMATION|windows.GROUP_SECURITY_INFORMATION|windows.DACL_SECURITY_INFORMATION)
+	if err != nil {
+		return "", err
+	}
+	return sd.String(), nil
+}
+
+// applyFileSecuritySDDL 把 SDDL 中的 DACL（以及可设置时的所有者、主组）写回文件。
+// 设置所有者需要 SeRestorePrivilege，失败时退回只写 DACL。
+func applyFileSecuritySDDL(path string, sddl string) error {
+	sd, err := windows.SecurityDescriptorFromString(sddl)
+	if err != nil {
+		return err
+	}
+	dacl, _, err := sd.DACL()
+	if err != nil {
+		return err
+	}
+	info := windows.SECURITY_INFORMATION(windows.DACL_SECURITY_INFORMATION)
+	if control, _, err := sd.Control(); err == nil && control&windows.SE_DACL_PROTECTED != 0 {
+		info |= windows.PROTECTED_DACL_SECURITY_INFORMATION
+	}
+	owner, _, _ := sd.Owner()
+	group, _, _ := sd.Group()
+	if owner != nil && group != nil {
+		err := windows.SetNamedSecurityInfo(path, windows.SE_FILE_OBJECT,
+			info|windows.OWNER_SECURITY_INFORMATION|windows.GROUP_SECURITY_INFORMATION, owner, group, dacl, nil)
+		if err == nil {
+			return nil
+		}
+	}
+	return windows.SetNamedSecurityInfo(path, windows.SE_FILE_OBJECT, info, nil, nil, dacl, nil)
+}
+
+// restrictDirToAdmins 把目录 DACL 设为仅 SYSTEM 与 Administrators 可访问，并断开继承。
+func restrictDirToAdmins(dir string) error {
+	return applyFileSecuritySDDL(dir, "D:P(A;OICI;FA;;;SY)(A;OICI;FA;;;BA)")
+}
@@ -0,0 +1,279 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// maxForceDeleteEntries 单次强制删除允许的最大条目数，避免误删整个卷或生成超大计划。
+const maxForceDeleteEntries = 50000
+
+// ForceDeleteTreeArgs 强制删除目录树请求参数。
+// CloseHandles 为 true 时，用户态删除失败的条目会先关闭指向它的文件句柄再重试；
+// Force 作用于关闭句柄时的目标策略检查。
+type ForceDeleteTreeArgs struct {
+	Path         string `json:"path"`
+	CloseHandles bool   `json:"close_handles"`
+	DryRun       bool   `json:"dry_run"`
+	ConfirmToken string `json:"confirm_token"`
+	Force        bool   `json:"force"`
+}
+
+// ForceDeleteEntryResult 单个条目的删除结果。
+// Method 为 user（用户态删除）、user_after_unlock（关闭句柄后用户态删除）或 kernel（驱动删除）。
+type ForceDeleteEntryResult struct {
+	Path          string `json:"path"`
+	IsDir         bool   `json:"is_dir"`
+	Success       bool   `json:"success"`
+	Method        string `json:"method,omitempty"`
+	HandlesClosed int    `json:"handles_closed,omitempty"`
+	Error         string `json:"error,omitempty"`
+}
+
+// ForceDeleteTreeReply 强制删除目录树响应
+type ForceDeleteTreeReply struct {
+	Path    string                   `json:"path"`
+	Total   int                      `json:"total"`
+	Deleted int                      `json:"deleted"`
+	Failed  int                      `json:"failed"`
+	Results []ForceDeleteEntryResult `json:"results"`
+	Plan    *ConfirmPlanModel        `json:"plan,omitempty"`
+}
+
+// canonicalDeletePath 解析联接、符号链接与 8.3 短文件名，得到用于比较的长路径。
+// path 本身是重解析点时只删除链接，因此只解析其所在目录；解析失败时原样返回。
+func canonicalDeletePath(path string) string {
+	if info, err := os.Lstat(path); err == nil &&
+		(info.Mode()&os.ModeSymlink != 0 || queryFileExtInfo(path, info).attributes&fileAttributeReparsePoint != 0) {
+		if dir, err := filepath.EvalSymlinks(filepath.Dir(path)); err == nil {
+			return filepath.Join(dir, filepath.Base(path))
+		}
+		return path
+	}
+	if resolved, err := filepath.EvalSymlinks(path); err == nil {
+		return resolved
+	}
+	return path
+}
+
+// protectedDeleteRoot 不允许整树删除的目录；Subtree 为 true 时其下任何路径也不允许删除。
+type protectedDeleteRoot struct {
+	Path    string
+	Subtree bool
+}
+
+// forceDeleteProtectedRoots 收集 %SystemRoot%、Program Files、ProgramData、用户配置文件根目录与后端所在目录。
+func forceDeleteProtectedRoots() []protectedDeleteRoot {
+	roots := make([]protectedDeleteRoot, 0, 8)
+	add := func(p string, subtree bool) {
+		if p = strings.TrimRight(p, `\`); p != "" {
+			roots = append(roots, protectedDeleteRoot{Path: canonicalDeletePath(filepath.Clean(p)), Subtree: subtree})
+		}
+	}
+	add(os.Getenv("SystemRoot"), true)
+	for _, env := range []string{"ProgramFiles", "ProgramFiles(x86)", "ProgramW6432", "ProgramData"} {
+		add(os.Getenv(env), false)
+	}
+	// 用户配置文件根目录（通常为 C:\Users），以 PUBLIC 的上级为准，缺失时按系统盘推断
+	if pub := os.Getenv("PUBLIC"); pub != "" {
+		add(filepath.Dir(pub), false)
+	} else if drive := os.Getenv("SystemDrive"); drive != "" {
+		add(drive+`\Users`, false)
+	}
+	if exePath, err := os.Executable(); err == nil {
+		add(filepath.Dir(exePath), true)
+	}
+	return roots
+}
+
+// forceDeleteRootAllowed 拒绝盘符根目录、系统目录、用户配置文件根目录、后端自身所在目录及其上级目录，
+// 以及 %SystemRoot% 与后端目录之下的任何路径；比较前先解析联接与短文件名，这类路径即使确认也不应整树删除。
+func forceDeleteRootAllowed(path string) error {
+	if filepath.Dir(path) == path {
+		return fmt.Errorf("不允许删除卷根目录: %s", path)
+	}
+	resolved := canonicalDeletePath(path)
+	if filepath.Dir(resolved) == resolved {
+		return fmt.Errorf("不允许删除卷根目录: %s（解析为 %s）", path, resolved)
+	}
+	p := strings.ToLower(resolved)
+	for _, root := range forceDeleteProtectedRoots() {
+		r := strings.ToLower(root.Path)
+		switch {
+		case p == r, strings.HasPrefix(r, p+`\`):
+			return fmt.Errorf("不允许删除系统目录: %s", path)
+		case root.Subtree && strings.HasPrefix(p, r+`\`):
+			return fmt.Errorf("不允许删除系统目录下的路径: %s（位于 %s 之下）", path, root.Path)
+		}
+	}
+	return nil
+}
+
+// collectForceDeleteEntries 深度优先收集目录树，返回子项在前、父目录在后的删除顺序。
+// 重解析点（符号链接、联接）不跟随，只删除链接本身。
+func collectForceDeleteEntries(root string) ([]PlanTargetModel, []bool, error) {
+	paths := make([]string, 0, 64)
+	dirs := make([]bool, 0, 64)
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if d == nil {
+				return err
+			}
+			// 无法读取的目录已在首次回调时记录，删除时会报告失败
+			return nil
+		}
+		if len(paths) >= maxForceDeleteEntries {
+			return fmt.Errorf("条目过多，最多支持 %d 个", maxForceDeleteEntries)
+		}
+		isDir := d.IsDir()
+		if isDir {
+			if info, infoErr := d.Info(); infoErr == nil && queryFileExtInfo(path, info).attributes&fileAttributeReparsePoint != 0 {
+				isDir = false
+			}
+		}
+		paths = append(paths, path)
+		dirs = append(dirs, isDir)
+		if d.IsDir() && !isDir {
+			return filepath.SkipDir
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// 前序遍历结果倒序即为后序：每个目录都排在其全部子项之后
+	targets := make([]PlanTargetModel, len(paths))
+	isDir := make([]bool, len(paths))
+	for i, p := range paths {
+		j := len(paths) - 1 - i
+		targets[j] = filePlanTarget(p)
+		isDir[j] = dirs[i]
+	}
+	return targets, isDir, nil
+}
+
+// removeUserMode 用户态删除单个条目，遇到只读属性时清除后重试。
+func removeUserMode(path string) error {
+	err := os.Remove(path)
+	if err == nil || errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if chErr := os.Chmod(path, 0o666); chErr == nil {
+		if retry := os.Remove(path); retry == nil {
+			return nil
+		}
+	}
+	return err
+}
+
+// ForceDeleteTree 深度优先删除目录树：逐项先尝试用户态删除，失败时可选关闭占用句柄，再回退到驱动删除，最后删除已清空的目录
+func (t *ToolkitService) ForceDeleteTree(args *ForceDeleteTreeArgs, reply *ForceDeleteTreeReply) error {
+	params := map[string]any{"path": args.Path, "close_handles": args.CloseHandles}
+	if strings.TrimSpace(args.Path) == "" {
+		err := fmt.Errorf("path 不能为空")
+		auditWrite("force_delete_tree", params, err)
+		return err
+	}
+	if args.CloseHandles && t.Driver == nil {
+		err := fmt.Errorf("驱动未加载，无法关闭占用句柄")
+		auditWrite("force_delete_tree", params, err)
+		return err
+	}
+	root, err := win32Path(args.Path)
+	if err != nil {
+		retErr := fmt.Errorf("路径解析失败: %w", err)
+		auditWrite("force_delete_tree", params, retErr)
+		return retErr
+	}
+	if err := forceDeleteRootAllowed(root); err != nil {
+		auditWrite("force_delete_tree", params, err)
+		return err
+	}
+	if _, err := os.Lstat(root); err != nil {
+		retErr := fmt.Errorf("路径不存在或不可访问: %s", root)
+		auditWrite("force_delete_tree", params, retErr)
+		return retErr
+	}
+
+	current, isDir, err := collectForceDeleteEntries(root)
+	if err != nil {
+		retErr := fmt.Errorf("遍历目录失败: %w", err)
+		auditWrite("force_delete_tree", params, retErr)
+		return retErr
+	}
+	dirByPath := make(map[string]bool, len(current))
+	for i, pt := range current {
+		dirByPath[strings.ToLower(pt.Path)] = isDir[i]
+	}
+
+	scope := fmt.Sprintf("%s|%t", strings.ToLower(root), args.CloseHandles)
+	plan, targets, err := confirmGate("force_delete_tree", scope, args.DryRun, args.ConfirmToken, current)
+	if err != nil {
+		auditWrite("force_delete_tree", params, err)
+		return err
+	}
+
+	reply.Path = root
+	reply.Total = len(current)
+	if plan != nil {
+		reply.Results = make([]ForceDeleteEntryResult, 0)
+		reply.Plan = plan
+		params["dry_run"] = true
+		params["total"] = reply.Total
+		auditWrite("force_delete_tree", params, nil)
+		return nil
+	}
+
+	idx := newFileHandleIndex("force_delete_tree", root)
+	reply.Results = make([]ForceDeleteEntryResult, 0, len(targets))
+	for _, pt := range targets {
+		res := ForceDeleteEntryResult{Path: pt.Path, IsDir: dirByPath[strings.ToLower(pt.Path)]}
+		err := removeUserMode(pt.Path)
+		if err == nil {
+			res.Method = "user"
+		}
+		if err != nil && args.CloseHandles {
+			closed, closeErr := idx.closeFor(t, pt.Path, args.Force)
+			res.HandlesClosed = closed
+			if closed > 0 {
+				if retry := removeUserMode(pt.Path); retry == nil {
+					err = nil
+					res.Method = "user_after_unlock"
+				}
+			}
+			if err != nil && closeErr != nil {
+				err = fmt.Errorf("%v; %w", err, closeErr)
+			}
+		}
+		if err != nil && t.Driver != nil {
+			if kerr := deleteFileViaDriver(t.Driver, pt.Path); kerr != nil {
+				err = fmt.Errorf("%v; 内核删除文件失败: %w", err, kerr)
+			} else if _, statErr := os.Lstat(pt.Path); statErr == nil {
+				err = fmt.Errorf("%v; 内核删除后条目仍存在", err)
+			} else {
+				err = nil
+				res.Method = "kernel"
+			}
+		}
+
+		if err != nil {
+			res.Error = err.Error()
+			reply.Failed++
+		} else {
+			res.Success = true
+			reply.Deleted++
+		}
+		reply.Results = append(reply.Results, res)
+	}
+
+	params["total"] = reply.Total
+	params["deleted"] = reply.Deleted
+	params["failed"] = reply.Failed
+	auditWrite("force_delete_tree", params, nil)
+	return nil
+}
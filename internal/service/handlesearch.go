@@ -0,0 +1,148 @@
+package service
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// HandleMatchModel 全局句柄搜索的单条结果。
+type HandleMatchModel struct {
+	ProcessId     uint32 `json:"process_id"`
+	ProcessName   string `json:"process_name"`
+	Handle        uint64 `json:"handle"`
+	TypeName      string `json:"type_name"`
+	ObjectName    string `json:"object_name"`
+	DosPath       string `json:"dos_path,omitempty"`
+	GrantedAccess uint32 `json:"granted_access"`
+	Access        string `json:"access"`
+	ObjectAddress uint64 `json:"object_address"`
+}
+
+// FindHandlesArgs 全局句柄搜索请求参数。
+// Match 为 substring（默认，不区分大小写）/glob（* 与 ?，不区分大小写，整串匹配）/regex（RE2）；
+// Name 同时与对象名及其盘符路径比较，为空时只按 Types 过滤。
+type FindHandlesArgs struct {
+	Name      string     `json:"name"`
+	Match     string     `json:"match"`
+	Types     []string   `json:"types"`
+	ProcessId uint32     `json:"process_id"`
+	Query     *QuerySpec `json:"query,omitempty"`
+}
+
+// FindHandlesReply 全局句柄搜索响应
+type FindHandlesReply struct {
+	Scanned int                `json:"scanned"`
+	Matches []HandleMatchModel `json:"matches"`
+	Page    *QueryPageModel    `json:"page"`
+}
+
+// compileHandleNameMatcher 按匹配方式生成对象名匹配函数。
+func compileHandleNameMatcher(pattern string, mode string) (func(string) bool, error) {
+	switch strings.ToLower(strings.TrimSpace(mode)) {
+	case "", "substring":
+		needle := strings.ToLower(pattern)
+		return func(s string) bool { return strings.Contains(strings.ToLower(s), needle) }, nil
+	case "glob":
+		var b strings.Builder
+		b.WriteString("(?is)^")
+		for _, r := range pattern {
+			switch r {
+			case '*':
+				b.WriteString(".*")
+			case '?':
+				b.WriteString(".")
+			default:
+				b.WriteString(regexp.QuoteMeta(string(r)))
+			}
+		}
+		b.WriteString("$")
+		re := regexp.MustCompile(b.String())
+		return re.MatchString, nil
+	case "regex":
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("name 正则无效: %w", err)
+		}
+		return re.MatchString, nil
+	default:
+		return nil, fmt.Errorf("match 仅支持 substring/glob/regex")
+	}
+}
+
+// FindHandles 通过驱动枚举全系统句柄，按对象名与类型查找持有者（文件、注册表键、互斥体、节等）
+func (t *ToolkitService) FindHandles(args *FindHandlesArgs, reply *FindHandlesReply) error {
+	params := map[string]any{"name": args.Name, "match": args.Match, "types": args.Types, "process_id": args.ProcessId}
+	if t.Driver == nil {
+		err := fmt.Errorf("驱动未加载")
+		auditWrite("find_handles", params, err)
+		return err
+	}
+	if args.Name == "" && len(args.Types) == 0 {
+		err := fmt.Errorf("name 与 types 至少指定一项")
+		auditWrite("find_handles", params, err)
+		return err
+	}
+
+	var match func(string) bool
+	if args.Name != "" {
+		var err error
+		if match, err = compileHandleNameMatcher(args.Name, args.Match); err != nil {
+			auditWrite("find_handles", params, err)
+			return err
+		}
+	}
+	var types map[string]struct{}
+	if len(args.Types) > 0 {
+		types = make(map[string]struct{}, len(args.Types))
+		for _, tn := range args.Types {
+			types[strings.ToLower(strings.TrimSpace(tn))] = struct{}{}
+		}
+	}
+
+	handles, err := listHandlesViaDriver(t.Driver, args.ProcessId)
+	if err != nil {
+		retErr := fmt.Errorf("枚举句柄明细失败: %w", err)
+		auditWrite("find_handles", params, retErr)
+		return retErr
+	}
+	names, _ := processNameMapViaDriver(t.Driver)
+
+	matches := make([]HandleMatchModel, 0, 64)
+	for _, h := range handles {
+		if types != nil {
+			if _, ok := types[strings.ToLower(h.TypeName)]; !ok {
+				continue
+			}
+		}
+		dosPath := h.DosPath
+		if match != nil && !match(h.ObjectName) && (dosPath == "" || !match(dosPath)) {
+			continue
+		}
+		matches = append(matches, HandleMatchModel{
+			ProcessId:     h.ProcessId,
+			ProcessName:   names[h.ProcessId],
+			Handle:        h.Handle,
+			TypeName:      h.TypeName,
+			ObjectName:    h.ObjectName,
+			DosPath:       dosPath,
+			GrantedAccess: h.GrantedAccess,
+			Access:        h.Access,
+			ObjectAddress: h.ObjectAddress,
+		})
+	}
+
+	found := len(matches)
+	matches, page, err := applyQuery(matches, args.Query)
+	if err != nil {
+		auditWrite("find_handles", params, err)
+		return err
+	}
+
+	reply.Scanned = len(handles)
+	reply.Matches = matches
+	reply.Page = page
+	params["found"] = found
+	auditWrite("find_handles", params, nil)
+	return nil
+}
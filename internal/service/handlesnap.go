@@ -0,0 +1,293 @@
+package service
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// maxHandleSnapshots 服务端保留的句柄快照数，全系统快照可达数万条，超出时淘汰最早的一份。
+const maxHandleSnapshots = 8
+
+type handleSnapshot struct {
+	name      string
+	pid       uint32
+	createdAt time.Time
+	handles   []HandleEntryModel
+	names     map[uint32]string
+}
+
+type handleSnapshotStore struct {
+	mu    sync.Mutex
+	items []*handleSnapshot
+}
+
+var (
+	globalHandleSnapshots = &handleSnapshotStore{}
+	handleSnapshotSeq     atomic.Int64
+)
+
+// put 保存快照；同名快照被替换，超出上限时淘汰最早的一份并返回其名称。
+func (s *handleSnapshotStore) put(snap *handleSnapshot) (replaced bool, evicted string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i, it := range s.items {
+		if it.name == snap.name {
+			s.items = append(s.items[:i], s.items[i+1:]...)
+			replaced = true
+			break
+		}
+	}
+	s.items = append(s.items, snap)
+	if len(s.items) > maxHandleSnapshots {
+		evicted = s.items[0].name
+		s.items = s.items[1:]
+	}
+	return replaced, evicted
+}
+
+func (s *handleSnapshotStore) get(name string) *handleSnapshot {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, it := range s.items {
+		if it.name == name {
+			return it
+		}
+	}
+	return nil
+}
+
+// SnapshotHandlesArgs 句柄快照请求参数，ProcessId 为 0 表示全系统，Name 为空时自动生成。
+type SnapshotHandlesArgs struct {
+	Name      string `json:"name"`
+	ProcessId uint32 `json:"process_id"`
+}
+
+// SnapshotHandlesReply 句柄快照响应
+type SnapshotHandlesReply struct {
+	Name      string `json:"name"`
+	ProcessId uint32 `json:"process_id"`
+	CreatedAt string `json:"created_at"`
+	Count     int    `json:"count"`
+	Replaced  bool   `json:"replaced"`
+	Evicted   string `json:"evicted,omitempty"`
+}
+
+// SnapshotHandles 通过驱动枚举句柄并以名称保存在服务端，供 DiffHandles 对比
+func (t *ToolkitService) SnapshotHandles(args *SnapshotHandlesArgs, reply *SnapshotHandlesReply) error {
+	params := map[string]any{"name": args.Name, "process_id": args.ProcessId}
+	if t.Driver == nil {
+		err := fmt.Errorf("驱动未加载")
+		auditWrite("snapshot_handles", params, err)
+		return err
+	}
+
+	handles, err := listHandlesViaDriver(t.Driver, args.ProcessId)
+	if err != nil {
+		retErr := fmt.Errorf("枚举句柄明细失败: %w", err)
+		auditWrite("snapshot_handles", params, retErr)
+		return retErr
+	}
+	names, _ := processNameMapViaDriver(t.Driver)
+
+	name := strings.TrimSpace(args.Name)
+	if name == "" {
+		name = fmt.Sprintf("snap-%d", handleSnapshotSeq.Add(1))
+	}
+	snap := &handleSnapshot{name: name, pid: args.ProcessId, createdAt: time.Now(), handles: handles, names: names}
+	replaced, evicted := globalHandleSnapshots.put(snap)
+
+	reply.Name = name
+	reply.ProcessId = args.ProcessId
+	reply.CreatedAt = snap.createdAt.Format(time.RFC3339Nano)
+	reply.Count = len(handles)
+	reply.Replaced = replaced
+	reply.Evicted = evicted
+	params["name"] = name
+	params["count"] = len(handles)
+	auditWrite("snapshot_handles", params, nil)
+	return nil
+}
+
+// HandleDiffEntryModel 两次快照之间新增或关闭的句柄。
+type HandleDiffEntryModel struct {
+	ProcessId     uint32 `json:"process_id"`
+	ProcessName   string `json:"process_name"`
+	Handle        uint64 `json:"handle"`
+	ObjectAddress uint64 `json:"object_address"`
+	TypeName      string `json:"type_name"`
+	ObjectName    string `json:"object_name"`
+	DosPath       string `json:"dos_path,omitempty"`
+	GrantedAccess uint32 `json:"granted_access"`
+	Access        string `json:"access"`
+}
+
+// HandleStateModel 句柄在某次快照中的可变属性。
+type HandleStateModel struct {
+	GrantedAccess uint32 `json:"granted_access"`
+	Access        string `json:"access"`
+	ObjectName    string `json:"object_name"`
+}
+
+// HandleChangeModel 同一 (pid, handle, object_address) 在两次快照间权限或对象名发生变化。
+type HandleChangeModel struct {
+	ProcessId     uint32           `json:"process_id"`
+	ProcessName   string           `json:"process_name"`
+	Handle        uint64           `json:"handle"`
+	ObjectAddress uint64           `json:"object_address"`
+	TypeName      string           `json:"type_name"`
+	Before        HandleStateModel `json:"before"`
+	After         HandleStateModel `json:"after"`
+}
+
+// DiffHandlesArgs 句柄快照对比请求参数，A 为较早的快照；Types 非空时只对比这些对象类型。
+type DiffHandlesArgs struct {
+	A     string   `json:"a"`
+	B     string   `json:"b"`
+	Types []string `json:"types"`
+}
+
+// DiffHandlesReply 句柄快照对比响应
+type DiffHandlesReply struct {
+	A         string                 `json:"a"`
+	B         string                 `json:"b"`
+	ProcessId uint32                 `json:"process_id"`
+	ElapsedMs int64                  `json:"elapsed_ms"`
+	Opened    []HandleDiffEntryModel `json:"opened"`
+	Closed    []HandleDiffEntryModel `json:"closed"`
+	Changed   []HandleChangeModel    `json:"changed"`
+}
+
+type handleDiffKey struct {
+	pid     uint32
+	handle  uint64
+	address uint64
+}
+
+func newHandleDiffEntry(h HandleEntryModel, names map[uint32]string) HandleDiffEntryModel {
+	return HandleDiffEntryModel{
+		ProcessId:     h.ProcessId,
+		ProcessName:   names[h.ProcessId],
+		Handle:        h.Handle,
+		ObjectAddress: h.ObjectAddress,
+		TypeName:      h.TypeName,
+		ObjectName:    h.ObjectName,
+		DosPath:       h.DosPath,
+		GrantedAccess: h.GrantedAccess,
+		Access:        h.Access,
+	}
+}
+
+// DiffHandles 对比两份句柄快照，按 (pid, handle, object_address) 给出新打开、已关闭与权限/名称变化的句柄
+func (t *ToolkitService) DiffHandles(args *DiffHandlesArgs, reply *DiffHandlesReply) error {
+	params := map[string]any{"a": args.A, "b": args.B, "types": args.Types}
+	if args.A == "" || args.B == "" {
+		err := fmt.Errorf("a 与 b 不能为空")
+		auditWrite("diff_handles", params, err)
+		return err
+	}
+	a := globalHandleSnapshots.get(args.A)
+	b := globalHandleSnapshots.get(args.B)
+	if a == nil || b == nil {
+		missing := args.A
+		if a != nil {
+			missing = args.B
+		}
+		err := fmt.Errorf("句柄快照不存在: %s", missing)
+		auditWrite("diff_handles", params, err)
+		return err
+	}
+
+	// 一份为全系统、另一份为单进程时，按单进程范围对比
+	pid := a.pid
+	if pid == 0 {
+		pid = b.pid
+	}
+	if a.pid != 0 && b.pid != 0 && a.pid != b.pid {
+		err := fmt.Errorf("两份快照的 process_id 不一致: %d / %d", a.pid, b.pid)
+		auditWrite("diff_handles", params, err)
+		return err
+	}
+
+	var types map[string]struct{}
+	if len(args.Types) > 0 {
+		types = make(map[string]struct{}, len(args.Types))
+		for _, tn := range args.Types {
+			types[strings.ToLower(strings.TrimSpace(tn))] = struct{}{}
+		}
+	}
+	index := func(snap *handleSnapshot) map[handleDiffKey]HandleEntryModel {
+		m := make(map[handleDiffKey]HandleEntryModel, len(snap.handles))
+		for _, h := range snap.handles {
+			if pid != 0 && h.ProcessId != pid {
+				continue
+			}
+			if types != nil {
+				if _, ok := types[strings.ToLower(h.TypeName)]; !ok {
+					continue
+				}
+			}
+			m[handleDiffKey{pid: h.ProcessId, handle: h.Handle, address: h.ObjectAddress}] = h
+		}
+		return m
+	}
+	before := index(a)
+	after := index(b)
+
+	reply.Opened = make([]HandleDiffEntryModel, 0)
+	reply.Closed = make([]HandleDiffEntryModel, 0)
+	reply.Changed = make([]HandleChangeModel, 0)
+	for k, h := range after {
+		old, ok := before[k]
+		if !ok {
+			reply.Opened = append(reply.Opened, newHandleDiffEntry(h, b.names))
+			continue
+		}
+		if old.GrantedAccess != h.GrantedAccess || old.ObjectName != h.ObjectName {
+			reply.Changed = append(reply.Changed, HandleChangeModel{
+				ProcessId:     h.ProcessId,
+				ProcessName:   b.names[h.ProcessId],
+				Handle:        h.Handle,
+				ObjectAddress: h.ObjectAddress,
+				TypeName:      h.TypeName,
+				Before:        HandleStateModel{GrantedAccess: old.GrantedAccess, Access: old.Access, ObjectName: old.ObjectName},
+				After:         HandleStateModel{GrantedAccess: h.GrantedAccess, Access: h.Access, ObjectName: h.ObjectName},
+			})
+		}
+	}
+	for k, h := range before {
+		if _, ok := after[k]; !ok {
+			reply.Closed = append(reply.Closed, newHandleDiffEntry(h, a.names))
+		}
+	}
+
+	sortDiff := func(items []HandleDiffEntryModel) {
+		sort.Slice(items, func(i, j int) bool {
+			if items[i].ProcessId != items[j].ProcessId {
+				return items[i].ProcessId < items[j].ProcessId
+			}
+			return items[i].Handle < items[j].Handle
+		})
+	}
+	sortDiff(reply.Opened)
+	sortDiff(reply.Closed)
+	sort.Slice(reply.Changed, func(i, j int) bool {
+		if reply.Changed[i].ProcessId != reply.Changed[j].ProcessId {
+			return reply.Changed[i].ProcessId < reply.Changed[j].ProcessId
+		}
+		return reply.Changed[i].Handle < reply.Changed[j].Handle
+	})
+
+	reply.A = a.name
+	reply.B = b.name
+	reply.ProcessId = pid
+	reply.ElapsedMs = b.createdAt.Sub(a.createdAt).Milliseconds()
+	params["opened"] = len(reply.Opened)
+	params["closed"] = len(reply.Closed)
+	params["changed"] = len(reply.Changed)
+	auditWrite("diff_handles", params, nil)
+	return nil
+}
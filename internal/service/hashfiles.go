@@ -0,0 +1,246 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/OpenSysKit/backend/internal/filehash"
+)
+
+const (
+	defaultHashWorkers = 4
+	maxHashWorkers     = 16
+	maxHashPaths       = 10000
+)
+
+// HashFilesArgs 批量哈希请求参数。
+// Algorithms 可选 md5/sha1/sha256/imphash，默认 sha256；Mode 为 processes 时忽略 Paths，
+// 改为哈希所有运行中进程的映像与已加载模块；Async 为 true 时作为后台任务执行并返回 job_id。
+type HashFilesArgs struct {
+	Paths      []string `json:"paths"`
+	Algorithms []string `json:"algorithms"`
+	Mode       string   `json:"mode"`
+	Workers    int      `json:"workers"`
+	Async      bool     `json:"async"`
+}
+
+// FileHashModel 单个文件的哈希结果。
+type FileHashModel struct {
+	Path       string   `json:"path"`
+	Size       int64    `json:"size"`
+	MD5        string   `json:"md5,omitempty"`
+	SHA1       string   `json:"sha1,omitempty"`
+	SHA256     string   `json:"sha256,omitempty"`
+	Imphash    string   `json:"imphash,omitempty"`
+	ProcessIds []uint32 `json:"process_ids,omitempty"`
+	Error      string   `json:"error,omitempty"`
+
+	hashed bool
+}
+
+// HashFilesReply 批量哈希响应；异步模式下只有 job_id，结果同结构在 GetJob 中返回。
+type HashFilesReply struct {
+	JobId      int64           `json:"job_id,omitempty"`
+	Algorithms []string        `json:"algorithms"`
+	Total      int             `json:"total"`
+	Succeeded  int             `json:"succeeded"`
+	Failed     int             `json:"failed"`
+	Files      []FileHashModel `json:"files"`
+}
+
+// HashProgressModel 批量哈希进度。
+type HashProgressModel struct {
+	Done  int64 `json:"done"`
+	Total int64 `json:"total"`
+}
+
+// win32ModulePath 将驱动返回的模块路径（\SystemRoot\、\??\、\Device\ 形式）转为可打开的 Win32 路径。
+func win32ModulePath(p string) string {
+	if dos, err := pathTranslator.ToWin32(p); err == nil {
+		return dos
+	}
+	return p
+}
+
+// collectProcessImageFiles 汇总所有进程的映像与模块路径（按小写路径去重），并记录引用它们的 PID。
+func (t *ToolkitService) collectProcessImageFiles() ([]FileHashModel, error) {
+	names, err := processNameMapViaDriver(t.Driver)
+	if err != nil {
+		return nil, fmt.Errorf("枚举进程失败: %w", err)
+	}
+
+	byPath := make(map[string]*FileHashModel)
+	add := func(path string, pid uint32) {
+		if path == "" {
+			return
+		}
+		key := strings.ToLower(path)
+		f, ok := byPath[key]
+		if !ok {
+			f = &FileHashModel{Path: path}
+			byPath[key] = f
+		}
+		if n := len(f.ProcessIds); n == 0 || f.ProcessIds[n-1] != pid {
+			f.ProcessIds = append(f.ProcessIds, pid)
+		}
+	}
+
+	pids := make([]uint32, 0, len(names))
+	for pid := range names {
+		if pid != 0 && pid != 4 {
+			pids = append(pids, pid)
+		}
+	}
+	sort.Slice(pids, func(i, j int) bool { return pids[i] < pids[j] })
+	for _, pid := range pids {
+		if image, err := processImagePath(pid); err == nil {
+			add(image, pid)
+		}
+		modules, err := enumProcessModulesViaDriver(t.Driver, pid)
+		if err != nil {
+			continue
+		}
+		for _, m := range modules {
+			add(win32ModulePath(m.Path), pid)
+		}
+	}
+
+	files := make([]FileHashModel, 0, len(byPath))
+	for _, f := range byPath {
+		files = append(files, *f)
+	}
+	sort.Slice(files, func(i, j int) bool { return strings.ToLower(files[i].Path) < strings.ToLower(files[j].Path) })
+	return files, nil
+}
+
+// hashFilesConcurrently 以有界 worker 池计算 files 中每个文件的摘要，结果写回原位置。
+func hashFilesConcurrently(ctx context.Context, files []FileHashModel, algs []string, workers int, report func(any)) error {
+	var done atomic.Int64
+	total := int64(len(files))
+	next := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				f := &files[i]
+				sums, size, err := filehash.Sum(f.Path, algs)
+				f.Size = size
+				f.hashed = true
+				if err != nil {
+					f.Error = err.Error()
+				} else {
+					f.MD5 = sums[filehash.MD5]
+					f.SHA1 = sums[filehash.SHA1]
+					f.SHA256 = sums[filehash.SHA256]
+					f.Imphash = sums[filehash.Imphash]
+				}
+				if report != nil {
+					report(HashProgressModel{Done: done.Add(1), Total: total})
+				}
+			}
+		}()
+	}
+
+	var err error
+feed:
+	for i := range files {
+		select {
+		case next <- i:
+		case <-ctx.Done():
+			err = ctx.Err()
+			break feed
+		}
+	}
+	close(next)
+	wg.Wait()
+	return err
+}
+
+// HashFiles 并发计算文件 MD5/SHA1/SHA256 与 PE imphash；mode=processes 时哈希所有运行中进程的映像与模块，用于与 IOC 列表比对
+func (t *ToolkitService) HashFiles(args *HashFilesArgs, reply *HashFilesReply) error {
+	params := map[string]any{"paths": len(args.Paths), "algorithms": args.Algorithms, "mode": args.Mode, "async": args.Async}
+	algs, err := filehash.ParseAlgorithms(args.Algorithms)
+	if err != nil {
+		auditWrite("hash_files", params, err)
+		return err
+	}
+	workers := args.Workers
+	if workers <= 0 {
+		workers = defaultHashWorkers
+	}
+	if workers > maxHashWorkers {
+		workers = maxHashWorkers
+	}
+
+	var files []FileHashModel
+	switch strings.ToLower(strings.TrimSpace(args.Mode)) {
+	case "", "paths":
+		if len(args.Paths) == 0 {
+			err := fmt.Errorf("paths 不能为空")
+			auditWrite("hash_files", params, err)
+			return err
+		}
+		if len(args.Paths) > maxHashPaths {
+			err := fmt.Errorf("paths 最多 %d 个", maxHashPaths)
+			auditWrite("hash_files", params, err)
+			return err
+		}
+		files = make([]FileHashModel, 0, len(args.Paths))
+		for _, p := range args.Paths {
+			files = append(files, FileHashModel{Path: p})
+		}
+	case "processes":
+		if t.Driver == nil {
+			err := fmt.Errorf("驱动未加载")
+			auditWrite("hash_files", params, err)
+			return err
+		}
+		if files, err = t.collectProcessImageFiles(); err != nil {
+			auditWrite("hash_files", params, err)
+			return err
+		}
+	default:
+		err := fmt.Errorf("mode 仅支持 paths/processes")
+		auditWrite("hash_files", params, err)
+		return err
+	}
+
+	run := func(ctx context.Context, report func(any)) (*HashFilesReply, error) {
+		err := hashFilesConcurrently(ctx, files, algs, workers, report)
+		out := &HashFilesReply{Algorithms: algs, Total: len(files), Files: files}
+		for _, f := range files {
+			switch {
+			case f.Error != "":
+				out.Failed++
+			case f.hashed:
+				out.Succeeded++
+			}
+		}
+		return out, err
+	}
+
+	params["files"] = len(files)
+	if args.Async {
+		reply.JobId = startJob("hash_files", map[string]any{"mode": args.Mode, "algorithms": algs, "files": len(files)}, func(ctx context.Context, report func(any)) (any, error) {
+			return run(ctx, report)
+		})
+		reply.Algorithms = algs
+		reply.Total = len(files)
+		reply.Files = make([]FileHashModel, 0)
+		params["job_id"] = reply.JobId
+		auditWrite("hash_files", params, nil)
+		return nil
+	}
+
+	out, _ := run(context.Background(), nil)
+	*reply = *out
+	params["failed"] = reply.Failed
+	auditWrite("hash_files", params, nil)
+	return nil
+}
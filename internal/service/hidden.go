@@ -0,0 +1,217 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+)
+
+const (
+	hiddenSourceDriver   = "driver_processes"
+	hiddenSourceToolhelp = "toolhelp"
+	hiddenSourceThreads  = "driver_threads"
+	hiddenSourceHandles  = "driver_handles"
+)
+
+// DetectHiddenProcessesArgs 隐藏进程检测请求参数
+type DetectHiddenProcessesArgs struct{}
+
+// HiddenSourceStatus 单个枚举视图的采集状态。
+// Partial 为 true 表示驱动返回的列表不完整，该视图只用于标注 seen_by，不参与比对。
+type HiddenSourceStatus struct {
+	Name      string `json:"name"`
+	Available bool   `json:"available"`
+	Partial   bool   `json:"partial"`
+	Count     int    `json:"count"`
+	Error     string `json:"error,omitempty"`
+}
+
+// HiddenProcessModel 视图不一致的进程
+type HiddenProcessModel struct {
+	ProcessId   uint32   `json:"process_id"`
+	ImageName   string   `json:"image_name"`
+	SeenBy      []string `json:"seen_by"`
+	MissingFrom []string `json:"missing_from"`
+	Risk        string   `json:"risk"`
+}
+
+// DetectHiddenProcessesReply 隐藏进程检测响应
+type DetectHiddenProcessesReply struct {
+	Sources            []HiddenSourceStatus `json:"sources"`
+	Findings           []HiddenProcessModel `json:"findings"`
+	ExcludedSelfHidden []uint32             `json:"excluded_self_hidden"`
+}
+
+// DetectHiddenProcesses 对比驱动进程列表、Toolhelp 快照与线程/句柄属主 PID，报告视图不一致的进程
+func (t *ToolkitService) DetectHiddenProcesses(_ *DetectHiddenProcessesArgs, reply *DetectHiddenProcessesReply) error {
+	if t.Driver == nil {
+		err := fmt.Errorf("驱动未加载")
+		auditWrite("detect_hidden_processes", nil, err)
+		return err
+	}
+
+	views, partial, statuses := t.collectProcessViews()
+	reply.Sources = statuses
+
+	names := make(map[uint32]string)
+	for _, src := range []string{hiddenSourceToolhelp, hiddenSourceDriver} {
+		for pid, name := range views[src] {
+			if name != "" {
+				names[pid] = name
+			}
+		}
+	}
+
+	candidates := diffProcessViews(views, partial)
+	if len(candidates) > 0 {
+		// 进程在两次快照之间创建/退出会造成误报，复查一次各视图
+		confirmViews, confirmPartial, _ := t.collectProcessViews()
+		confirmed := diffProcessViews(confirmViews, confirmPartial)
+		for pid := range candidates {
+			if _, ok := confirmed[pid]; !ok {
+				delete(candidates, pid)
+			}
+		}
+	}
+
+	reply.Findings = make([]HiddenProcessModel, 0, len(candidates))
+	reply.ExcludedSelfHidden = make([]uint32, 0)
+	for pid := range candidates {
+		if globalStateLedger.has(StateKindHidden, pid) {
+			reply.ExcludedSelfHidden = append(reply.ExcludedSelfHidden, pid)
+			continue
+		}
+
+		finding := HiddenProcessModel{
+			ProcessId:   pid,
+			ImageName:   names[pid],
+			SeenBy:      make([]string, 0, len(views)),
+			MissingFrom: make([]string, 0, len(views)),
+			Risk:        "high",
+		}
+		for _, st := range statuses {
+			if !st.Available {
+				continue
+			}
+			if _, ok := views[st.Name][pid]; ok {
+				finding.SeenBy = append(finding.SeenBy, st.Name)
+				continue
+			}
+			// 不完整的视图缺少某个 PID 不说明任何问题
+			if !st.Partial {
+				finding.MissingFrom = append(finding.MissingFrom, st.Name)
+			}
+		}
+		reply.Findings = append(reply.Findings, finding)
+	}
+
+	sort.SliceStable(reply.Findings, func(i, j int) bool {
+		if reply.Findings[i].Risk != reply.Findings[j].Risk {
+			return reply.Findings[i].Risk == "high"
+		}
+		return reply.Findings[i].ProcessId < reply.Findings[j].ProcessId
+	})
+	sort.Slice(reply.ExcludedSelfHidden, func(i, j int) bool {
+		return reply.ExcludedSelfHidden[i] < reply.ExcludedSelfHidden[j]
+	})
+
+	auditWrite("detect_hidden_processes", map[string]any{
+		"findings": len(reply.Findings),
+		"excluded": len(reply.ExcludedSelfHidden),
+	}, nil)
+	return nil
+}
+
+// collectProcessViews 采集各枚举视图的 PID 集合（值为映像名，线程/句柄视图为空串），
+// 同时返回列表被截断、只有部分数据的视图。
+func (t *ToolkitService) collectProcessViews() (map[string]map[uint32]string, map[string]bool, []HiddenSourceStatus) {
+	views := make(map[string]map[uint32]string, 4)
+	partial := make(map[string]bool)
+	statuses := make([]HiddenSourceStatus, 0, 4)
+	record := func(name string, pids map[uint32]string, err error) {
+		st := HiddenSourceStatus{Name: name}
+		if err != nil {
+			st.Error = err.Error()
+			if pids == nil || !errors.Is(err, errDriverListTruncated) {
+				statuses = append(statuses, st)
+				return
+			}
+			st.Partial = true
+			partial[name] = true
+		}
+		delete(pids, 0)
+		st.Available = true
+		st.Count = len(pids)
+		views[name] = pids
+		statuses = append(statuses, st)
+	}
+
+	driverPids, err := processNameMapViaDriver(t.Driver)
+	record(hiddenSourceDriver, driverPids, err)
+
+	toolhelpPids, err := processNameMap()
+	record(hiddenSourceToolhelp, toolhelpPids, err)
+
+	var threadPids map[uint32]string
+	threads, err := enumThreadsViaDriver(t.Driver, 0)
+	if threads != nil {
+		threadPids = make(map[uint32]string, 256)
+		for _, th := range threads {
+			threadPids[th.OwnerProcess] = ""
+		}
+	}
+	record(hiddenSourceThreads, threadPids, err)
+
+	var handlePids map[uint32]string
+	handles, err := listHandlesViaDriver(t.Driver, 0)
+	if handles != nil {
+		handlePids = make(map[uint32]string, 256)
+		for _, h := range handles {
+			handlePids[h.ProcessId] = ""
+		}
+	}
+	record(hiddenSourceHandles, handlePids, err)
+
+	return views, partial, statuses
+}
+
+// diffProcessViews 返回视图不一致的 PID 及其可见来源，不完整的视图不参与比对：
+//   - 驱动进程列表与 Toolhelp 一方可见、另一方不可见；
+//   - 两个进程列表都看不到，但完整的线程视图与句柄视图都能看到。
+//
+// 线程/句柄视图单独缺少某个 PID（进程正在退出、没有句柄等）不算不一致。
+func diffProcessViews(views map[string]map[uint32]string, partial map[string]bool) map[uint32]map[string]struct{} {
+	complete := func(name string) bool {
+		_, ok := views[name]
+		return ok && !partial[name]
+	}
+	seen := make(map[uint32]map[string]struct{})
+	for src, pids := range views {
+		if partial[src] {
+			continue
+		}
+		for pid := range pids {
+			if seen[pid] == nil {
+				seen[pid] = make(map[string]struct{}, len(views))
+			}
+			seen[pid][src] = struct{}{}
+		}
+	}
+
+	out := make(map[uint32]map[string]struct{})
+	for pid, srcs := range seen {
+		_, inDriver := srcs[hiddenSourceDriver]
+		_, inToolhelp := srcs[hiddenSourceToolhelp]
+		_, inThreads := srcs[hiddenSourceThreads]
+		_, inHandles := srcs[hiddenSourceHandles]
+		switch {
+		case complete(hiddenSourceDriver) && complete(hiddenSourceToolhelp) && inDriver != inToolhelp:
+		case !inDriver && !inToolhelp && (complete(hiddenSourceDriver) || complete(hiddenSourceToolhelp)) &&
+			complete(hiddenSourceThreads) && complete(hiddenSourceHandles) && inThreads && inHandles:
+		default:
+			continue
+		}
+		out[pid] = srcs
+	}
+	return out
+}
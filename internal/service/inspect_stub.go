@@ -8,6 +8,10 @@ func enumProcessModules(_ uint32) ([]ProcessModuleModel, error) {
 	return nil, fmt.Errorf("仅支持 Windows")
 }
 
+func processNameMap() (map[uint32]string, error) {
+	return nil, fmt.Errorf("仅支持 Windows")
+}
+
 func enumNetworkConnections(_ string) ([]NetworkConnectionModel, error) {
 	return nil, fmt.Errorf("仅支持 Windows")
 }
@@ -0,0 +1,246 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// maxFinishedJobs 保留的已结束任务数，超出时丢弃最早结束的任务。
+const maxFinishedJobs = 32
+
+// 后台任务状态。
+const (
+	JobStateRunning   = "running"
+	JobStateCompleted = "completed"
+	JobStateFailed    = "failed"
+	JobStateCancelled = "cancelled"
+)
+
+// backgroundJob 一个耗时较长的后台任务，进度与结果通过 GetJob 轮询。
+type backgroundJob struct {
+	id         int64
+	kind       string
+	params     map[string]any
+	startedAt  time.Time
+	finishedAt time.Time
+	cancel     context.CancelFunc
+
+	mu       sync.Mutex
+	state    string
+	progress any
+	result   any
+	err      error
+}
+
+type jobStore struct {
+	mu   sync.Mutex
+	jobs map[int64]*backgroundJob
+}
+
+var (
+	globalJobs = &jobStore{jobs: make(map[int64]*backgroundJob)}
+	jobIDSeq   atomic.Int64
+)
+
+// jobRunner 任务主体：定期调用 report 更新进度，ctx 取消时应尽快返回 ctx.Err()。
+type jobRunner func(ctx context.Context, report func(progress any)) (any, error)
+
+// startJob 在后台 goroutine 中执行 run 并立即返回任务 ID。
+func startJob(kind string, params map[string]any, run jobRunner) int64 {
+	ctx, cancel := context.WithCancel(context.Background())
+	job := &backgroundJob{
+		id:        jobIDSeq.Add(1),
+		kind:      kind,
+		params:    params,
+		startedAt: time.Now(),
+		cancel:    cancel,
+		state:     JobStateRunning,
+	}
+
+	globalJobs.mu.Lock()
+	globalJobs.jobs[job.id] = job
+	globalJobs.pruneLocked()
+	globalJobs.mu.Unlock()
+
+	go func() {
+		defer cancel()
+		result, err := run(ctx, func(progress any) {
+			job.mu.Lock()
+			job.progress = progress
+			job.mu.Unlock()
+		})
+
+		job.mu.Lock()
+		job.finishedAt = time.Now()
+		job.result = result
+		switch {
+		case errors.Is(err, context.Canceled):
+			job.state = JobStateCancelled
+		case err != nil:
+			job.state = JobStateFailed
+			job.err = err
+		default:
+			job.state = JobStateCompleted
+		}
+		job.mu.Unlock()
+	}()
+	return job.id
+}
+
+// pruneLocked 只保留最近结束的 maxFinishedJobs 个任务，运行中的任务不受影响。
+func (s *jobStore) pruneLocked() {
+	type finishedJob struct {
+		id int64
+		at time.Time
+	}
+	finished := make([]finishedJob, 0, len(s.jobs))
+	for _, j := range s.jobs {
+		j.mu.Lock()
+		if j.state != JobStateRunning {
+			finished = append(finished, finishedJob{id: j.id, at: j.finishedAt})
+		}
+		j.mu.Unlock()
+	}
+	if len(finished) <= maxFinishedJobs {
+		return
+	}
+	sort.Slice(finished, func(i, k int) bool { return finished[i].at.Before(finished[k].at) })
+	for _, f := range finished[:len(finished)-maxFinishedJobs] {
+		delete(s.jobs, f.id)
+	}
+}
+
+func (s *jobStore) get(id int64) (*backgroundJob, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	job, ok := s.jobs[id]
+	if !ok {
+		return nil, fmt.Errorf("任务不存在: %d", id)
+	}
+	return job, nil
+}
+
+// CancelAllJobs 取消所有运行中的后台任务，供主进程退出前调用。
+func CancelAllJobs() {
+	globalJobs.mu.Lock()
+	defer globalJobs.mu.Unlock()
+	for _, j := range globalJobs.jobs {
+		j.cancel()
+	}
+}
+
+// JobModel 后台任务状态。
+type JobModel struct {
+	JobId      int64          `json:"job_id"`
+	Kind       string         `json:"kind"`
+	State      string         `json:"state"`
+	Params     map[string]any `json:"params,omitempty"`
+	StartedAt  string         `json:"started_at"`
+	FinishedAt string         `json:"finished_at,omitempty"`
+	ElapsedMs  int64          `json:"elapsed_ms"`
+	Progress   any            `json:"progress,omitempty"`
+	Result     any            `json:"result,omitempty"`
+	Error      string         `json:"error,omitempty"`
+}
+
+// snapshot 生成任务状态；withResult 为 false 时省略结果，用于列表。
+func (j *backgroundJob) snapshot(withResult bool) JobModel {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	m := JobModel{
+		JobId:     j.id,
+		Kind:      j.kind,
+		State:     j.state,
+		Params:    j.params,
+		StartedAt: j.startedAt.Format(time.RFC3339),
+		Progress:  j.progress,
+	}
+	end := time.Now()
+	if !j.finishedAt.IsZero() {
+		end = j.finishedAt
+		m.FinishedAt = j.finishedAt.Format(time.RFC3339)
+	}
+	m.ElapsedMs = end.Sub(j.startedAt).Milliseconds()
+	if withResult {
+		m.Result = j.result
+	}
+	if j.err != nil {
+		m.Error = j.err.Error()
+	}
+	return m
+}
+
+// GetJobArgs 查询后台任务请求参数
+type GetJobArgs struct {
+	JobId int64 `json:"job_id"`
+}
+
+// GetJobReply 查询后台任务响应
+type GetJobReply struct {
+	Job JobModel `json:"job"`
+}
+
+// GetJob 查询后台任务的状态、进度与结果
+func (t *ToolkitService) GetJob(args *GetJobArgs, reply *GetJobReply) error {
+	job, err := globalJobs.get(args.JobId)
+	if err != nil {
+		return err
+	}
+	reply.Job = job.snapshot(true)
+	return nil
+}
+
+// ListJobsArgs 后台任务列表请求参数
+type ListJobsArgs struct{}
+
+// ListJobsReply 后台任务列表响应，不含结果
+type ListJobsReply struct {
+	Jobs []JobModel `json:"jobs"`
+}
+
+// ListJobs 列出运行中与最近结束的后台任务
+func (t *ToolkitService) ListJobs(_ *ListJobsArgs, reply *ListJobsReply) error {
+	globalJobs.mu.Lock()
+	jobs := make([]*backgroundJob, 0, len(globalJobs.jobs))
+	for _, j := range globalJobs.jobs {
+		jobs = append(jobs, j)
+	}
+	globalJobs.mu.Unlock()
+
+	sort.Slice(jobs, func(i, k int) bool { return jobs[i].id < jobs[k].id })
+	reply.Jobs = make([]JobModel, 0, len(jobs))
+	for _, j := range jobs {
+		reply.Jobs = append(reply.Jobs, j.snapshot(false))
+	}
+	return nil
+}
+
+// CancelJobArgs 取消后台任务请求参数
+type CancelJobArgs struct {
+	JobId int64 `json:"job_id"`
+}
+
+// CancelJobReply 取消后台任务响应
+type CancelJobReply struct {
+	Success bool   `json:"success"`
+	State   string `json:"state"`
+}
+
+// CancelJob 请求取消运行中的后台任务；任务在下一个检查点退出，状态变为 cancelled
+func (t *ToolkitService) CancelJob(args *CancelJobArgs, reply *CancelJobReply) error {
+	job, err := globalJobs.get(args.JobId)
+	if err != nil {
+		auditWrite("cancel_job", map[string]any{"job_id": args.JobId}, err)
+		return err
+	}
+	job.cancel()
+	reply.Success = true
+	reply.State = job.snapshot(false).State
+	auditWrite("cancel_job", map[string]any{"job_id": args.JobId, "kind": job.kind}, nil)
+	return nil
+}
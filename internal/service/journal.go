@@ -0,0 +1,154 @@
+package service
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const maxUndoEntries = 200
+
+// UndoEntry 可撤销操作的日志项。
+type UndoEntry struct {
+	ID        int64          `json:"id"`
+	Action    string         `json:"action"`
+	Inverse   string         `json:"inverse"`
+	Params    map[string]any `json:"params,omitempty"`
+	CreatedAt string         `json:"created_at"`
+	Undone    bool           `json:"undone"`
+	UndoneAt  string         `json:"undone_at,omitempty"`
+
+	undo func(t *ToolkitService) error
+}
+
+type undoJournal struct {
+	mu      sync.Mutex
+	entries []UndoEntry
+}
+
+var (
+	globalUndoJournal = &undoJournal{entries: make([]UndoEntry, 0, maxUndoEntries)}
+	undoIDSeq         atomic.Int64
+)
+
+// journalPush 在可逆操作成功后登记撤销方法，inverse 为逆操作的审计动作名。
+func journalPush(action string, params map[string]any, inverse string, undo func(t *ToolkitService) error) int64 {
+	entry := UndoEntry{
+		ID:        undoIDSeq.Add(1),
+		Action:    action,
+		Inverse:   inverse,
+		Params:    params,
+		CreatedAt: time.Now().Format(time.RFC3339),
+		undo:      undo,
+	}
+
+	globalUndoJournal.mu.Lock()
+	defer globalUndoJournal.mu.Unlock()
+	globalUndoJournal.entries = append(globalUndoJournal.entries, entry)
+	if len(globalUndoJournal.entries) > maxUndoEntries {
+		globalUndoJournal.entries = append([]UndoEntry(nil), globalUndoJournal.entries[len(globalUndoJournal.entries)-maxUndoEntries:]...)
+	}
+	return entry.ID
+}
+
+// claim 原子地取出待撤销项并标记为已撤销，避免并发重复执行；失败时由 release 回滚。
+func (j *undoJournal) claim(id int64) (UndoEntry, error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	for i := range j.entries {
+		if j.entries[i].ID != id {
+			continue
+		}
+		if j.entries[i].Undone {
+			return j.entries[i], fmt.Errorf("撤销项已执行过: %d", id)
+		}
+		j.entries[i].Undone = true
+		j.entries[i].UndoneAt = time.Now().Format(time.RFC3339)
+		return j.entries[i], nil
+	}
+	return UndoEntry{}, fmt.Errorf("撤销项不存在: %d", id)
+}
+
+func (j *undoJournal) release(id int64) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	for i := range j.entries {
+		if j.entries[i].ID == id {
+			j.entries[i].Undone = false
+			j.entries[i].UndoneAt = ""
+			return
+		}
+	}
+}
+
+func (j *undoJournal) list(includeUndone bool, limit int) []UndoEntry {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	out := make([]UndoEntry, 0, len(j.entries))
+	for i := len(j.entries) - 1; i >= 0; i-- {
+		if j.entries[i].Undone && !includeUndone {
+			continue
+		}
+		out = append(out, j.entries[i])
+		if limit > 0 && len(out) >= limit {
+			break
+		}
+	}
+	return out
+}
+
+// ListUndoableArgs 可撤销操作列表请求参数
+type ListUndoableArgs struct {
+	IncludeUndone bool `json:"include_undone"`
+	Limit         int  `json:"limit"`
+}
+
+// ListUndoableReply 可撤销操作列表响应
+type ListUndoableReply struct {
+	Entries []UndoEntry `json:"entries"`
+}
+
+// ListUndoable 按时间倒序列出可撤销操作
+func (t *ToolkitService) ListUndoable(args *ListUndoableArgs, reply *ListUndoableReply) error {
+	limit := args.Limit
+	if limit <= 0 {
+		limit = 100
+	}
+	reply.Entries = globalUndoJournal.list(args.IncludeUndone, limit)
+	return nil
+}
+
+// UndoArgs 撤销请求参数
+type UndoArgs struct {
+	ID int64 `json:"id"`
+}
+
+// UndoReply 撤销响应
+type UndoReply struct {
+	Success bool   `json:"success"`
+	Action  string `json:"action"`
+	Inverse string `json:"inverse"`
+}
+
+// Undo 执行日志项登记的逆操作；逆操作本身同样会写入审计与撤销日志
+func (t *ToolkitService) Undo(args *UndoArgs, reply *UndoReply) error {
+	entry, err := globalUndoJournal.claim(args.ID)
+	if err != nil {
+		auditWrite("undo", map[string]any{"id": args.ID, "action": entry.Action}, err)
+		return err
+	}
+
+	reply.Action = entry.Action
+	reply.Inverse = entry.Inverse
+	if err := entry.undo(t); err != nil {
+		globalUndoJournal.release(args.ID)
+		retErr := fmt.Errorf("撤销失败: %w", err)
+		auditWrite("undo", map[string]any{"id": args.ID, "action": entry.Action, "inverse": entry.Inverse}, retErr)
+		return retErr
+	}
+
+	reply.Success = true
+	auditWrite("undo", map[string]any{"id": args.ID, "action": entry.Action, "inverse": entry.Inverse}, nil)
+	return nil
+}
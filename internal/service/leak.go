@@ -0,0 +1,578 @@
+package service
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	maxLeakAlerts         = 200
+	maxLeakAlertTypes     = 10
+	maxLeakAlertNames     = 20
+	leakNameKeySep        = "\x00"
+	minLeakIntervalMs     = 5000
+	maxLeakIntervalMs     = 60 * 60 * 1000
+	minLeakWindow         = 3
+	maxLeakWindow         = 1000
+	defaultLeakWindow     = 10
+	defaultLeakGrowth     = 100
+	defaultLeakSlope      = 100
+	defaultLeakRatio      = 0.8
+	defaultLeakIntervalMs = 60000
+)
+
+// HandleLeakDetectorConfigModel 句柄泄漏检测配置。
+// 窗口内最近 Window 次采样同时满足：总增长 >= MinGrowth、拟合斜率 >= MinSlopePerHour、
+// 非下降步数占比 >= MonotonicRatio，即判定为疑似泄漏。
+type HandleLeakDetectorConfigModel struct {
+	Enabled         bool     `json:"enabled"`
+	IntervalMs      int      `json:"interval_ms"`
+	Window          int      `json:"window"`
+	MinGrowth       int      `json:"min_growth"`
+	MinSlopePerHour float64  `json:"min_slope_per_hour"`
+	MonotonicRatio  float64  `json:"monotonic_ratio"`
+	ProcessIds      []uint32 `json:"process_ids"`
+}
+
+// HandleLeakTypeModel 告警中按对象类型的增长情况。
+type HandleLeakTypeModel struct {
+	TypeName     string  `json:"type_name"`
+	StartCount   uint32  `json:"start_count"`
+	CurrentCount uint32  `json:"current_count"`
+	Growth       int64   `json:"growth"`
+	SlopePerHour float64 `json:"slope_per_hour"`
+}
+
+// HandleLeakObjectModel 告警中按对象名的累积情况（相对名称基线）。
+type HandleLeakObjectModel struct {
+	TypeName      string `json:"type_name"`
+	ObjectName    string `json:"object_name"`
+	BaselineCount uint32 `json:"baseline_count"`
+	CurrentCount  uint32 `json:"current_count"`
+	Growth        int64  `json:"growth"`
+}
+
+// HandleLeakAlertModel 疑似句柄泄漏告警；同一进程持续泄漏时原地更新，恢复后标记 resolved。
+type HandleLeakAlertModel struct {
+	ID              int64                   `json:"id"`
+	ProcessId       uint32                  `json:"process_id"`
+	ProcessKey      string                  `json:"process_key"`
+	ImageName       string                  `json:"image_name"`
+	Active          bool                    `json:"active"`
+	FirstDetectedAt string                  `json:"first_detected_at"`
+	LastUpdatedAt   string                  `json:"last_updated_at"`
+	ResolvedAt      string                  `json:"resolved_at,omitempty"`
+	Samples         int                     `json:"samples"`
+	StartCount      uint32                  `json:"start_count"`
+	CurrentCount    uint32                  `json:"current_count"`
+	Growth          int64                   `json:"growth"`
+	SlopePerHour    float64                 `json:"slope_per_hour"`
+	MonotonicRatio  float64                 `json:"monotonic_ratio"`
+	Types           []HandleLeakTypeModel   `json:"types"`
+	Objects         []HandleLeakObjectModel `json:"objects"`
+	ObjectsSince    string                  `json:"objects_since,omitempty"`
+}
+
+type leakSample struct {
+	at    time.Time
+	total uint32
+	types map[string]uint32
+}
+
+// leakTrack 单进程的采样窗口与对象名基线；基线每经过一个完整窗口刷新一次。
+type leakTrack struct {
+	imageName     string
+	createTime    uint64
+	samples       []leakSample
+	baseline      map[string]uint32
+	baselineAt    time.Time
+	sinceBaseline int
+	alertID       int64
+}
+
+// leakObservation 一轮采样中单个进程的句柄分布；names 为 nil 表示当前来源不提供对象名。
+type leakObservation struct {
+	total uint32
+	types map[string]uint32
+	names map[string]uint32
+}
+
+type leakDetector struct {
+	applyMu   sync.Mutex
+	mu        sync.Mutex
+	t         *ToolkitService
+	cfg       HandleLeakDetectorConfigModel
+	tracks    map[uint32]*leakTrack
+	alerts    []HandleLeakAlertModel
+	stop      chan struct{}
+	done      chan struct{}
+	lastError string
+	lastAt    time.Time
+}
+
+var (
+	globalLeakDetector = &leakDetector{
+		cfg:    defaultLeakDetectorConfig(),
+		tracks: make(map[uint32]*leakTrack),
+	}
+	leakAlertIDSeq atomic.Int64
+)
+
+func defaultLeakDetectorConfig() HandleLeakDetectorConfigModel {
+	return HandleLeakDetectorConfigModel{
+		IntervalMs:      defaultLeakIntervalMs,
+		Window:          defaultLeakWindow,
+		MinGrowth:       defaultLeakGrowth,
+		MinSlopePerHour: defaultLeakSlope,
+		MonotonicRatio:  defaultLeakRatio,
+	}
+}
+
+// apply 替换配置并重启采样协程；采样间隔或窗口变化时清空已有窗口，告警保留。
+func (d *leakDetector) apply(t *ToolkitService, cfg HandleLeakDetectorConfigModel) {
+	d.applyMu.Lock()
+	defer d.applyMu.Unlock()
+
+	d.mu.Lock()
+	stop, done := d.stop, d.done
+	d.stop, d.done = nil, nil
+	d.mu.Unlock()
+	if stop != nil {
+		close(stop)
+		<-done
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if cfg.IntervalMs != d.cfg.IntervalMs || cfg.Window != d.cfg.Window {
+		d.tracks = make(map[uint32]*leakTrack)
+	}
+	d.cfg = cfg
+	d.t = t
+	if !cfg.Enabled {
+		return
+	}
+	d.stop = make(chan struct{})
+	d.done = make(chan struct{})
+	go d.run(t, time.Duration(cfg.IntervalMs)*time.Millisecond, d.stop, d.done)
+}
+
+func (d *leakDetector) run(t *ToolkitService, interval time.Duration, stop <-chan struct{}, done chan<- struct{}) {
+	defer close(done)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	d.sample(t)
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			d.sample(t)
+		}
+	}
+}
+
+// collect 驱动可用时一次枚举全系统句柄（含对象名）；否则仅对指定 PID 用用户态统计类型分布。
+func (d *leakDetector) collect(t *ToolkitService, pids []uint32) (map[uint32]*leakObservation, map[uint32]string, error) {
+	var selected map[uint32]struct{}
+	if len(pids) > 0 {
+		selected = make(map[uint32]struct{}, len(pids))
+		for _, pid := range pids {
+			selected[pid] = struct{}{}
+		}
+	}
+
+	obs := make(map[uint32]*leakObservation)
+	names := make(map[uint32]string)
+	if t.Driver != nil {
+		handles, err := listHandlesViaDriver(t.Driver, 0)
+		if err != nil {
+			return nil, nil, fmt.Errorf("枚举句柄失败: %w", err)
+		}
+		for _, h := range handles {
+			if selected != nil {
+				if _, ok := selected[h.ProcessId]; !ok {
+					continue
+				}
+			}
+			o := obs[h.ProcessId]
+			if o == nil {
+				o = &leakObservation{types: make(map[string]uint32), names: make(map[string]uint32)}
+				obs[h.ProcessId] = o
+			}
+			typeName := h.TypeName
+			if typeName == "" {
+				typeName = fmt.Sprintf("type#%d", h.ObjectTypeIndex)
+			}
+			o.total++
+			o.types[typeName]++
+			o.names[typeName+leakNameKeySep+h.ObjectName]++
+		}
+		if pm, err := processNameMapViaDriver(t.Driver); err == nil {
+			names = pm
+		}
+		return obs, names, nil
+	}
+
+	if selected == nil {
+		return nil, nil, fmt.Errorf("驱动未加载，需通过 process_ids 指定要检测的进程")
+	}
+	for pid := range selected {
+		total, stats, err := enumHandleStatsByPID(pid)
+		if err != nil {
+			continue
+		}
+		o := &leakObservation{total: total, types: make(map[string]uint32, len(stats))}
+		for _, s := range stats {
+			o.types[s.TypeName] += s.Count
+		}
+		obs[pid] = o
+		names[pid] = processImageBaseName(pid)
+	}
+	return obs, names, nil
+}
+
+func (d *leakDetector) sample(t *ToolkitService) {
+	d.mu.Lock()
+	cfg := d.cfg
+	cfg.ProcessIds = append([]uint32(nil), d.cfg.ProcessIds...)
+	d.mu.Unlock()
+
+	obs, imageNames, err := d.collect(t, cfg.ProcessIds)
+	createTimes := make(map[uint32]uint64, len(obs))
+	for pid := range obs {
+		if ct, cerr := processCreateTime(pid); cerr == nil {
+			createTimes[pid] = ct
+		}
+	}
+	now := time.Now()
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.lastAt = now
+	if err != nil {
+		d.lastError = err.Error()
+		return
+	}
+	d.lastError = ""
+
+	for pid, tr := range d.tracks {
+		if _, ok := obs[pid]; !ok {
+			d.resolveAlert(tr, now)
+			delete(d.tracks, pid)
+		}
+	}
+
+	for pid, o := range obs {
+		tr := d.tracks[pid]
+		ct := createTimes[pid]
+		if tr != nil && ct != 0 && tr.createTime != 0 && ct != tr.createTime {
+			d.resolveAlert(tr, now)
+			tr = nil
+		}
+		if tr == nil {
+			tr = &leakTrack{createTime: ct}
+			d.tracks[pid] = tr
+		}
+		if name := imageNames[pid]; name != "" {
+			tr.imageName = name
+		}
+
+		tr.samples = append(tr.samples, leakSample{at: now, total: o.total, types: o.types})
+		if len(tr.samples) > cfg.Window {
+			tr.samples = append([]leakSample(nil), tr.samples[len(tr.samples)-cfg.Window:]...)
+		}
+		if o.names != nil && (tr.baseline == nil || tr.sinceBaseline >= cfg.Window) {
+			tr.baseline = o.names
+			tr.baselineAt = now
+			tr.sinceBaseline = 0
+		}
+		tr.sinceBaseline++
+
+		d.evaluate(pid, tr, o, cfg, now)
+	}
+}
+
+// evaluate 对窗口拟合斜率并判定是否泄漏，满足条件时新建或更新告警，不再满足时标记恢复。
+func (d *leakDetector) evaluate(pid uint32, tr *leakTrack, o *leakObservation, cfg HandleLeakDetectorConfigModel, now time.Time) {
+	if len(tr.samples) < cfg.Window {
+		return
+	}
+
+	first, last := tr.samples[0], tr.samples[len(tr.samples)-1]
+	growth := int64(last.total) - int64(first.total)
+	slope := fitSlopePerHour(tr.samples, func(s leakSample) float64 { return float64(s.total) })
+	steps := 0
+	for i := 1; i < len(tr.samples); i++ {
+		if tr.samples[i].total >= tr.samples[i-1].total {
+			steps++
+		}
+	}
+	ratio := float64(steps) / float64(len(tr.samples)-1)
+
+	if growth < int64(cfg.MinGrowth) || slope < cfg.MinSlopePerHour || ratio < cfg.MonotonicRatio {
+		d.resolveAlert(tr, now)
+		return
+	}
+
+	alert := HandleLeakAlertModel{
+		ProcessId:      pid,
+		ProcessKey:     formatProcessKey(pid, tr.createTime),
+		ImageName:      tr.imageName,
+		Active:         true,
+		LastUpdatedAt:  now.Format(time.RFC3339),
+		Samples:        len(tr.samples),
+		StartCount:     first.total,
+		CurrentCount:   last.total,
+		Growth:         growth,
+		SlopePerHour:   slope,
+		MonotonicRatio: ratio,
+		Types:          leakTypeGrowth(tr.samples),
+		Objects:        leakObjectGrowth(tr.baseline, o.names),
+	}
+	if o.names != nil && !tr.baselineAt.IsZero() {
+		alert.ObjectsSince = tr.baselineAt.Format(time.RFC3339)
+	}
+
+	if tr.alertID != 0 {
+		for i := range d.alerts {
+			if d.alerts[i].ID == tr.alertID {
+				alert.ID = tr.alertID
+				alert.FirstDetectedAt = d.alerts[i].FirstDetectedAt
+				d.alerts[i] = alert
+				return
+			}
+		}
+	}
+	alert.ID = leakAlertIDSeq.Add(1)
+	alert.FirstDetectedAt = alert.LastUpdatedAt
+	tr.alertID = alert.ID
+	d.alerts = append(d.alerts, alert)
+	if len(d.alerts) > maxLeakAlerts {
+		d.alerts = append([]HandleLeakAlertModel(nil), d.alerts[len(d.alerts)-maxLeakAlerts:]...)
+	}
+}
+
+func (d *leakDetector) resolveAlert(tr *leakTrack, now time.Time) {
+	if tr.alertID == 0 {
+		return
+	}
+	for i := range d.alerts {
+		if d.alerts[i].ID == tr.alertID {
+			d.alerts[i].Active = false
+			d.alerts[i].ResolvedAt = now.Format(time.RFC3339)
+		}
+	}
+	tr.alertID = 0
+}
+
+// fitSlopePerHour 对采样点做最小二乘线性拟合，返回每小时的增长量。
+func fitSlopePerHour(samples []leakSample, value func(leakSample) float64) float64 {
+	n := float64(len(samples))
+	if n < 2 {
+		return 0
+	}
+	var sx, sy, sxx, sxy float64
+	for _, s := range samples {
+		x := s.at.Sub(samples[0].at).Hours()
+		y := value(s)
+		sx += x
+		sy += y
+		sxx += x * x
+		sxy += x * y
+	}
+	den := n*sxx - sx*sx
+	if den == 0 {
+		return 0
+	}
+	return (n*sxy - sx*sy) / den
+}
+
+func leakTypeGrowth(samples []leakSample) []HandleLeakTypeModel {
+	first, last := samples[0], samples[len(samples)-1]
+	out := make([]HandleLeakTypeModel, 0, len(last.types))
+	for typeName, cur := range last.types {
+		start := first.types[typeName]
+		if cur <= start {
+			continue
+		}
+		out = append(out, HandleLeakTypeModel{
+			TypeName:     typeName,
+			StartCount:   start,
+			CurrentCount: cur,
+			Growth:       int64(cur) - int64(start),
+			SlopePerHour: fitSlopePerHour(samples, func(s leakSample) float64 { return float64(s.types[typeName]) }),
+		})
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Growth != out[j].Growth {
+			return out[i].Growth > out[j].Growth
+		}
+		return out[i].TypeName < out[j].TypeName
+	})
+	if len(out) > maxLeakAlertTypes {
+		out = out[:maxLeakAlertTypes]
+	}
+	return out
+}
+
+func leakObjectGrowth(baseline map[string]uint32, current map[string]uint32) []HandleLeakObjectModel {
+	out := make([]HandleLeakObjectModel, 0, maxLeakAlertNames)
+	for key, cur := range current {
+		base := baseline[key]
+		if cur <= base {
+			continue
+		}
+		typeName, objectName, _ := strings.Cut(key, leakNameKeySep)
+		out = append(out, HandleLeakObjectModel{
+			TypeName:      typeName,
+			ObjectName:    objectName,
+			BaselineCount: base,
+			CurrentCount:  cur,
+			Growth:        int64(cur) - int64(base),
+		})
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Growth != out[j].Growth {
+			return out[i].Growth > out[j].Growth
+		}
+		if out[i].TypeName != out[j].TypeName {
+			return out[i].TypeName < out[j].TypeName
+		}
+		return out[i].ObjectName < out[j].ObjectName
+	})
+	if len(out) > maxLeakAlertNames {
+		out = out[:maxLeakAlertNames]
+	}
+	return out
+}
+
+// ConfigureHandleLeakDetectorArgs 配置句柄泄漏检测请求参数（整体替换，数值为 0 取默认值）
+type ConfigureHandleLeakDetectorArgs struct {
+	Enabled         bool     `json:"enabled"`
+	IntervalMs      int      `json:"interval_ms"`
+	Window          int      `json:"window"`
+	MinGrowth       int      `json:"min_growth"`
+	MinSlopePerHour float64  `json:"min_slope_per_hour"`
+	MonotonicRatio  float64  `json:"monotonic_ratio"`
+	ProcessIds      []uint32 `json:"process_ids"`
+}
+
+// ConfigureHandleLeakDetectorReply 配置句柄泄漏检测响应
+type ConfigureHandleLeakDetectorReply struct {
+	Config HandleLeakDetectorConfigModel `json:"config"`
+}
+
+// ConfigureHandleLeakDetector 启停后台句柄泄漏检测并设置判定阈值
+func (t *ToolkitService) ConfigureHandleLeakDetector(args *ConfigureHandleLeakDetectorArgs, reply *ConfigureHandleLeakDetectorReply) error {
+	cfg := defaultLeakDetectorConfig()
+	cfg.Enabled = args.Enabled
+	cfg.ProcessIds = append([]uint32(nil), args.ProcessIds...)
+	if args.IntervalMs != 0 {
+		cfg.IntervalMs = args.IntervalMs
+	}
+	if args.Window != 0 {
+		cfg.Window = args.Window
+	}
+	if args.MinGrowth != 0 {
+		cfg.MinGrowth = args.MinGrowth
+	}
+	if args.MinSlopePerHour != 0 {
+		cfg.MinSlopePerHour = args.MinSlopePerHour
+	}
+	if args.MonotonicRatio != 0 {
+		cfg.MonotonicRatio = args.MonotonicRatio
+	}
+	params := map[string]any{
+		"enabled":            cfg.Enabled,
+		"interval_ms":        cfg.IntervalMs,
+		"window":             cfg.Window,
+		"min_growth":         cfg.MinGrowth,
+		"min_slope_per_hour": cfg.MinSlopePerHour,
+		"monotonic_ratio":    cfg.MonotonicRatio,
+		"process_ids":        cfg.ProcessIds,
+	}
+
+	var err error
+	switch {
+	case cfg.IntervalMs < minLeakIntervalMs || cfg.IntervalMs > maxLeakIntervalMs:
+		err = fmt.Errorf("interval_ms 取值范围 %d~%d", minLeakIntervalMs, maxLeakIntervalMs)
+	case cfg.Window < minLeakWindow || cfg.Window > maxLeakWindow:
+		err = fmt.Errorf("window 取值范围 %d~%d", minLeakWindow, maxLeakWindow)
+	case cfg.MinGrowth < 0 || cfg.MinSlopePerHour < 0:
+		err = fmt.Errorf("min_growth/min_slope_per_hour 不能为负数")
+	case cfg.MonotonicRatio < 0 || cfg.MonotonicRatio > 1:
+		err = fmt.Errorf("monotonic_ratio 取值范围 0~1")
+	case cfg.Enabled && t.Driver == nil && len(cfg.ProcessIds) == 0:
+		err = fmt.Errorf("驱动未加载，需通过 process_ids 指定要检测的进程")
+	}
+	if err != nil {
+		auditWrite("configure_handle_leak_detector", params, err)
+		return err
+	}
+
+	globalLeakDetector.apply(&ToolkitService{Driver: t.Driver}, cfg)
+	reply.Config = cfg
+	auditWrite("configure_handle_leak_detector", params, nil)
+	return nil
+}
+
+// StopHandleLeakDetector 停止后台句柄泄漏检测，须在关闭驱动句柄前调用。
+func StopHandleLeakDetector() {
+	globalLeakDetector.mu.Lock()
+	cfg, t := globalLeakDetector.cfg, globalLeakDetector.t
+	globalLeakDetector.mu.Unlock()
+	cfg.Enabled = false
+	globalLeakDetector.apply(t, cfg)
+}
+
+// GetHandleLeakAlertsArgs 句柄泄漏告警查询请求参数
+type GetHandleLeakAlertsArgs struct {
+	SinceId    int64 `json:"since_id"`
+	ActiveOnly bool  `json:"active_only"`
+	Limit      int   `json:"limit"`
+}
+
+// GetHandleLeakAlertsReply 句柄泄漏告警查询响应
+type GetHandleLeakAlertsReply struct {
+	Config           HandleLeakDetectorConfigModel `json:"config"`
+	Running          bool                          `json:"running"`
+	TrackedProcesses int                           `json:"tracked_processes"`
+	LastSampleAt     string                        `json:"last_sample_at,omitempty"`
+	LastError        string                        `json:"last_error,omitempty"`
+	Alerts           []HandleLeakAlertModel        `json:"alerts"`
+}
+
+// GetHandleLeakAlerts 按 ID 倒序返回句柄泄漏告警及检测器状态
+func (t *ToolkitService) GetHandleLeakAlerts(args *GetHandleLeakAlertsArgs, reply *GetHandleLeakAlertsReply) error {
+	limit := args.Limit
+	if limit <= 0 {
+		limit = 50
+	}
+
+	d := globalLeakDetector
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	reply.Config = d.cfg
+	reply.Running = d.stop != nil
+	reply.TrackedProcesses = len(d.tracks)
+	reply.LastError = d.lastError
+	if !d.lastAt.IsZero() {
+		reply.LastSampleAt = d.lastAt.Format(time.RFC3339)
+	}
+	reply.Alerts = make([]HandleLeakAlertModel, 0, limit)
+	for i := len(d.alerts) - 1; i >= 0 && len(reply.Alerts) < limit; i-- {
+		a := d.alerts[i]
+		if a.ID <= args.SinceId || (args.ActiveOnly && !a.Active) {
+			continue
+		}
+		reply.Alerts = append(reply.Alerts, a)
+	}
+	return nil
+}
@@ -0,0 +1,245 @@
+package service
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	minAutoRevertMs = 1000
+	maxAutoRevertMs = 24 * 60 * 60 * 1000
+)
+
+// revertLease 死手定时器：到期前未续期或取消，则自动执行逆操作。
+type revertLease struct {
+	id        int64
+	kind      string
+	pid       uint32
+	expiresAt time.Time
+	timer     *time.Timer
+}
+
+type revertLeaseStore struct {
+	mu     sync.Mutex
+	leases map[int64]*revertLease
+}
+
+var (
+	globalRevertLeases = &revertLeaseStore{leases: make(map[int64]*revertLease)}
+	revertLeaseIDSeq   atomic.Int64
+)
+
+func validateAutoRevertMs(ms uint32) error {
+	if ms == 0 {
+		return nil
+	}
+	if ms < minAutoRevertMs || ms > maxAutoRevertMs {
+		return fmt.Errorf("auto_revert_after_ms 取值范围 %d~%d", minAutoRevertMs, maxAutoRevertMs)
+	}
+	return nil
+}
+
+// schedule 为 (kind, pid) 登记租约；已存在的同类租约会被替换。
+func (s *revertLeaseStore) schedule(t *ToolkitService, kind string, pid uint32, after time.Duration) (int64, time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for id, l := range s.leases {
+		if l.kind == kind && l.pid == pid {
+			l.timer.Stop()
+			delete(s.leases, id)
+		}
+	}
+
+	lease := &revertLease{
+		id:        revertLeaseIDSeq.Add(1),
+		kind:      kind,
+		pid:       pid,
+		expiresAt: time.Now().Add(after),
+	}
+	id := lease.id
+	lease.timer = time.AfterFunc(after, func() { s.fire(t, id) })
+	s.leases[id] = lease
+	return id, lease.expiresAt
+}
+
+// fire 到期回调：先摘除租约再执行逆操作，逆操作内清理台账时不会重入本租约。
+func (s *revertLeaseStore) fire(t *ToolkitService, id int64) {
+	s.mu.Lock()
+	lease, ok := s.leases[id]
+	if ok {
+		delete(s.leases, id)
+	}
+	s.mu.Unlock()
+	if !ok {
+		return
+	}
+
+	err := t.revertState(lease.kind, lease.pid)
+	auditWrite("auto_revert", map[string]any{
+		"lease_id":   lease.id,
+		"kind":       lease.kind,
+		"process_id": lease.pid,
+	}, err)
+}
+
+func (s *revertLeaseStore) extend(id int64, after time.Duration) (time.Time, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	lease, ok := s.leases[id]
+	if !ok {
+		return time.Time{}, fmt.Errorf("租约不存在或已到期: %d", id)
+	}
+	if !lease.timer.Stop() {
+		return time.Time{}, fmt.Errorf("租约已到期: %d", id)
+	}
+	lease.expiresAt = time.Now().Add(after)
+	lease.timer.Reset(after)
+	return lease.expiresAt, nil
+}
+
+func (s *revertLeaseStore) cancel(id int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	lease, ok := s.leases[id]
+	if !ok {
+		return fmt.Errorf("租约不存在或已到期: %d", id)
+	}
+	lease.timer.Stop()
+	delete(s.leases, id)
+	return nil
+}
+
+// dropFor 在手动逆操作成功后撤销对应租约。
+func (s *revertLeaseStore) dropFor(kind string, pid uint32) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for id, l := range s.leases {
+		if l.kind == kind && l.pid == pid {
+			l.timer.Stop()
+			delete(s.leases, id)
+		}
+	}
+}
+
+// expireAll 立即触发全部未到期租约，用于后端退出前。
+func (s *revertLeaseStore) expireAll(t *ToolkitService) {
+	s.mu.Lock()
+	ids := make([]int64, 0, len(s.leases))
+	for id, l := range s.leases {
+		if l.timer.Stop() {
+			ids = append(ids, id)
+		}
+	}
+	s.mu.Unlock()
+
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	for _, id := range ids {
+		s.fire(t, id)
+	}
+}
+
+func (s *revertLeaseStore) list() []RevertLeaseModel {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	out := make([]RevertLeaseModel, 0, len(s.leases))
+	for _, l := range s.leases {
+		remaining := l.expiresAt.Sub(now).Milliseconds()
+		if remaining < 0 {
+			remaining = 0
+		}
+		out = append(out, RevertLeaseModel{
+			LeaseId:     l.id,
+			Kind:        l.kind,
+			ProcessId:   l.pid,
+			ExpiresAt:   l.expiresAt.Format(time.RFC3339),
+			RemainingMs: remaining,
+		})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].LeaseId < out[j].LeaseId })
+	return out
+}
+
+// RevertLeaseModel 自动恢复租约
+type RevertLeaseModel struct {
+	LeaseId     int64  `json:"lease_id"`
+	Kind        string `json:"kind"`
+	ProcessId   uint32 `json:"process_id"`
+	ExpiresAt   string `json:"expires_at"`
+	RemainingMs int64  `json:"remaining_ms"`
+}
+
+// ListRevertLeasesArgs 查询自动恢复租约请求参数
+type ListRevertLeasesArgs struct{}
+
+// ListRevertLeasesReply 查询自动恢复租约响应
+type ListRevertLeasesReply struct {
+	Leases []RevertLeaseModel `json:"leases"`
+}
+
+// ListRevertLeases 列出尚未到期的自动恢复租约
+func (t *ToolkitService) ListRevertLeases(_ *ListRevertLeasesArgs, reply *ListRevertLeasesReply) error {
+	reply.Leases = globalRevertLeases.list()
+	return nil
+}
+
+// ExtendRevertLeaseArgs 续期租约请求参数，到期时间重置为 now + extend_ms
+type ExtendRevertLeaseArgs struct {
+	LeaseId  int64  `json:"lease_id"`
+	ExtendMs uint32 `json:"extend_ms"`
+}
+
+// ExtendRevertLeaseReply 续期租约响应
+type ExtendRevertLeaseReply struct {
+	Success   bool   `json:"success"`
+	ExpiresAt string `json:"expires_at"`
+}
+
+// ExtendRevertLease 续期自动恢复租约（前端心跳）
+func (t *ToolkitService) ExtendRevertLease(args *ExtendRevertLeaseArgs, reply *ExtendRevertLeaseReply) error {
+	if args.ExtendMs == 0 {
+		err := fmt.Errorf("extend_ms must be > 0")
+		auditWrite("extend_revert_lease", map[string]any{"lease_id": args.LeaseId, "extend_ms": args.ExtendMs}, err)
+		return err
+	}
+	if err := validateAutoRevertMs(args.ExtendMs); err != nil {
+		auditWrite("extend_revert_lease", map[string]any{"lease_id": args.LeaseId, "extend_ms": args.ExtendMs}, err)
+		return err
+	}
+
+	expiresAt, err := globalRevertLeases.extend(args.LeaseId, time.Duration(args.ExtendMs)*time.Millisecond)
+	if err != nil {
+		auditWrite("extend_revert_lease", map[string]any{"lease_id": args.LeaseId, "extend_ms": args.ExtendMs}, err)
+		return err
+	}
+
+	reply.Success = true
+	reply.ExpiresAt = expiresAt.Format(time.RFC3339)
+	auditWrite("extend_revert_lease", map[string]any{"lease_id": args.LeaseId, "extend_ms": args.ExtendMs}, nil)
+	return nil
+}
+
+// CancelRevertLeaseArgs 取消租约请求参数
+type CancelRevertLeaseArgs struct {
+	LeaseId int64 `json:"lease_id"`
+}
+
+// CancelRevertLeaseReply 取消租约响应
+type CancelRevertLeaseReply struct {
+	Success bool `json:"success"`
+}
+
+// CancelRevertLease 取消自动恢复，保留当前修改
+func (t *ToolkitService) CancelRevertLease(args *CancelRevertLeaseArgs, reply *CancelRevertLeaseReply) error {
+	if err := globalRevertLeases.cancel(args.LeaseId); err != nil {
+		auditWrite("cancel_revert_lease", map[string]any{"lease_id": args.LeaseId}, err)
+		return err
+	}
+	reply.Success = true
+	auditWrite("cancel_revert_lease", map[string]any{"lease_id": args.LeaseId}, nil)
+	return nil
+}
@@ -0,0 +1,236 @@
+package service
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/OpenSysKit/backend/internal/driver"
+)
+
+// 状态台账记录的内核修改类型。
+const (
+	StateKindHidden    = "hidden"
+	StateKindFrozen    = "frozen"
+	StateKindProtected = "protected"
+	StateKindElevated  = "elevated"
+)
+
+// StateLedgerEntry 一条由本后端造成、尚未恢复的内核状态修改。
+type StateLedgerEntry struct {
+	ID            int64  `json:"id"`
+	Kind          string `json:"kind"`
+	ProcessId     uint32 `json:"process_id"`
+	ProcessKey    string `json:"process_key,omitempty"`
+	ImageName     string `json:"image_name"`
+	OriginalValue string `json:"original_value"`
+	CurrentValue  string `json:"current_value"`
+	Revertible    bool   `json:"revertible"`
+	SafeRevert    bool   `json:"safe_revert"`
+	ModifiedAt    string `json:"modified_at"`
+}
+
+type stateLedgerKey struct {
+	kind string
+	pid  uint32
+}
+
+type stateLedger struct {
+	mu      sync.Mutex
+	entries map[stateLedgerKey]StateLedgerEntry
+}
+
+var (
+	globalStateLedger = &stateLedger{entries: make(map[stateLedgerKey]StateLedgerEntry)}
+	stateLedgerIDSeq  atomic.Int64
+)
+
+// record 登记一次修改；同一 (kind, pid) 重复修改时保留最初的原始值与进程标识。
+func (l *stateLedger) record(kind string, pid uint32, processKey string, original string, current string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	key := stateLedgerKey{kind: kind, pid: pid}
+	now := time.Now().Format(time.RFC3339)
+	if e, ok := l.entries[key]; ok {
+		e.CurrentValue = current
+		e.ModifiedAt = now
+		l.entries[key] = e
+		return
+	}
+
+	l.entries[key] = StateLedgerEntry{
+		ID:            stateLedgerIDSeq.Add(1),
+		Kind:          kind,
+		ProcessId:     pid,
+		ProcessKey:    processKey,
+		ImageName:     processImageBaseName(pid),
+		OriginalValue: original,
+		CurrentValue:  current,
+		Revertible:    kind != StateKindElevated,
+		SafeRevert:    kind == StateKindHidden || kind == StateKindFrozen,
+		ModifiedAt:    now,
+	}
+}
+
+// clear 在对应的逆操作成功后移除记录，并撤销同一修改上挂着的自动恢复租约。
+func (l *stateLedger) clear(kind string, pid uint32) {
+	l.mu.Lock()
+	delete(l.entries, stateLedgerKey{kind: kind, pid: pid})
+	l.mu.Unlock()
+	globalRevertLeases.dropFor(kind, pid)
+}
+
+func (l *stateLedger) has(kind string, pid uint32) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	_, ok := l.entries[stateLedgerKey{kind: kind, pid: pid}]
+	return ok
+}
+
+func (l *stateLedger) processKey(kind string, pid uint32) string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.entries[stateLedgerKey{kind: kind, pid: pid}].ProcessKey
+}
+
+func (l *stateLedger) list() []StateLedgerEntry {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	out := make([]StateLedgerEntry, 0, len(l.entries))
+	for _, e := range l.entries {
+		out = append(out, e)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].ID < out[j].ID })
+	return out
+}
+
+// ListModifiedStateArgs 查询已修改内核状态请求参数
+type ListModifiedStateArgs struct{}
+
+// ListModifiedStateReply 查询已修改内核状态响应
+type ListModifiedStateReply struct {
+	Entries []StateLedgerEntry `json:"entries"`
+}
+
+// ListModifiedState 列出本后端造成且尚未恢复的隐藏/冻结/保护/提权修改
+func (t *ToolkitService) ListModifiedState(_ *ListModifiedStateArgs, reply *ListModifiedStateReply) error {
+	reply.Entries = globalStateLedger.list()
+	return nil
+}
+
+// RevertAllArgs 批量恢复请求参数
+// Kinds 为空表示全部类型；SafeOnly 仅恢复解冻/取消隐藏这类无副作用的项。
+type RevertAllArgs struct {
+	Kinds    []string `json:"kinds"`
+	SafeOnly bool     `json:"safe_only"`
+}
+
+// StateRevertResult 单条台账记录的恢复结果
+type StateRevertResult struct {
+	ID        int64  `json:"id"`
+	Kind      string `json:"kind"`
+	ProcessId uint32 `json:"process_id"`
+	Success   bool   `json:"success"`
+	Error     string `json:"error,omitempty"`
+}
+
+// RevertAllReply 批量恢复响应
+type RevertAllReply struct {
+	Reverted int                 `json:"reverted"`
+	Failed   int                 `json:"failed"`
+	Skipped  int                 `json:"skipped"`
+	Results  []StateRevertResult `json:"results"`
+}
+
+// RevertAll 按台账逐项执行逆操作（取消隐藏、解冻、取消保护），提权不可恢复会被跳过
+func (t *ToolkitService) RevertAll(args *RevertAllArgs, reply *RevertAllReply) error {
+	if t.Driver == nil {
+		err := fmt.Errorf("驱动未加载")
+		auditWrite("revert_all", map[string]any{"kinds": args.Kinds, "safe_only": args.SafeOnly}, err)
+		return err
+	}
+
+	kinds := make(map[string]struct{}, len(args.Kinds))
+	for _, k := range args.Kinds {
+		switch k {
+		case StateKindHidden, StateKindFrozen, StateKindProtected, StateKindElevated:
+			kinds[k] = struct{}{}
+		default:
+			err := fmt.Errorf("kinds 仅支持 hidden/frozen/protected/elevated")
+			auditWrite("revert_all", map[string]any{"kinds": args.Kinds, "safe_only": args.SafeOnly}, err)
+			return err
+		}
+	}
+
+	reply.Results = make([]StateRevertResult, 0, 8)
+	for _, e := range globalStateLedger.list() {
+		if len(kinds) > 0 {
+			if _, ok := kinds[e.Kind]; !ok {
+				continue
+			}
+		}
+		if !e.Revertible || (args.SafeOnly && !e.SafeRevert) {
+			reply.Skipped++
+			continue
+		}
+
+		res := StateRevertResult{ID: e.ID, Kind: e.Kind, ProcessId: e.ProcessId}
+		if err := t.revertState(e.Kind, e.ProcessId); err != nil {
+			res.Error = err.Error()
+			reply.Failed++
+		} else {
+			res.Success = true
+			reply.Reverted++
+		}
+		reply.Results = append(reply.Results, res)
+	}
+
+	auditWrite("revert_all", map[string]any{
+		"kinds":     args.Kinds,
+		"safe_only": args.SafeOnly,
+		"reverted":  reply.Reverted,
+		"failed":    reply.Failed,
+		"skipped":   reply.Skipped,
+	}, nil)
+	return nil
+}
+
+// revertState 通过 RPC 的内部实现执行逆操作，复用其审计与台账清理；
+// 这类恢复由系统发起，不登记撤销日志，避免之后的 Undo 把特意释放的状态重新施加回去。
+// 冻结/保护带上登记时的进程标识，PID 被复用时拒绝作用到新进程；
+// 隐藏的进程可能无法用 OpenProcess 打开，不做标识校验。
+func (t *ToolkitService) revertState(kind string, pid uint32) error {
+	switch kind {
+	case StateKindHidden:
+		return t.unhideProcess(&UnhideProcessArgs{ProcessId: pid}, &UnhideProcessReply{})
+	case StateKindFrozen:
+		key := globalStateLedger.processKey(kind, pid)
+		return t.unfreezeProcess(&UnfreezeProcessArgs{ProcessId: pid, ProcessKey: key}, &UnfreezeProcessReply{})
+	case StateKindProtected:
+		key := globalStateLedger.processKey(kind, pid)
+		_, err := t.unprotectProcess(&UnprotectProcessArgs{ProcessId: pid, ProcessKey: key}, &UnprotectProcessReply{})
+		return err
+	default:
+		return fmt.Errorf("%s 不支持恢复", kind)
+	}
+}
+
+// RevertModifiedState 供主进程在释放驱动前调用，按策略恢复台账中的修改。
+// 挂有自动恢复租约的修改无论策略如何都会先行恢复；safeOnly 为 true 时其余项仅解冻与取消隐藏。
+func RevertModifiedState(dev driver.Device, safeOnly bool) RevertAllReply {
+	var reply RevertAllReply
+	if dev == nil {
+		return reply
+	}
+	t := &ToolkitService{Driver: dev}
+	globalRevertLeases.expireAll(t)
+	_ = t.RevertAll(&RevertAllArgs{SafeOnly: safeOnly}, &reply)
+	return reply
+}
+
+func formatProtectionLevel(level uint8) string {
+	return fmt.Sprintf("0x%02X", level)
+}
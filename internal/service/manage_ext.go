@@ -21,6 +21,9 @@ type ProcessTreeNode struct {
 	ImageName       string            `json:"image_name"`
 	ThreadCount     uint32            `json:"thread_count"`
 	WorkingSetSize  uint64            `json:"working_set_size"`
+	CreateTime      uint64            `json:"create_time"`
+	ProcessKey      string            `json:"process_key"`
+	IdentitySource  string            `json:"identity_source"`
 	Children        []ProcessTreeNode `json:"children"`
 }
 
@@ -46,25 +49,35 @@ func (t *ToolkitService) GetProcessTree(_ *ProcessTreeArgs, reply *ProcessTreeRe
 // KillProcessTreeArgs 结束进程子树请求参数
 type KillProcessTreeArgs struct {
 	ProcessId    uint32 `json:"process_id"`
+	ProcessKey   string `json:"process_key,omitempty"`
 	IncludeRoot  bool   `json:"include_root"`
 	LeavesFirst  bool   `json:"leaves_first"`
 	StrictErrors bool   `json:"strict_errors"`
+	DryRun       bool   `json:"dry_run"`
+	ConfirmToken string `json:"confirm_token"`
+	Force        bool   `json:"force"`
 }
 
 // KillProcessTreeReply 结束进程子树响应
 type KillProcessTreeReply struct {
-	TargetProcessId uint32       `json:"target_process_id"`
-	OrderedPids     []uint32     `json:"ordered_pids"`
-	Results         []KillResult `json:"results"`
+	TargetProcessId uint32            `json:"target_process_id"`
+	OrderedPids     []uint32          `json:"ordered_pids"`
+	Results         []KillResult      `json:"results"`
+	Plan            *ConfirmPlanModel `json:"plan,omitempty"`
 }
 
 // KillProcessTree 按子树顺序结束进程（默认叶子优先）。
+// 需先以 dry_run 取得计划与确认令牌，再携带 confirm_token 提交。
 func (t *ToolkitService) KillProcessTree(args *KillProcessTreeArgs, reply *KillProcessTreeReply) error {
 	if t.Driver == nil {
 		err := fmt.Errorf("驱动未加载")
 		auditWrite("kill_process_tree", map[string]any{"process_id": args.ProcessId}, err)
 		return err
 	}
+	if err := t.verifyProcessKey(args.ProcessId, args.ProcessKey); err != nil {
+		auditWrite("kill_process_tree", map[string]any{"process_id": args.ProcessId, "process_key": args.ProcessKey}, err)
+		return err
+	}
 	if args.ProcessId == 0 {
 		err := fmt.Errorf("process_id must be > 0")
 		auditWrite("kill_process_tree", map[string]any{"process_id": args.ProcessId}, err)
@@ -77,12 +90,55 @@ func (t *ToolkitService) KillProcessTree(args *KillProcessTreeArgs, reply *KillP
 		return err
 	}
 
+	names := make(map[uint32]string, len(processes))
+	for _, p := range processes {
+		names[p.ProcessId] = p.ImageName
+	}
 	order := collectSubtreeOrder(processes, args.ProcessId, args.IncludeRoot, args.LeavesFirst)
+	current := make([]PlanTargetModel, 0, len(order))
+	for _, pid := range order {
+		pt, err := t.processPlanTarget(pid, names[pid])
+		if err != nil {
+			auditWrite("kill_process_tree", map[string]any{"process_id": args.ProcessId}, err)
+			return err
+		}
+		current = append(current, pt)
+	}
+
+	scope := fmt.Sprintf("%d|%t|%t", args.ProcessId, args.IncludeRoot, args.LeavesFirst)
+	plan, targets, err := confirmGate("kill_process_tree", scope, args.DryRun, args.ConfirmToken, current)
+	if err != nil {
+		auditWrite("kill_process_tree", map[string]any{"process_id": args.ProcessId}, err)
+		return err
+	}
+
 	reply.TargetProcessId = args.ProcessId
-	reply.OrderedPids = order
-	reply.Results = make([]KillResult, 0, len(order))
+	if plan != nil {
+		reply.OrderedPids = order
+		reply.Results = make([]KillResult, 0)
+		reply.Plan = plan
+		auditWrite("kill_process_tree", map[string]any{"process_id": args.ProcessId, "dry_run": true, "targets": len(order)}, nil)
+		return nil
+	}
+
+	reply.OrderedPids = make([]uint32, 0, len(targets))
+	for _, target := range targets {
+		reply.OrderedPids = append(reply.OrderedPids, target.ProcessId)
+	}
+	reply.Results = make([]KillResult, 0, len(targets))
+
+	for _, target := range targets {
+		pid := target.ProcessId
+		if err := t.guardTarget("kill_process_tree", pid, target.ImageName, args.Force); err != nil {
+			reply.Results = append(reply.Results, KillResult{ProcessId: pid, Success: false, Error: err.Error()})
+			if args.StrictErrors {
+				retErr := fmt.Errorf("结束子树进程失败(pid=%d): %w", pid, err)
+				auditWrite("kill_process_tree", map[string]any{"process_id": args.ProcessId, "failed_pid": pid}, retErr)
+				return retErr
+			}
+			continue
+		}
 
-	for _, pid := range order {
 		result, err := executeKillProcess(t.Driver, pid)
 		if err != nil {
 			kr := KillResult{
@@ -301,9 +357,12 @@ func (t *ToolkitService) WatchHandleStats(args *WatchHandleStatsArgs, reply *Wat
 
 // ResolvePortConflictArgs 端口冲突处置请求参数
 type ResolvePortConflictArgs struct {
-	Port     uint16 `json:"port"`
-	Protocol string `json:"protocol"` // all/tcp/udp
-	Action   string `json:"action"`   // kill/disconnect
+	Port         uint16 `json:"port"`
+	Protocol     string `json:"protocol"` // all/tcp/udp
+	Action       string `json:"action"`   // kill/disconnect
+	DryRun       bool   `json:"dry_run"`
+	ConfirmToken string `json:"confirm_token"` // action=kill 时必填
+	Force        bool   `json:"force"`
 }
 
 // PortConflictConnection 命中的端口连接
@@ -336,6 +395,7 @@ type ResolvePortConflictReply struct {
 	Summary  string                     `json:"summary"`
 	Matches  []PortConflictConnection   `json:"matches"`
 	Results  []PortConflictActionResult `json:"results"`
+	Plan     *ConfirmPlanModel          `json:"plan,omitempty"`
 }
 
 // ResolvePortConflict 按端口执行“断连”或“结束占用进程”
@@ -415,11 +475,40 @@ func (t *ToolkitService) ResolvePortConflict(args *ResolvePortConflictArgs, repl
 			return retErr
 		}
 
-		for pid, name := range pids {
+		sortedPids := make([]uint32, 0, len(pids))
+		for pid := range pids {
+			sortedPids = append(sortedPids, pid)
+		}
+		sort.Slice(sortedPids, func(i, j int) bool { return sortedPids[i] < sortedPids[j] })
+		current := make([]PlanTargetModel, 0, len(sortedPids))
+		for _, pid := range sortedPids {
+			pt, ptErr := t.processPlanTarget(pid, pids[pid])
+			if ptErr != nil {
+				auditWrite("resolve_port_conflict", map[string]any{"port": args.Port, "protocol": protocol, "action": action, "matches": len(matches)}, ptErr)
+				return ptErr
+			}
+			current = append(current, pt)
+		}
+
+		scope := fmt.Sprintf("%d|%s", args.Port, protocol)
+		plan, targets, gateErr := confirmGate("resolve_port_conflict", scope, args.DryRun, args.ConfirmToken, current)
+		if gateErr != nil {
+			auditWrite("resolve_port_conflict", map[string]any{"port": args.Port, "protocol": protocol, "action": action, "matches": len(matches)}, gateErr)
+			return gateErr
+		}
+		if plan != nil {
+			reply.Plan = plan
+			reply.Summary = fmt.Sprintf("匹配连接 %d 条，计划结束进程 %d 个", len(matches), len(current))
+			auditWrite("resolve_port_conflict", map[string]any{"port": args.Port, "protocol": protocol, "action": action, "matches": len(matches), "dry_run": true}, nil)
+			return nil
+		}
+
+		for _, target := range targets {
+			pid, name := target.ProcessId, target.ImageName
 			res := PortConflictActionResult{ProcessId: pid, Method: "kill_process"}
-			if pid <= 4 || isHighRiskProcessName(name) {
+			if err := t.guardTarget("resolve_port_conflict", pid, name, args.Force); err != nil {
 				res.Success = false
-				res.Error = "高风险系统进程，拒绝结束"
+				res.Error = err.Error()
 				reply.Results = append(reply.Results, res)
 				continue
 			}
@@ -444,6 +533,11 @@ func (t *ToolkitService) ResolvePortConflict(args *ResolvePortConflictArgs, repl
 			auditWrite("resolve_port_conflict", map[string]any{"port": args.Port, "protocol": protocol, "action": action, "matches": len(matches)}, retErr)
 			return retErr
 		}
+		if args.DryRun {
+			reply.Summary = fmt.Sprintf("匹配连接 %d 条，dry_run 未执行断连", len(matches))
+			auditWrite("resolve_port_conflict", map[string]any{"port": args.Port, "protocol": protocol, "action": action, "matches": len(matches), "dry_run": true}, nil)
+			return nil
+		}
 
 		rows, disErr := disconnectTCPByLocalPort(args.Port, tcpPids)
 		if disErr != nil {
@@ -478,19 +572,28 @@ func (t *ToolkitService) ResolvePortConflict(args *ResolvePortConflictArgs, repl
 	return nil
 }
 
+// highRiskProcessNames 内置关键系统映像，结束或冻结可能导致系统崩溃。
+var highRiskProcessNames = []string{
+	"system", "registry", "smss.exe", "csrss.exe", "wininit.exe", "winlogon.exe",
+	"services.exe", "lsass.exe", "lsaiso.exe", "svchost.exe",
+}
+
 func isHighRiskProcessName(name string) bool {
 	n := strings.ToLower(strings.TrimSpace(name))
-	switch n {
-	case "smss.exe", "csrss.exe", "wininit.exe", "winlogon.exe", "services.exe", "lsass.exe", "svchost.exe":
-		return true
-	default:
-		return false
+	for _, h := range highRiskProcessNames {
+		if n == h {
+			return true
+		}
 	}
+	return false
 }
 
-// ThreadActionArgs 线程动作请求参数
+// ThreadActionArgs 线程动作请求参数。
+// ProcessId 非 0 时校验线程仍属于该进程，ProcessKey 非空时再校验该进程未被 PID 复用；撤销操作会带上二者。
 type ThreadActionArgs struct {
-	ThreadId uint32 `json:"thread_id"`
+	ThreadId   uint32 `json:"thread_id"`
+	ProcessId  uint32 `json:"process_id,omitempty"`
+	ProcessKey string `json:"process_key,omitempty"`
 }
 
 // ThreadActionReply 线程动作响应
@@ -506,16 +609,25 @@ func (t *ToolkitService) SuspendThread(args *ThreadActionArgs, reply *ThreadActi
 		auditWrite("suspend_thread", map[string]any{"thread_id": args.ThreadId}, err)
 		return err
 	}
-	count, err := suspendThread(args.ThreadId)
+	if err := t.verifyProcessKey(args.ProcessId, args.ProcessKey); err != nil {
+		auditWrite("suspend_thread", map[string]any{"thread_id": args.ThreadId, "process_id": args.ProcessId, "process_key": args.ProcessKey}, err)
+		return err
+	}
+	count, owner, err := suspendThread(args.ThreadId, args.ProcessId)
 	if err != nil {
 		reply.Success = false
 		retErr := fmt.Errorf("挂起线程失败: %w", err)
-		auditWrite("suspend_thread", map[string]any{"thread_id": args.ThreadId}, retErr)
+		auditWrite("suspend_thread", map[string]any{"thread_id": args.ThreadId, "process_id": args.ProcessId}, retErr)
 		return retErr
 	}
 	reply.Success = true
 	reply.SuspendCount = count
-	auditWrite("suspend_thread", map[string]any{"thread_id": args.ThreadId, "suspend_count": count}, nil)
+	tid := args.ThreadId
+	key := t.currentProcessKey(owner)
+	journalPush("suspend_thread", map[string]any{"thread_id": tid, "process_id": owner, "process_key": key}, "resume_thread", func(t *ToolkitService) error {
+		return t.ResumeThread(&ThreadActionArgs{ThreadId: tid, ProcessId: owner, ProcessKey: key}, &ThreadActionReply{})
+	})
+	auditWrite("suspend_thread", map[string]any{"thread_id": args.ThreadId, "process_id": owner, "suspend_count": count}, nil)
 	return nil
 }
 
@@ -526,22 +638,35 @@ func (t *ToolkitService) ResumeThread(args *ThreadActionArgs, reply *ThreadActio
 		auditWrite("resume_thread", map[string]any{"thread_id": args.ThreadId}, err)
 		return err
 	}
-	count, err := resumeThread(args.ThreadId)
+	if err := t.verifyProcessKey(args.ProcessId, args.ProcessKey); err != nil {
+		auditWrite("resume_thread", map[string]any{"thread_id": args.ThreadId, "process_id": args.ProcessId, "process_key": args.ProcessKey}, err)
+		return err
+	}
+	count, owner, err := resumeThread(args.ThreadId, args.ProcessId)
 	if err != nil {
 		reply.Success = false
 		retErr := fmt.Errorf("恢复线程失败: %w", err)
-		auditWrite("resume_thread", map[string]any{"thread_id": args.ThreadId}, retErr)
+		auditWrite("resume_thread", map[string]any{"thread_id": args.ThreadId, "process_id": args.ProcessId}, retErr)
 		return retErr
 	}
 	reply.Success = true
 	reply.SuspendCount = count
-	auditWrite("resume_thread", map[string]any{"thread_id": args.ThreadId, "suspend_count": count}, nil)
+	// 返回的是恢复前的挂起计数，为 0 说明线程原本就在运行，无需登记撤销
+	if count > 0 {
+		tid := args.ThreadId
+		key := t.currentProcessKey(owner)
+		journalPush("resume_thread", map[string]any{"thread_id": tid, "process_id": owner, "process_key": key}, "suspend_thread", func(t *ToolkitService) error {
+			return t.SuspendThread(&ThreadActionArgs{ThreadId: tid, ProcessId: owner, ProcessKey: key}, &ThreadActionReply{})
+		})
+	}
+	auditWrite("resume_thread", map[string]any{"thread_id": args.ThreadId, "process_id": owner, "suspend_count": count}, nil)
 	return nil
 }
 
 // ListServicesArgs 服务枚举请求参数
 type ListServicesArgs struct {
-	NameLike string `json:"name_like"`
+	NameLike string     `json:"name_like"`
+	Query    *QuerySpec `json:"query,omitempty"`
 }
 
 // ServiceInfoModel 服务信息
@@ -555,6 +680,7 @@ type ServiceInfoModel struct {
 // ListServicesReply 服务枚举响应
 type ListServicesReply struct {
 	Services []ServiceInfoModel `json:"services"`
+	Page     *QueryPageModel    `json:"page"`
 }
 
 // ListServices 枚举服务并返回状态/启动类型
@@ -563,7 +689,12 @@ func (t *ToolkitService) ListServices(args *ListServicesArgs, reply *ListService
 	if err != nil {
 		return fmt.Errorf("枚举服务失败: %w", err)
 	}
+	services, page, err := applyQuery(services, args.Query)
+	if err != nil {
+		return err
+	}
 	reply.Services = services
+	reply.Page = page
 	return nil
 }
 
@@ -628,6 +759,7 @@ func (t *ToolkitService) StartService(args *ServiceActionArgs, reply *ServiceAct
 		auditWrite("start_service", map[string]any{"name": args.Name}, err)
 		return err
 	}
+	prevState, _ := queryWindowsServiceState(args.Name)
 	if err := startWindowsService(args.Name); err != nil {
 		reply.Success = false
 		retErr := fmt.Errorf("启动服务失败: %w", err)
@@ -635,6 +767,12 @@ func (t *ToolkitService) StartService(args *ServiceActionArgs, reply *ServiceAct
 		return retErr
 	}
 	reply.Success = true
+	if prevState == "stopped" {
+		name := args.Name
+		journalPush("start_service", map[string]any{"name": name}, "stop_service", func(t *ToolkitService) error {
+			return t.StopService(&ServiceActionArgs{Name: name}, &ServiceActionReply{})
+		})
+	}
 	auditWrite("start_service", map[string]any{"name": args.Name}, nil)
 	return nil
 }
@@ -646,6 +784,7 @@ func (t *ToolkitService) StopService(args *ServiceActionArgs, reply *ServiceActi
 		auditWrite("stop_service", map[string]any{"name": args.Name}, err)
 		return err
 	}
+	prevState, _ := queryWindowsServiceState(args.Name)
 	if err := stopWindowsService(args.Name); err != nil {
 		reply.Success = false
 		retErr := fmt.Errorf("停止服务失败: %w", err)
@@ -653,6 +792,12 @@ func (t *ToolkitService) StopService(args *ServiceActionArgs, reply *ServiceActi
 		return retErr
 	}
 	reply.Success = true
+	if prevState == "running" {
+		name := args.Name
+		journalPush("stop_service", map[string]any{"name": name}, "start_service", func(t *ToolkitService) error {
+			return t.StartService(&ServiceActionArgs{Name: name}, &ServiceActionReply{})
+		})
+	}
 	auditWrite("stop_service", map[string]any{"name": args.Name}, nil)
 	return nil
 }
@@ -665,7 +810,8 @@ type SetServiceStartTypeArgs struct {
 
 // SetServiceStartTypeReply 设置服务启动类型响应
 type SetServiceStartTypeReply struct {
-	Success bool `json:"success"`
+	Success           bool   `json:"success"`
+	PreviousStartType string `json:"previous_start_type"`
 }
 
 // SetServiceStartType 修改服务启动类型（auto/manual/disabled）
@@ -675,20 +821,30 @@ func (t *ToolkitService) SetServiceStartType(args *SetServiceStartTypeArgs, repl
 		auditWrite("set_service_start_type", map[string]any{"name": args.Name, "start_type": args.StartType}, err)
 		return err
 	}
-	if err := setWindowsServiceStartType(args.Name, args.StartType); err != nil {
+	previous, err := setWindowsServiceStartType(args.Name, args.StartType)
+	if err != nil {
 		reply.Success = false
 		retErr := fmt.Errorf("修改服务启动类型失败: %w", err)
 		auditWrite("set_service_start_type", map[string]any{"name": args.Name, "start_type": args.StartType}, retErr)
 		return retErr
 	}
 	reply.Success = true
-	auditWrite("set_service_start_type", map[string]any{"name": args.Name, "start_type": args.StartType}, nil)
+	reply.PreviousStartType = previous
+	if previous == "auto" || previous == "manual" || previous == "disabled" {
+		name := args.Name
+		journalPush("set_service_start_type", map[string]any{"name": name, "start_type": args.StartType, "previous_start_type": previous}, "set_service_start_type", func(t *ToolkitService) error {
+			return t.SetServiceStartType(&SetServiceStartTypeArgs{Name: name, StartType: previous}, &SetServiceStartTypeReply{})
+		})
+	}
+	auditWrite("set_service_start_type", map[string]any{"name": args.Name, "start_type": args.StartType, "previous_start_type": previous}, nil)
 	return nil
 }
 
-// ApplyProtectTemplateArgs 策略模板请求参数
+// ApplyProtectTemplateArgs 策略模板请求参数。
+// Template 为 custom 时按 DenyAccess 中的权限名（如 "PROCESS_TERMINATE|PROCESS_VM_WRITE"）下发。
 type ApplyProtectTemplateArgs struct {
-	Template string `json:"template"`
+	Template   string `json:"template"`
+	DenyAccess string `json:"deny_access"`
 }
 
 // ApplyProtectTemplateReply 策略模板响应
@@ -697,6 +853,14 @@ type ApplyProtectTemplateReply struct {
 	Template       string `json:"template"`
 	Version        uint32 `json:"version"`
 	DenyAccessMask uint32 `json:"deny_access_mask"`
+	DenyAccess     string `json:"deny_access"`
+}
+
+// protectTemplates 内置保护模板拒绝的进程访问权限
+var protectTemplates = map[string]string{
+	"low":    "PROCESS_TERMINATE",
+	"medium": "PROCESS_TERMINATE|PROCESS_SUSPEND_RESUME",
+	"high":   "PROCESS_TERMINATE|PROCESS_CREATE_THREAD|PROCESS_VM_OPERATION|PROCESS_VM_WRITE|PROCESS_DUP_HANDLE|PROCESS_SET_INFORMATION|PROCESS_SUSPEND_RESUME",
 }
 
 // ApplyProtectTemplate 按模板下发 WinDrive 进程保护策略
@@ -712,19 +876,27 @@ func (t *ToolkitService) ApplyProtectTemplate(args *ApplyProtectTemplateArgs, re
 		template = "medium"
 	}
 
-	var mask uint32
-	switch template {
-	case "low":
-		mask = 0x00000001 // PROCESS_TERMINATE
-	case "medium":
-		mask = 0x00000801 // TERMINATE + SUSPEND_RESUME
-	case "high":
-		mask = 0x00000A6B // TERMINATE + CREATE_THREAD + VM_OPERATION + VM_WRITE + DUP_HANDLE + SET_INFORMATION + SUSPEND_RESUME
-	default:
-		err := fmt.Errorf("template 仅支持 low/medium/high")
+	spec, ok := protectTemplates[template]
+	if template == "custom" {
+		spec, ok = args.DenyAccess, true
+		if strings.TrimSpace(spec) == "" {
+			err := fmt.Errorf("template=custom 时 deny_access 不能为空")
+			auditWrite("apply_protect_template", map[string]any{"template": args.Template}, err)
+			return err
+		}
+	}
+	if !ok {
+		err := fmt.Errorf("template 仅支持 low/medium/high/custom")
 		auditWrite("apply_protect_template", map[string]any{"template": args.Template}, err)
 		return err
 	}
+	mask, err := encodeAccessMask("Process", spec)
+	if err != nil {
+		retErr := fmt.Errorf("deny_access 无效: %w", err)
+		auditWrite("apply_protect_template", map[string]any{"template": template, "deny_access": spec}, retErr)
+		return retErr
+	}
+	denyAccess := formatAccessMask("Process", mask)
 
 	req := driver.ProtectPolicyRequest{
 		Version:        1,
@@ -738,7 +910,7 @@ func (t *ToolkitService) ApplyProtectTemplate(args *ApplyProtectTemplateArgs, re
 	if _, err := t.WinDriveDriver.IoControl(driver.IOCTL_WINDRIVE_SET_PROTECT_POLICY, inBuf.Bytes(), 0); err != nil {
 		reply.Success = false
 		retErr := fmt.Errorf("设置保护策略失败: %w", err)
-		auditWrite("apply_protect_template", map[string]any{"template": template, "deny_access_mask": mask}, retErr)
+		auditWrite("apply_protect_template", map[string]any{"template": template, "deny_access_mask": mask, "deny_access": denyAccess}, retErr)
 		return retErr
 	}
 
@@ -746,7 +918,8 @@ func (t *ToolkitService) ApplyProtectTemplate(args *ApplyProtectTemplateArgs, re
 	reply.Template = template
 	reply.Version = 1
 	reply.DenyAccessMask = mask
-	auditWrite("apply_protect_template", map[string]any{"template": template, "deny_access_mask": mask}, nil)
+	reply.DenyAccess = denyAccess
+	auditWrite("apply_protect_template", map[string]any{"template": template, "deny_access_mask": mask, "deny_access": denyAccess}, nil)
 	return nil
 }
 
@@ -763,9 +936,17 @@ func (t *ToolkitService) getProcessList() ([]ProcessInfoModel, error) {
 
 func buildProcessTree(processes []ProcessInfoModel) []ProcessTreeNode {
 	byPID := make(map[uint32]ProcessInfoModel, len(processes))
-	children := make(map[uint32][]ProcessInfoModel, len(processes))
 	for _, p := range processes {
 		byPID[p.ProcessId] = p
+	}
+	children := make(map[uint32][]ProcessInfoModel, len(processes))
+	orphans := make(map[uint32]struct{})
+	for _, p := range processes {
+		// 父 PID 已被复用时不挂到新进程下，作为根节点展示
+		if parent, ok := byPID[p.ParentProcessId]; ok && !isParentLinkValid(parent, p) {
+			orphans[p.ProcessId] = struct{}{}
+			continue
+		}
 		children[p.ParentProcessId] = append(children[p.ParentProcessId], p)
 	}
 	for k := range children {
@@ -777,7 +958,8 @@ func buildProcessTree(processes []ProcessInfoModel) []ProcessTreeNode {
 	var roots []ProcessInfoModel
 	for _, p := range processes {
 		_, parentExists := byPID[p.ParentProcessId]
-		if p.ParentProcessId == 0 || !parentExists || p.ParentProcessId == p.ProcessId {
+		_, orphan := orphans[p.ProcessId]
+		if p.ParentProcessId == 0 || !parentExists || orphan || p.ParentProcessId == p.ProcessId {
 			roots = append(roots, p)
 		}
 	}
@@ -791,6 +973,9 @@ func buildProcessTree(processes []ProcessInfoModel) []ProcessTreeNode {
 			ImageName:       p.ImageName,
 			ThreadCount:     p.ThreadCount,
 			WorkingSetSize:  p.WorkingSetSize,
+			CreateTime:      p.CreateTime,
+			ProcessKey:      p.ProcessKey,
+			IdentitySource:  p.IdentitySource,
 		}
 		if depth >= 64 {
 			return node
@@ -812,8 +997,15 @@ func buildProcessTree(processes []ProcessInfoModel) []ProcessTreeNode {
 }
 
 func collectSubtreeOrder(processes []ProcessInfoModel, pid uint32, includeRoot bool, leavesFirst bool) []uint32 {
+	byPID := make(map[uint32]ProcessInfoModel, len(processes))
+	for _, p := range processes {
+		byPID[p.ProcessId] = p
+	}
 	children := make(map[uint32][]uint32, len(processes))
 	for _, p := range processes {
+		if parent, ok := byPID[p.ParentProcessId]; ok && !isParentLinkValid(parent, p) {
+			continue
+		}
 		children[p.ParentProcessId] = append(children[p.ParentProcessId], p.ProcessId)
 	}
 	for k := range children {
@@ -8,12 +8,12 @@ func enumThreadsByProcess(_ uint32) ([]ThreadInfoModel, error) {
 	return nil, fmt.Errorf("仅支持 Windows")
 }
 
-func suspendThread(_ uint32) (int32, error) {
-	return -1, fmt.Errorf("仅支持 Windows")
+func suspendThread(_, _ uint32) (int32, uint32, error) {
+	return -1, 0, fmt.Errorf("仅支持 Windows")
 }
 
-func resumeThread(_ uint32) (int32, error) {
-	return -1, fmt.Errorf("仅支持 Windows")
+func resumeThread(_, _ uint32) (int32, uint32, error) {
+	return -1, 0, fmt.Errorf("仅支持 Windows")
 }
 
 func listWindowsServices(_ string) ([]ServiceInfoModel, error) {
@@ -28,6 +28,14 @@ func stopWindowsService(_ string) error {
 	return fmt.Errorf("仅支持 Windows")
 }
 
-func setWindowsServiceStartType(_, _ string) error {
-	return fmt.Errorf("仅支持 Windows")
+func queryWindowsServiceState(_ string) (string, error) {
+	return "", fmt.Errorf("仅支持 Windows")
+}
+
+func queryServiceBinaryPath(_ string) (string, error) {
+	return "", fmt.Errorf("仅支持 Windows")
+}
+
+func setWindowsServiceStartType(_, _ string) (string, error) {
+	return "", fmt.Errorf("仅支持 Windows")
 }
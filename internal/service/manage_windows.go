@@ -20,6 +20,8 @@ var (
 	modKernel32       = windows.NewLazySystemDLL("kernel32.dll")
 	procSuspendThread = modKernel32.NewProc("SuspendThread")
 	procResumeThread  = modKernel32.NewProc("ResumeThread")
+
+	procGetProcessIdOfThread = modKernel32.NewProc("GetProcessIdOfThread")
 )
 
 func enumThreadsByProcess(pid uint32) ([]ThreadInfoModel, error) {
@@ -60,38 +62,37 @@ func enumThreadsByProcess(pid uint32) ([]ThreadInfoModel, error) {
 	return out, nil
 }
 
-func suspendThread(threadID uint32) (int32, error) {
-	h, err := windows.OpenThread(windows.THREAD_SUSPEND_RESUME, false, threadID)
-	if err != nil {
-		return -1, err
-	}
-	defer windows.CloseHandle(h)
+func suspendThread(threadID, expectPid uint32) (int32, uint32, error) {
+	return threadSuspendResume(procSuspendThread, threadID, expectPid)
+}
 
-	r1, _, callErr := procSuspendThread.Call(uintptr(h))
-	if r1 == 0xFFFFFFFF {
-		if callErr != syscall.Errno(0) {
-			return -1, callErr
-		}
-		return -1, windows.GetLastError()
-	}
-	return int32(r1), nil
+func resumeThread(threadID, expectPid uint32) (int32, uint32, error) {
+	return threadSuspendResume(procResumeThread, threadID, expectPid)
 }
 
-func resumeThread(threadID uint32) (int32, error) {
-	h, err := windows.OpenThread(windows.THREAD_SUSPEND_RESUME, false, threadID)
+// threadSuspendResume 打开线程执行 SuspendThread/ResumeThread，返回调用前的挂起计数与线程所属 PID。
+// expectPid 非 0 时先在同一句柄上核对所属进程，不一致（TID 已被复用）则不做操作。
+func threadSuspendResume(proc *windows.LazyProc, threadID, expectPid uint32) (int32, uint32, error) {
+	h, err := windows.OpenThread(windows.THREAD_SUSPEND_RESUME|windows.THREAD_QUERY_LIMITED_INFORMATION, false, threadID)
 	if err != nil {
-		return -1, err
+		return -1, 0, err
 	}
 	defer windows.CloseHandle(h)
 
-	r1, _, callErr := procResumeThread.Call(uintptr(h))
+	r0, _, _ := procGetProcessIdOfThread.Call(uintptr(h))
+	owner := uint32(r0)
+	if expectPid != 0 && owner != expectPid {
+		return -1, owner, fmt.Errorf("线程 %d 当前属于进程 %d 而不是 %d（TID 可能已被复用）", threadID, owner, expectPid)
+	}
+
+	r1, _, callErr := proc.Call(uintptr(h))
 	if r1 == 0xFFFFFFFF {
 		if callErr != syscall.Errno(0) {
-			return -1, callErr
+			return -1, owner, callErr
 		}
-		return -1, windows.GetLastError()
+		return -1, owner, windows.GetLastError()
 	}
-	return int32(r1), nil
+	return int32(r1), owner, nil
 }
 
 func listWindowsServices(nameLike string) ([]ServiceInfoModel, error) {
@@ -190,23 +191,65 @@ func stopWindowsService(name string) error {
 	return fmt.Errorf("停止服务超时")
 }
 
-func setWindowsServiceStartType(name string, startType string) error {
+func queryWindowsServiceState(name string) (string, error) {
 	m, err := mgr.Connect()
 	if err != nil {
-		return err
+		return "", err
 	}
 	defer m.Disconnect()
 
 	s, err := m.OpenService(name)
 	if err != nil {
-		return err
+		return "", err
+	}
+	defer s.Close()
+
+	st, err := s.Query()
+	if err != nil {
+		return "", err
+	}
+	return serviceStateToString(st.State), nil
+}
+
+func queryServiceBinaryPath(name string) (string, error) {
+	m, err := mgr.Connect()
+	if err != nil {
+		return "", err
+	}
+	defer m.Disconnect()
+
+	s, err := m.OpenService(name)
+	if err != nil {
+		return "", err
 	}
 	defer s.Close()
 
 	cfg, err := s.Config()
 	if err != nil {
-		return err
+		return "", err
+	}
+	return cfg.BinaryPathName, nil
+}
+
+// setWindowsServiceStartType 修改启动类型，返回修改前的启动类型
+func setWindowsServiceStartType(name string, startType string) (string, error) {
+	m, err := mgr.Connect()
+	if err != nil {
+		return "", err
+	}
+	defer m.Disconnect()
+
+	s, err := m.OpenService(name)
+	if err != nil {
+		return "", err
+	}
+	defer s.Close()
+
+	cfg, err := s.Config()
+	if err != nil {
+		return "", err
 	}
+	previous := serviceStartTypeToString(cfg.StartType)
 
 	switch strings.ToLower(strings.TrimSpace(startType)) {
 	case "auto":
@@ -216,10 +259,10 @@ func setWindowsServiceStartType(name string, startType string) error {
 	case "disabled":
 		cfg.StartType = mgr.StartDisabled
 	default:
-		return fmt.Errorf("start_type 仅支持 auto/manual/disabled")
+		return previous, fmt.Errorf("start_type 仅支持 auto/manual/disabled")
 	}
 
-	return s.UpdateConfig(cfg)
+	return previous, s.UpdateConfig(cfg)
 }
 
 func serviceStateToString(st svc.State) string {
@@ -0,0 +1,429 @@
+package service
+
+import (
+	"fmt"
+	"runtime"
+	"sync"
+	"time"
+
+	"github.com/OpenSysKit/backend/internal/driver"
+)
+
+const (
+	defaultMetricsIntervalMs = 2000
+	defaultMetricsRetentionS = 600
+	minMetricsIntervalMs     = 500
+	maxMetricsIntervalMs     = 60000
+	minMetricsRetentionS     = 60
+	maxMetricsRetentionS     = 24 * 60 * 60
+)
+
+// processCounters 用户态读取的单进程累计计数。
+type processCounters struct {
+	createTime  uint64
+	cpuTicks    uint64 // 内核态 + 用户态 CPU 时间，100ns
+	handleCount uint32
+}
+
+// MetricSampleModel 单个采样点。
+type MetricSampleModel struct {
+	Timestamp      string  `json:"timestamp"`
+	CpuPercent     float64 `json:"cpu_percent"`
+	WorkingSetSize uint64  `json:"working_set_size"`
+	ThreadCount    uint32  `json:"thread_count"`
+	HandleCount    uint32  `json:"handle_count"`
+}
+
+type metricPoint struct {
+	at         time.Time
+	cpuPercent float64
+	workingSet uint64
+	threads    uint32
+	handles    uint32
+}
+
+// metricSeries 单进程的环形缓冲区；PID 被复用（创建时间变化）时整体重置。
+type metricSeries struct {
+	imageName  string
+	createTime uint64
+	countersOK bool
+	points     []metricPoint
+	head       int
+	size       int
+	lastTicks  uint64
+	lastAt     time.Time
+}
+
+func newMetricSeries(capacity int) *metricSeries {
+	return &metricSeries{points: make([]metricPoint, capacity)}
+}
+
+func (s *metricSeries) push(p metricPoint) {
+	s.points[(s.head+s.size)%len(s.points)] = p
+	if s.size < len(s.points) {
+		s.size++
+	} else {
+		s.head = (s.head + 1) % len(s.points)
+	}
+}
+
+func (s *metricSeries) latest() time.Time {
+	if s.size == 0 {
+		return time.Time{}
+	}
+	return s.points[(s.head+s.size-1)%len(s.points)].at
+}
+
+func (s *metricSeries) since(from time.Time) []metricPoint {
+	out := make([]metricPoint, 0, s.size)
+	for i := 0; i < s.size; i++ {
+		p := s.points[(s.head+i)%len(s.points)]
+		if !p.at.Before(from) {
+			out = append(out, p)
+		}
+	}
+	return out
+}
+
+// MetricsSamplerConfigModel 后台采样器配置，ProcessIds 为空表示采样全部进程。
+type MetricsSamplerConfigModel struct {
+	Enabled          bool     `json:"enabled"`
+	IntervalMs       int      `json:"interval_ms"`
+	RetentionSeconds int      `json:"retention_seconds"`
+	ProcessIds       []uint32 `json:"process_ids"`
+}
+
+func (c MetricsSamplerConfigModel) capacity() int {
+	n := c.RetentionSeconds * 1000 / c.IntervalMs
+	if n < 1 {
+		n = 1
+	}
+	return n
+}
+
+// metricsSampler 后台周期性采集进程资源指标，供 GetProcessMetrics 无阻塞查询。
+type metricsSampler struct {
+	applyMu   sync.Mutex
+	mu        sync.Mutex
+	t         *ToolkitService
+	cfg       MetricsSamplerConfigModel
+	series    map[uint32]*metricSeries
+	stop      chan struct{}
+	done      chan struct{}
+	lastError string
+	lastAt    time.Time
+}
+
+var globalMetricsSampler = &metricsSampler{
+	cfg: MetricsSamplerConfigModel{
+		IntervalMs:       defaultMetricsIntervalMs,
+		RetentionSeconds: defaultMetricsRetentionS,
+	},
+	series: make(map[uint32]*metricSeries),
+}
+
+// StartMetricsSampler 由主进程在驱动就绪后登记驱动；采样默认关闭，
+// 需前端通过 ConfigureMetricsSampler 显式开启，避免后端常驻期间周期性打开所有进程。
+func StartMetricsSampler(dev driver.Device) {
+	globalMetricsSampler.mu.Lock()
+	globalMetricsSampler.t = &ToolkitService{Driver: dev}
+	cfg := globalMetricsSampler.cfg
+	globalMetricsSampler.mu.Unlock()
+	globalMetricsSampler.apply(cfg)
+}
+
+// StopMetricsSampler 停止后台采样并等待当前一轮结束，须在关闭驱动句柄前调用。
+func StopMetricsSampler() {
+	globalMetricsSampler.mu.Lock()
+	cfg := globalMetricsSampler.cfg
+	globalMetricsSampler.mu.Unlock()
+	cfg.Enabled = false
+	globalMetricsSampler.apply(cfg)
+}
+
+// apply 替换配置；间隔或保留时长变化时按新容量重建缓冲区。
+func (m *metricsSampler) apply(cfg MetricsSamplerConfigModel) {
+	m.applyMu.Lock()
+	defer m.applyMu.Unlock()
+
+	m.mu.Lock()
+	stop, done := m.stop, m.done
+	m.stop, m.done = nil, nil
+	m.mu.Unlock()
+	if stop != nil {
+		close(stop)
+		<-done
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if cfg.IntervalMs != m.cfg.IntervalMs || cfg.RetentionSeconds != m.cfg.RetentionSeconds {
+		m.series = make(map[uint32]*metricSeries)
+	}
+	m.cfg = cfg
+	if !cfg.Enabled || m.t == nil {
+		return
+	}
+	m.stop = make(chan struct{})
+	m.done = make(chan struct{})
+	go m.run(m.t, time.Duration(cfg.IntervalMs)*time.Millisecond, m.stop, m.done)
+}
+
+func (m *metricsSampler) run(t *ToolkitService, interval time.Duration, stop <-chan struct{}, done chan<- struct{}) {
+	defer close(done)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	m.sample(t)
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			m.sample(t)
+		}
+	}
+}
+
+// sample 先在锁外完成进程枚举与逐进程查询，再一次性写入缓冲区，避免查询阻塞读取。
+// 进程列表直接取驱动记录，每个进程每轮只在 queryProcessCounters 中打开一次。
+func (m *metricsSampler) sample(t *ToolkitService) {
+	m.mu.Lock()
+	pids := append([]uint32(nil), m.cfg.ProcessIds...)
+	m.mu.Unlock()
+
+	var selected map[uint32]struct{}
+	if len(pids) > 0 {
+		selected = make(map[uint32]struct{}, len(pids))
+		for _, pid := range pids {
+			selected[pid] = struct{}{}
+		}
+	}
+
+	processes, err := enumProcessesViaDriver(t.Driver)
+	type observed struct {
+		info     ProcessInfoModel
+		counters processCounters
+		err      error
+	}
+	batch := make([]observed, 0, len(processes))
+	for _, p := range processes {
+		if selected != nil {
+			if _, ok := selected[p.ProcessId]; !ok {
+				continue
+			}
+		}
+		counters, cerr := queryProcessCounters(p.ProcessId)
+		batch = append(batch, observed{info: p, counters: counters, err: cerr})
+	}
+	now := time.Now()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.lastAt = now
+	if err != nil {
+		m.lastError = err.Error()
+		return
+	}
+	m.lastError = ""
+
+	cpus := float64(runtime.NumCPU())
+	capacity := m.cfg.capacity()
+	for _, o := range batch {
+		p, counters, cerr := o.info, o.counters, o.err
+		// 创建时间优先取用户态计数（本轮实际打开的进程），打不开时用驱动记录
+		createTime := p.CreateTime
+		if cerr == nil {
+			createTime = counters.createTime
+		}
+		s := m.series[p.ProcessId]
+		if s == nil || (createTime != 0 && s.createTime != 0 && createTime != s.createTime) {
+			s = newMetricSeries(capacity)
+			m.series[p.ProcessId] = s
+		}
+		s.imageName = p.ImageName
+		s.countersOK = cerr == nil
+
+		if createTime != 0 {
+			s.createTime = createTime
+		}
+		point := metricPoint{at: now, workingSet: p.WorkingSetSize, threads: p.ThreadCount}
+		if cerr == nil {
+			point.handles = counters.handleCount
+			if !s.lastAt.IsZero() && counters.cpuTicks >= s.lastTicks {
+				wall := float64(now.Sub(s.lastAt) / 100)
+				if wall > 0 {
+					point.cpuPercent = float64(counters.cpuTicks-s.lastTicks) / wall / cpus * 100
+				}
+			}
+			s.lastTicks = counters.cpuTicks
+			s.lastAt = now
+		}
+		s.push(point)
+	}
+
+	// 已退出进程的序列保留到超出保留时长后再清理。
+	expire := now.Add(-time.Duration(m.cfg.RetentionSeconds) * time.Second)
+	for pid, s := range m.series {
+		if s.latest().Before(expire) {
+			delete(m.series, pid)
+		}
+	}
+}
+
+// ConfigureMetricsSamplerArgs 配置后台采样器请求参数（整体替换）
+type ConfigureMetricsSamplerArgs struct {
+	Enabled          bool     `json:"enabled"`
+	IntervalMs       int      `json:"interval_ms"`
+	RetentionSeconds int      `json:"retention_seconds"`
+	ProcessIds       []uint32 `json:"process_ids"`
+}
+
+// ConfigureMetricsSamplerReply 配置后台采样器响应
+type ConfigureMetricsSamplerReply struct {
+	Config MetricsSamplerConfigModel `json:"config"`
+}
+
+// ConfigureMetricsSampler 启停后台采样并设置采样间隔、保留时长与采样范围
+func (t *ToolkitService) ConfigureMetricsSampler(args *ConfigureMetricsSamplerArgs, reply *ConfigureMetricsSamplerReply) error {
+	cfg := MetricsSamplerConfigModel{
+		Enabled:          args.Enabled,
+		IntervalMs:       args.IntervalMs,
+		RetentionSeconds: args.RetentionSeconds,
+		ProcessIds:       append([]uint32(nil), args.ProcessIds...),
+	}
+	if cfg.IntervalMs == 0 {
+		cfg.IntervalMs = defaultMetricsIntervalMs
+	}
+	if cfg.RetentionSeconds == 0 {
+		cfg.RetentionSeconds = defaultMetricsRetentionS
+	}
+	params := map[string]any{
+		"enabled":           cfg.Enabled,
+		"interval_ms":       cfg.IntervalMs,
+		"retention_seconds": cfg.RetentionSeconds,
+		"process_ids":       cfg.ProcessIds,
+	}
+
+	if cfg.IntervalMs < minMetricsIntervalMs || cfg.IntervalMs > maxMetricsIntervalMs {
+		err := fmt.Errorf("interval_ms 取值范围 %d~%d", minMetricsIntervalMs, maxMetricsIntervalMs)
+		auditWrite("configure_metrics_sampler", params, err)
+		return err
+	}
+	if cfg.RetentionSeconds < minMetricsRetentionS || cfg.RetentionSeconds > maxMetricsRetentionS {
+		err := fmt.Errorf("retention_seconds 取值范围 %d~%d", minMetricsRetentionS, maxMetricsRetentionS)
+		auditWrite("configure_metrics_sampler", params, err)
+		return err
+	}
+	if cfg.Enabled && t.Driver == nil {
+		err := fmt.Errorf("驱动未加载")
+		auditWrite("configure_metrics_sampler", params, err)
+		return err
+	}
+
+	globalMetricsSampler.mu.Lock()
+	if globalMetricsSampler.t == nil {
+		globalMetricsSampler.t = &ToolkitService{Driver: t.Driver}
+	}
+	globalMetricsSampler.mu.Unlock()
+	globalMetricsSampler.apply(cfg)
+
+	reply.Config = cfg
+	auditWrite("configure_metrics_sampler", params, nil)
+	return nil
+}
+
+// GetProcessMetricsArgs 进程资源时间序列请求参数
+// Since 为 RFC3339 时间，空表示全部保留数据；StepMs 非 0 时按该步长分桶取平均。
+type GetProcessMetricsArgs struct {
+	ProcessId uint32 `json:"process_id"`
+	Since     string `json:"since"`
+	StepMs    int    `json:"step_ms"`
+}
+
+// GetProcessMetricsReply 进程资源时间序列响应
+type GetProcessMetricsReply struct {
+	ProcessId         uint32              `json:"process_id"`
+	ImageName         string              `json:"image_name"`
+	ProcessKey        string              `json:"process_key"`
+	IntervalMs        int                 `json:"interval_ms"`
+	SamplerEnabled    bool                `json:"sampler_enabled"`
+	CountersAvailable bool                `json:"counters_available"`
+	LastSampleAt      string              `json:"last_sample_at,omitempty"`
+	LastError         string              `json:"last_error,omitempty"`
+	Samples           []MetricSampleModel `json:"samples"`
+}
+
+// GetProcessMetrics 从后台采样缓冲区读取进程的 CPU、工作集、线程数与句柄数序列，不阻塞等待采样
+func (t *ToolkitService) GetProcessMetrics(args *GetProcessMetricsArgs, reply *GetProcessMetricsReply) error {
+	if args.ProcessId == 0 {
+		return fmt.Errorf("process_id must be > 0")
+	}
+	if args.StepMs < 0 {
+		return fmt.Errorf("step_ms 不能为负数")
+	}
+	var since time.Time
+	if args.Since != "" {
+		var err error
+		if since, err = time.Parse(time.RFC3339, args.Since); err != nil {
+			return fmt.Errorf("since 需为 RFC3339 时间: %w", err)
+		}
+	}
+
+	m := globalMetricsSampler
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	reply.ProcessId = args.ProcessId
+	reply.IntervalMs = m.cfg.IntervalMs
+	reply.SamplerEnabled = m.cfg.Enabled && m.stop != nil
+	reply.LastError = m.lastError
+	if !m.lastAt.IsZero() {
+		reply.LastSampleAt = m.lastAt.Format(time.RFC3339Nano)
+	}
+
+	s, ok := m.series[args.ProcessId]
+	if !ok && !reply.SamplerEnabled {
+		return fmt.Errorf("后台采样未开启，请先调用 ConfigureMetricsSampler")
+	}
+	if !ok {
+		return fmt.Errorf("进程 %d 无采样数据（未被采样或已超出保留时长）", args.ProcessId)
+	}
+	reply.ImageName = s.imageName
+	reply.ProcessKey = formatProcessKey(args.ProcessId, s.createTime)
+	reply.CountersAvailable = s.countersOK
+	reply.Samples = downsampleMetrics(s.since(since), time.Duration(args.StepMs)*time.Millisecond)
+	return nil
+}
+
+// downsampleMetrics 按步长分桶，桶内取平均值，时间戳取桶内首个采样点。
+func downsampleMetrics(points []metricPoint, step time.Duration) []MetricSampleModel {
+	out := make([]MetricSampleModel, 0, len(points))
+	for i := 0; i < len(points); {
+		j := i + 1
+		if step > 0 {
+			for j < len(points) && points[j].at.Sub(points[i].at) < step {
+				j++
+			}
+		}
+
+		var cpu float64
+		var ws, threads, handles uint64
+		for _, p := range points[i:j] {
+			cpu += p.cpuPercent
+			ws += p.workingSet
+			threads += uint64(p.threads)
+			handles += uint64(p.handles)
+		}
+		n := uint64(j - i)
+		out = append(out, MetricSampleModel{
+			Timestamp:      points[i].at.Format(time.RFC3339Nano),
+			CpuPercent:     cpu / float64(n),
+			WorkingSetSize: ws / n,
+			ThreadCount:    uint32(threads / n),
+			HandleCount:    uint32(handles / n),
+		})
+		i = j
+	}
+	return out
+}
@@ -0,0 +1,69 @@
+package service
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+
+	"github.com/OpenSysKit/backend/internal/winpath"
+)
+
+// pathTranslator 进程内共用的路径转换器，盘符映射来自 QueryDosDevice（短时缓存）。
+var pathTranslator = winpath.Default()
+
+// ntPathToDos 将句柄对象名等 NT 路径（\Device\...、\??\...、\SystemRoot\...）转换为盘符或 UNC 路径，无法转换时返回空串。
+func ntPathToDos(name string) string {
+	if !winpath.IsNT(name) {
+		return ""
+	}
+	p, err := pathTranslator.ToWin32(name)
+	if err != nil {
+		return ""
+	}
+	return p
+}
+
+// normalizeKernelPath 把调用方传入的任意形式路径转为驱动可打开的 NT 路径（\??\C:\...、\??\UNC\... 或原样的 \Device\...）。
+func normalizeKernelPath(path string) string {
+	p := strings.TrimSpace(path)
+	if nt, err := pathTranslator.ToNT(p); err == nil {
+		return nt
+	}
+	if abs, err := filepath.Abs(p); err == nil {
+		if nt, err := pathTranslator.ToNT(abs); err == nil {
+			return nt
+		}
+	}
+	return p
+}
+
+// win32Path 把 Win32、\\?\、\??\、\Device\ 或 UNC 形式统一为绝对 Win32 路径，用于 os 包调用与比较。
+// 相对路径按当前目录补全；subst 盘展开为实际路径，链接不解析。
+func win32Path(path string) (string, error) {
+	p, err := pathTranslator.ToWin32(path)
+	if err == nil || !errors.Is(err, winpath.ErrRelative) {
+		return p, err
+	}
+	abs, err := filepath.Abs(strings.TrimSpace(path))
+	if err != nil {
+		return "", err
+	}
+	return pathTranslator.ToWin32(abs)
+}
+
+// handleMatchPath 解析 path 所在目录中的联接与符号链接，得到与句柄对象名一致的路径；
+// 最后一级不解析，解析失败时原样返回。
+func handleMatchPath(path string) string {
+	dir := filepath.Dir(path)
+	if dir == path {
+		return path
+	}
+	resolved, err := filepath.EvalSymlinks(dir)
+	if err != nil {
+		return path
+	}
+	if p, err := win32Path(filepath.Join(resolved, filepath.Base(path))); err == nil {
+		return p
+	}
+	return path
+}
@@ -0,0 +1,218 @@
+package service
+
+import (
+	"debug/pe"
+	"fmt"
+	"math"
+	"os"
+	"time"
+
+	"github.com/OpenSysKit/backend/internal/filehash"
+	"github.com/OpenSysKit/backend/internal/peinfo"
+)
+
+// InspectPEArgs PE 文件解析请求参数。
+// Path 接受 EnumProcessModules、EnumKernelModules 与 ListDirectory 返回的任意路径形式。
+type InspectPEArgs struct {
+	Path string `json:"path"`
+}
+
+// PESectionModel 节信息；Permissions 为 "rwx" 形式，Entropy 为原始数据的香农熵（0~8）。
+type PESectionModel struct {
+	Name            string  `json:"name"`
+	VirtualAddress  uint32  `json:"virtual_address"`
+	VirtualSize     uint32  `json:"virtual_size"`
+	RawOffset       uint32  `json:"raw_offset"`
+	RawSize         uint32  `json:"raw_size"`
+	Characteristics uint32  `json:"characteristics"`
+	Permissions     string  `json:"permissions"`
+	Entropy         float64 `json:"entropy"`
+}
+
+// PEImportFunctionModel 导入函数；按序号导入时只有 Ordinal。
+type PEImportFunctionModel struct {
+	Name    string `json:"name,omitempty"`
+	Hint    uint16 `json:"hint,omitempty"`
+	Ordinal uint16 `json:"ordinal,omitempty"`
+}
+
+// PEImportModel 一个导入库。
+type PEImportModel struct {
+	Library   string                  `json:"library"`
+	Functions []PEImportFunctionModel `json:"functions"`
+}
+
+// PEExportFunctionModel 导出函数；Forwarder 非空表示转发到其他模块。
+type PEExportFunctionModel struct {
+	Name      string `json:"name,omitempty"`
+	Ordinal   uint32 `json:"ordinal"`
+	RVA       uint32 `json:"rva"`
+	Forwarder string `json:"forwarder,omitempty"`
+}
+
+// PEExportsModel 导出目录。
+type PEExportsModel struct {
+	DllName       string                  `json:"dll_name"`
+	TimeDateStamp uint32                  `json:"time_date_stamp"`
+	Functions     []PEExportFunctionModel `json:"functions"`
+}
+
+// PEVersionInfoModel 版本资源；常用字段取自 StringFileInfo，缺失时版本号回退到 VS_FIXEDFILEINFO。
+type PEVersionInfoModel struct {
+	CompanyName         string            `json:"company_name"`
+	FileDescription     string            `json:"file_description"`
+	ProductName         string            `json:"product_name"`
+	ProductVersion      string            `json:"product_version"`
+	FileVersion         string            `json:"file_version"`
+	OriginalFilename    string            `json:"original_filename"`
+	FixedFileVersion    string            `json:"fixed_file_version,omitempty"`
+	FixedProductVersion string            `json:"fixed_product_version,omitempty"`
+	Strings             map[string]string `json:"strings"`
+}
+
+// InspectPEReply PE 文件解析响应。
+type InspectPEReply struct {
+	Path                  string              `json:"path"`
+	Size                  int64               `json:"size"`
+	ModTime               string              `json:"mod_time"`
+	SHA256                string              `json:"sha256,omitempty"`
+	Imphash               string              `json:"imphash,omitempty"`
+	Machine               string              `json:"machine"`
+	MachineCode           uint16              `json:"machine_code"`
+	Is64Bit               bool                `json:"is_64bit"`
+	IsDll                 bool                `json:"is_dll"`
+	Characteristics       []string            `json:"characteristics"`
+	DllCharacteristics    []string            `json:"dll_characteristics"`
+	Subsystem             string              `json:"subsystem"`
+	TimeDateStamp         uint32              `json:"time_date_stamp"`
+	LinkTime              string              `json:"link_time,omitempty"`
+	ImageBase             uint64              `json:"image_base"`
+	EntryPoint            uint32              `json:"entry_point"`
+	EntryPointSection     string              `json:"entry_point_section"`
+	SizeOfImage           uint32              `json:"size_of_image"`
+	CheckSum              uint32              `json:"checksum"`
+	Sections              []PESectionModel    `json:"sections"`
+	Imports               []PEImportModel     `json:"imports"`
+	ImportCount           int                 `json:"import_count"`
+	Exports               *PEExportsModel     `json:"exports,omitempty"`
+	TLSCallbacks          int                 `json:"tls_callbacks"`
+	VersionInfo           *PEVersionInfoModel `json:"version_info,omitempty"`
+	HasSecurityDirectory  bool                `json:"has_security_directory"`
+	SecurityDirectorySize uint32              `json:"security_directory_size,omitempty"`
+	Warnings              []string            `json:"warnings,omitempty"`
+}
+
+// versionInfoModel 整理版本资源中的常用字段。
+func versionInfoModel(v *peinfo.VersionInfo) *PEVersionInfoModel {
+	m := &PEVersionInfoModel{
+		CompanyName:         v.Strings["CompanyName"],
+		FileDescription:     v.Strings["FileDescription"],
+		ProductName:         v.Strings["ProductName"],
+		ProductVersion:      v.Strings["ProductVersion"],
+		FileVersion:         v.Strings["FileVersion"],
+		OriginalFilename:    v.Strings["OriginalFilename"],
+		FixedFileVersion:    v.FileVersion,
+		FixedProductVersion: v.ProductVersion,
+		Strings:             v.Strings,
+	}
+	if m.ProductVersion == "" {
+		m.ProductVersion = v.ProductVersion
+	}
+	if m.FileVersion == "" {
+		m.FileVersion = v.FileVersion
+	}
+	return m
+}
+
+// InspectPE 解析 PE 文件的头部、节与熵、导入导出表、TLS 回调数量、版本资源与安全目录，只读不加载映像
+func (t *ToolkitService) InspectPE(args *InspectPEArgs, reply *InspectPEReply) error {
+	if args.Path == "" {
+		return fmt.Errorf("path 不能为空")
+	}
+	path, err := win32Path(args.Path)
+	if err != nil {
+		return fmt.Errorf("路径解析失败: %w", err)
+	}
+	st, err := os.Stat(path)
+	if err != nil {
+		return fmt.Errorf("文件不存在或不可访问: %s", path)
+	}
+	if st.IsDir() {
+		return fmt.Errorf("不是文件: %s", path)
+	}
+
+	info, err := peinfo.Inspect(path)
+	if err != nil {
+		return fmt.Errorf("解析 PE 失败: %w", err)
+	}
+
+	reply.Path = path
+	reply.Size = st.Size()
+	reply.ModTime = st.ModTime().Format(time.RFC3339)
+	if sums, _, err := filehash.Sum(path, []string{filehash.SHA256}); err == nil {
+		reply.SHA256 = sums[filehash.SHA256]
+	}
+	// 导入表已解析，直接由其计算 imphash，无需再读一遍文件
+	reply.Imphash = filehash.ImphashFromImports(info.Imports)
+
+	reply.Machine = peinfo.MachineName(info.Machine)
+	reply.MachineCode = info.Machine
+	reply.Is64Bit = info.Is64
+	reply.IsDll = info.Characteristics&pe.IMAGE_FILE_DLL != 0
+	reply.Characteristics = peinfo.CharacteristicNames(info.Characteristics)
+	reply.DllCharacteristics = peinfo.DllCharacteristicNames(info.DllCharacteristics)
+	reply.Subsystem = peinfo.SubsystemName(info.Subsystem)
+	reply.TimeDateStamp = info.TimeDateStamp
+	if info.TimeDateStamp != 0 {
+		reply.LinkTime = time.Unix(int64(info.TimeDateStamp), 0).UTC().Format(time.RFC3339)
+	}
+	reply.ImageBase = info.ImageBase
+	reply.EntryPoint = info.EntryPoint
+	reply.EntryPointSection = info.EntryPointSection
+	reply.SizeOfImage = info.SizeOfImage
+	reply.CheckSum = info.CheckSum
+
+	reply.Sections = make([]PESectionModel, 0, len(info.Sections))
+	for _, s := range info.Sections {
+		reply.Sections = append(reply.Sections, PESectionModel{
+			Name:            s.Name,
+			VirtualAddress:  s.VirtualAddress,
+			VirtualSize:     s.VirtualSize,
+			RawOffset:       s.RawOffset,
+			RawSize:         s.RawSize,
+			Characteristics: s.Characteristics,
+			Permissions:     peinfo.SectionPermissions(s.Characteristics),
+			Entropy:         math.Round(s.Entropy*1000) / 1000,
+		})
+	}
+
+	reply.Imports = make([]PEImportModel, 0, len(info.Imports))
+	for _, imp := range info.Imports {
+		m := PEImportModel{Library: imp.Library, Functions: make([]PEImportFunctionModel, 0, len(imp.Functions))}
+		for _, fn := range imp.Functions {
+			if fn.ByOrdinal {
+				m.Functions = append(m.Functions, PEImportFunctionModel{Ordinal: fn.Ordinal})
+			} else {
+				m.Functions = append(m.Functions, PEImportFunctionModel{Name: fn.Name, Hint: fn.Hint})
+			}
+		}
+		reply.ImportCount += len(m.Functions)
+		reply.Imports = append(reply.Imports, m)
+	}
+
+	if e := info.Exports; e != nil {
+		reply.Exports = &PEExportsModel{DllName: e.DllName, TimeDateStamp: e.TimeDateStamp, Functions: make([]PEExportFunctionModel, 0, len(e.Functions))}
+		for _, fn := range e.Functions {
+			reply.Exports.Functions = append(reply.Exports.Functions, PEExportFunctionModel{Name: fn.Name, Ordinal: fn.Ordinal, RVA: fn.RVA, Forwarder: fn.Forwarder})
+		}
+	}
+
+	reply.TLSCallbacks = info.TLSCallbacks
+	if info.Version != nil {
+		reply.VersionInfo = versionInfoModel(info.Version)
+	}
+	reply.HasSecurityDirectory = info.SecurityDirectory.VirtualAddress != 0 && info.SecurityDirectory.Size != 0
+	reply.SecurityDirectorySize = info.SecurityDirectory.Size
+	reply.Warnings = info.Warnings
+	return nil
+}
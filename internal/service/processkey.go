@@ -0,0 +1,113 @@
+package service
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// formatProcessKey 生成进程唯一标识 "<pid>:<create_time>"，创建时间不可得时返回空串。
+// PID 会被系统回收复用，创建时间不会，二者组合可区分同一 PID 上的前后两个进程。
+func formatProcessKey(pid uint32, createTime uint64) string {
+	if createTime == 0 {
+		return ""
+	}
+	return fmt.Sprintf("%d:%d", pid, createTime)
+}
+
+// 进程标识的来源，写入 ProcessInfoModel.IdentitySource。
+const (
+	identitySourceDriver      = "driver"
+	identitySourceUserMode    = "user_mode"
+	identitySourceUnavailable = "unavailable"
+)
+
+// lookupCreateTime 查询进程创建时间：先用 OpenProcess，打不开（受保护进程、PPL 等）时退回驱动进程列表。
+func (t *ToolkitService) lookupCreateTime(pid uint32) (uint64, error) {
+	createTime, err := processCreateTime(pid)
+	if err == nil || t == nil || t.Driver == nil {
+		return createTime, err
+	}
+	processes, derr := enumProcessesViaDriver(t.Driver)
+	if derr != nil {
+		return 0, err
+	}
+	for _, p := range processes {
+		if p.ProcessId == pid && p.CreateTime != 0 {
+			return p.CreateTime, nil
+		}
+	}
+	return 0, err
+}
+
+// currentProcessKey 查询进程当前的标识，供延迟执行的逆操作（撤销、自动恢复）校验 PID 未被复用。
+func (t *ToolkitService) currentProcessKey(pid uint32) string {
+	createTime, err := t.lookupCreateTime(pid)
+	if err != nil {
+		return ""
+	}
+	return formatProcessKey(pid, createTime)
+}
+
+// fillProcessIdentity 为驱动返回的进程列表生成进程标识。
+// 新版驱动的记录自带创建时间；旧版驱动没有时退回 OpenProcess 查询，仍失败的条目标记为 unavailable。
+func fillProcessIdentity(processes []ProcessInfoModel) {
+	for i := range processes {
+		p := &processes[i]
+		switch {
+		case p.CreateTime != 0:
+			p.IdentitySource = identitySourceDriver
+		default:
+			createTime, err := processCreateTime(p.ProcessId)
+			if err != nil {
+				p.IdentitySource = identitySourceUnavailable
+				continue
+			}
+			p.CreateTime = createTime
+			p.IdentitySource = identitySourceUserMode
+		}
+		p.ProcessKey = formatProcessKey(p.ProcessId, p.CreateTime)
+	}
+}
+
+// verifyProcessKey 校验调用方携带的 process_key 是否仍指向同一进程；key 为空时不校验。
+func (t *ToolkitService) verifyProcessKey(pid uint32, key string) error {
+	key = strings.TrimSpace(key)
+	if key == "" {
+		return nil
+	}
+
+	pidPart, timePart, ok := strings.Cut(key, ":")
+	if !ok {
+		return fmt.Errorf("process_key 格式错误，应为 <pid>:<create_time>")
+	}
+	keyPid, err := strconv.ParseUint(pidPart, 10, 32)
+	if err != nil {
+		return fmt.Errorf("process_key 格式错误，应为 <pid>:<create_time>")
+	}
+	keyTime, err := strconv.ParseUint(timePart, 10, 64)
+	if err != nil {
+		return fmt.Errorf("process_key 格式错误，应为 <pid>:<create_time>")
+	}
+	if uint32(keyPid) != pid {
+		return fmt.Errorf("process_key 与 process_id 不一致")
+	}
+
+	createTime, err := t.lookupCreateTime(pid)
+	if err != nil {
+		return fmt.Errorf("无法校验 process_key（进程可能已退出）: %w", err)
+	}
+	if createTime != keyTime {
+		return fmt.Errorf("进程标识不匹配，PID %d 已被其他进程复用，请刷新后重试", pid)
+	}
+	return nil
+}
+
+// isParentLinkValid 父进程晚于子进程创建说明父 PID 已被复用，不应挂接。
+// 任一方创建时间未知时退回按 PID 挂接。
+func isParentLinkValid(parent ProcessInfoModel, child ProcessInfoModel) bool {
+	if parent.CreateTime == 0 || child.CreateTime == 0 {
+		return true
+	}
+	return parent.CreateTime <= child.CreateTime
+}
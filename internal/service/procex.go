@@ -0,0 +1,173 @@
+package service
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// 扩展进程字段的数据来源标记。
+const (
+	FieldSourceDriver      = "driver"
+	FieldSourceUserMode    = "usermode"
+	FieldSourceUnavailable = "unavailable"
+)
+
+// processExFields 参与来源标记的扩展字段，未被任何来源填充的字段标记为 unavailable。
+var processExFields = []string{
+	"image_path",
+	"user_name",
+	"session_id",
+	"integrity_level",
+	"protection",
+	"start_time",
+	"cpu_time",
+	"handle_count",
+	"private_bytes",
+}
+
+// ProcessInfoExModel 扩展进程信息。
+// Sources 以字段名为键给出每个扩展字段的来源：driver（内核驱动，权威）、
+// usermode（用户态 API，受权限限制）或 unavailable（两者均取不到，对应字段为零值）。
+type ProcessInfoExModel struct {
+	ProcessInfoModel
+	ImagePath       string            `json:"image_path"`
+	UserName        string            `json:"user_name"`
+	SessionId       uint32            `json:"session_id"`
+	IntegrityLevel  string            `json:"integrity_level"`
+	ProtectionLevel uint8             `json:"protection_level"`
+	Protection      string            `json:"protection"`
+	StartTime       string            `json:"start_time"`
+	KernelTimeMs    uint64            `json:"kernel_time_ms"`
+	UserTimeMs      uint64            `json:"user_time_ms"`
+	HandleCount     uint32            `json:"handle_count"`
+	PrivateBytes    uint64            `json:"private_bytes"`
+	Sources         map[string]string `json:"sources"`
+}
+
+// EnumProcessesExArgs 扩展进程枚举请求参数
+type EnumProcessesExArgs struct {
+	Query *QuerySpec `json:"query,omitempty"`
+}
+
+// EnumProcessesExReply 扩展进程枚举响应
+type EnumProcessesExReply struct {
+	Processes []ProcessInfoExModel `json:"processes"`
+	Page      *QueryPageModel      `json:"page"`
+}
+
+// EnumProcessesEx 在 EnumProcesses 的基础上补充路径、账户、会话、完整性级别、保护级别、
+// 启动时间、CPU 时间、句柄数与私有内存；优先使用驱动数据，取不到时回退到用户态 API
+func (t *ToolkitService) EnumProcessesEx(args *EnumProcessesExArgs, reply *EnumProcessesExReply) error {
+	processes, err := t.getProcessList()
+	if err != nil {
+		return err
+	}
+
+	// 驱动句柄表一次性覆盖全部进程，包括用户态无法打开的受保护进程。
+	var handleCounts map[uint32]uint32
+	if handles, err := listHandlesViaDriver(t.Driver, 0); err == nil {
+		handleCounts = make(map[uint32]uint32, len(processes))
+		for _, h := range handles {
+			handleCounts[h.ProcessId]++
+		}
+	}
+
+	reply.Processes = make([]ProcessInfoExModel, 0, len(processes))
+	for _, p := range processes {
+		info := ProcessInfoExModel{ProcessInfoModel: p, Sources: make(map[string]string, len(processExFields))}
+		if handleCounts != nil {
+			info.HandleCount = handleCounts[p.ProcessId]
+			info.Sources["handle_count"] = FieldSourceDriver
+		}
+		// 启动时间直接取驱动记录中的 CreateTime，与 process_key 同源，受保护进程同样可用
+		if p.IdentitySource == identitySourceDriver && p.CreateTime > filetimeUnixEpoch {
+			info.StartTime = filetimeToTime(p.CreateTime).Format(time.RFC3339)
+			info.Sources["start_time"] = FieldSourceDriver
+		}
+
+		fillProcessExUserMode(&info)
+
+		if info.ImagePath == "" {
+			t.fillImagePathViaDriver(&info)
+		}
+		for _, f := range processExFields {
+			if _, ok := info.Sources[f]; !ok {
+				info.Sources[f] = FieldSourceUnavailable
+			}
+		}
+		reply.Processes = append(reply.Processes, info)
+	}
+	reply.Processes, reply.Page, err = applyQuery(reply.Processes, args.Query)
+	return err
+}
+
+// filetimeUnixEpoch 1970-01-01 对应的 FILETIME 值（100ns 单位）。
+const filetimeUnixEpoch = 116444736000000000
+
+// filetimeToTime 把 FILETIME（自 1601 年起的 100ns 计数）转换为 time.Time。
+func filetimeToTime(ft uint64) time.Time {
+	return time.Unix(0, int64(ft-filetimeUnixEpoch)*100)
+}
+
+// fillImagePathViaDriver 用户态无法打开进程时，从驱动模块列表中找出主映像路径。
+func (t *ToolkitService) fillImagePathViaDriver(info *ProcessInfoExModel) {
+	if info.ProcessId == 0 || info.ImageName == "" {
+		return
+	}
+	modules, err := enumProcessModulesViaDriver(t.Driver, info.ProcessId)
+	if err != nil {
+		return
+	}
+	for _, m := range modules {
+		if strings.EqualFold(m.ModuleName, info.ImageName) && m.Path != "" {
+			info.ImagePath = m.Path
+			info.Sources["image_path"] = FieldSourceDriver
+			return
+		}
+	}
+}
+
+// formatProtection 按 PS_PROTECTION 编码 (Signer << 4) | Type 生成可读名称，如 PPL-WinTcb。
+func formatProtection(level uint8) string {
+	if level == 0 {
+		return "none"
+	}
+
+	var kind string
+	switch level & 0x7 {
+	case 1:
+		kind = "PPL"
+	case 2:
+		kind = "PP"
+	default:
+		kind = fmt.Sprintf("type%d", level&0x7)
+	}
+
+	signers := []string{"None", "Authenticode", "CodeGen", "Antimalware", "Lsa", "Windows", "WinTcb", "WinSystem", "App"}
+	signer := fmt.Sprintf("signer%d", level>>4)
+	if int(level>>4) < len(signers) {
+		signer = signers[level>>4]
+	}
+	return kind + "-" + signer
+}
+
+// integrityLevelName 将强制完整性标签的 RID 映射为名称。
+func integrityLevelName(rid uint32) string {
+	switch {
+	case rid >= 0x5000:
+		return "protected"
+	case rid >= 0x4000:
+		return "system"
+	case rid >= 0x3000:
+		return "high"
+	case rid >= 0x2100:
+		return "medium_plus"
+	case rid >= 0x2000:
+		return "medium"
+	case rid >= 0x1000:
+		return "low"
+	default:
+		return "untrusted"
+	}
+}
@@ -0,0 +1,5 @@
+//go:build !windows
+
+package service
+
+func fillProcessExUserMode(_ *ProcessInfoExModel) {}
@@ -0,0 +1,125 @@
+//go:build windows
+
+package service
+
+import (
+	"time"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+var (
+	procGetProcessHandleCount = modKernel32.NewProc("GetProcessHandleCount")
+	procK32GetProcessMemInfo  = modKernel32.NewProc("K32GetProcessMemoryInfo")
+)
+
+// processMemoryCountersEx 对应 PROCESS_MEMORY_COUNTERS_EX。
+type processMemoryCountersEx struct {
+	Cb                         uint32
+	PageFaultCount             uint32
+	PeakWorkingSetSize         uintptr
+	WorkingSetSize             uintptr
+	QuotaPeakPagedPoolUsage    uintptr
+	QuotaPagedPoolUsage        uintptr
+	QuotaPeakNonPagedPoolUsage uintptr
+	QuotaNonPagedPoolUsage     uintptr
+	PagefileUsage              uintptr
+	PeakPagefileUsage          uintptr
+	PrivateUsage               uintptr
+}
+
+// fillProcessExUserMode 通过用户态 API 补充扩展字段；每个字段成功取到才标记来源，
+// 已由驱动填充的字段不覆盖。
+func fillProcessExUserMode(info *ProcessInfoExModel) {
+	pid := info.ProcessId
+
+	var session uint32
+	if err := windows.ProcessIdToSessionId(pid, &session); err == nil {
+		info.SessionId = session
+		info.Sources["session_id"] = FieldSourceUserMode
+	}
+
+	h, err := windows.OpenProcess(windows.PROCESS_QUERY_LIMITED_INFORMATION, false, pid)
+	if err != nil {
+		return
+	}
+	defer windows.CloseHandle(h)
+
+	size := uint32(1024)
+	buf := make([]uint16, size)
+	if err = windows.QueryFullProcessImageName(h, 0, &buf[0], &size); err == nil {
+		info.ImagePath = windows.UTF16ToString(buf[:size])
+		info.Sources["image_path"] = FieldSourceUserMode
+	}
+
+	// 启动时间优先用驱动记录（见 EnumProcessesEx），这里只在驱动未提供时补充
+	var creation, exit, kernel, user windows.Filetime
+	if err = windows.GetProcessTimes(h, &creation, &exit, &kernel, &user); err == nil {
+		if _, ok := info.Sources["start_time"]; !ok {
+			info.StartTime = time.Unix(0, creation.Nanoseconds()).Format(time.RFC3339)
+			info.Sources["start_time"] = FieldSourceUserMode
+		}
+		info.KernelTimeMs = filetimeTicks(kernel) / 10000
+		info.UserTimeMs = filetimeTicks(user) / 10000
+		info.Sources["cpu_time"] = FieldSourceUserMode
+	}
+
+	var level uint8
+	var retLen uint32
+	if err = windows.NtQueryInformationProcess(h, windows.ProcessProtectionInformation, unsafe.Pointer(&level), 1, &retLen); err == nil {
+		info.ProtectionLevel = level
+		info.Protection = formatProtection(level)
+		info.Sources["protection"] = FieldSourceUserMode
+	}
+
+	if _, ok := info.Sources["handle_count"]; !ok {
+		var count uint32
+		if r, _, _ := procGetProcessHandleCount.Call(uintptr(h), uintptr(unsafe.Pointer(&count))); r != 0 {
+			info.HandleCount = count
+			info.Sources["handle_count"] = FieldSourceUserMode
+		}
+	}
+
+	var mem processMemoryCountersEx
+	mem.Cb = uint32(unsafe.Sizeof(mem))
+	if r, _, _ := procK32GetProcessMemInfo.Call(uintptr(h), uintptr(unsafe.Pointer(&mem)), uintptr(mem.Cb)); r != 0 {
+		info.PrivateBytes = uint64(mem.PrivateUsage)
+		info.Sources["private_bytes"] = FieldSourceUserMode
+	}
+
+	var token windows.Token
+	if err = windows.OpenProcessToken(h, windows.TOKEN_QUERY, &token); err != nil {
+		return
+	}
+	defer token.Close()
+
+	if name, err := tokenAccountName(token); err == nil {
+		info.UserName = name
+		info.Sources["user_name"] = FieldSourceUserMode
+	}
+	if rid, err := tokenIntegrityRID(token); err == nil {
+		info.IntegrityLevel = integrityLevelName(rid)
+		info.Sources["integrity_level"] = FieldSourceUserMode
+	}
+}
+
+func tokenIntegrityRID(token windows.Token) (uint32, error) {
+	n := uint32(64)
+	for {
+		buf := make([]byte, n)
+		err := windows.GetTokenInformation(token, windows.TokenIntegrityLevel, &buf[0], uint32(len(buf)), &n)
+		if err == nil {
+			label := (*windows.Tokenmandatorylabel)(unsafe.Pointer(&buf[0]))
+			sid := label.Label.Sid
+			return sid.SubAuthority(uint32(sid.SubAuthorityCount()) - 1), nil
+		}
+		if err != windows.ERROR_INSUFFICIENT_BUFFER || n <= uint32(len(buf)) {
+			return 0, err
+		}
+	}
+}
+
+func filetimeTicks(ft windows.Filetime) uint64 {
+	return uint64(ft.HighDateTime)<<32 | uint64(ft.LowDateTime)
+}
@@ -0,0 +1,29 @@
+//go:build !windows
+
+package service
+
+import "fmt"
+
+func queryProcessProtection(_ uint32) (uint8, error) {
+	return 0, fmt.Errorf("仅支持 Windows")
+}
+
+func processAccountName(_ uint32) (string, error) {
+	return "", fmt.Errorf("仅支持 Windows")
+}
+
+func processImagePath(_ uint32) (string, error) {
+	return "", fmt.Errorf("仅支持 Windows")
+}
+
+func processCreateTime(_ uint32) (uint64, error) {
+	return 0, fmt.Errorf("仅支持 Windows")
+}
+
+func processImageBaseName(_ uint32) string {
+	return ""
+}
+
+func queryProcessCounters(_ uint32) (processCounters, error) {
+	return processCounters{}, fmt.Errorf("仅支持 Windows")
+}
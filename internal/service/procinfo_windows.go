@@ -0,0 +1,125 @@
+//go:build windows
+
+package service
+
+import (
+	"fmt"
+	"path/filepath"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+// queryProcessProtection 读取进程当前 PS_PROTECTION 字节（ProcessProtectionInformation）。
+func queryProcessProtection(pid uint32) (uint8, error) {
+	h, err := windows.OpenProcess(windows.PROCESS_QUERY_LIMITED_INFORMATION, false, pid)
+	if err != nil {
+		return 0, err
+	}
+	defer windows.CloseHandle(h)
+
+	var level uint8
+	var retLen uint32
+	if err = windows.NtQueryInformationProcess(h, windows.ProcessProtectionInformation, unsafe.Pointer(&level), 1, &retLen); err != nil {
+		return 0, err
+	}
+	return level, nil
+}
+
+// processAccountName 返回进程令牌用户，格式 DOMAIN\user。
+func processAccountName(pid uint32) (string, error) {
+	h, err := windows.OpenProcess(windows.PROCESS_QUERY_LIMITED_INFORMATION, false, pid)
+	if err != nil {
+		return "", err
+	}
+	defer windows.CloseHandle(h)
+
+	var token windows.Token
+	if err = windows.OpenProcessToken(h, windows.TOKEN_QUERY, &token); err != nil {
+		return "", err
+	}
+	defer token.Close()
+	return tokenAccountName(token)
+}
+
+func tokenAccountName(token windows.Token) (string, error) {
+	user, err := token.GetTokenUser()
+	if err != nil {
+		return "", err
+	}
+	return sidAccountName(user.User.Sid), nil
+}
+
+// sidAccountName 将 SID 解析为 "域\账户"，无法解析时返回 SID 字符串。
+func sidAccountName(sid *windows.SID) string {
+	account, domain, _, err := sid.LookupAccount("")
+	if err != nil {
+		return sid.String()
+	}
+	if domain == "" {
+		return account
+	}
+	return fmt.Sprintf(`%s\%s`, domain, account)
+}
+
+// processImagePath 返回进程映像的 Win32 完整路径。
+func processImagePath(pid uint32) (string, error) {
+	h, err := windows.OpenProcess(windows.PROCESS_QUERY_LIMITED_INFORMATION, false, pid)
+	if err != nil {
+		return "", err
+	}
+	defer windows.CloseHandle(h)
+
+	size := uint32(1024)
+	buf := make([]uint16, size)
+	if err = windows.QueryFullProcessImageName(h, 0, &buf[0], &size); err != nil {
+		return "", err
+	}
+	return windows.UTF16ToString(buf[:size]), nil
+}
+
+// processCreateTime 返回进程创建时间（FILETIME，100ns 为单位），与 PID 组合可识别 PID 复用。
+func processCreateTime(pid uint32) (uint64, error) {
+	h, err := windows.OpenProcess(windows.PROCESS_QUERY_LIMITED_INFORMATION, false, pid)
+	if err != nil {
+		return 0, err
+	}
+	defer windows.CloseHandle(h)
+
+	var creation, exit, kernel, user windows.Filetime
+	if err = windows.GetProcessTimes(h, &creation, &exit, &kernel, &user); err != nil {
+		return 0, err
+	}
+	return uint64(creation.HighDateTime)<<32 | uint64(creation.LowDateTime), nil
+}
+
+func processImageBaseName(pid uint32) string {
+	path, err := processImagePath(pid)
+	if err != nil {
+		return ""
+	}
+	return filepath.Base(path)
+}
+
+// queryProcessCounters 一次打开进程读取创建时间、累计 CPU 时间与句柄数，供后台采样使用。
+func queryProcessCounters(pid uint32) (processCounters, error) {
+	h, err := windows.OpenProcess(windows.PROCESS_QUERY_LIMITED_INFORMATION, false, pid)
+	if err != nil {
+		return processCounters{}, err
+	}
+	defer windows.CloseHandle(h)
+
+	var creation, exit, kernel, user windows.Filetime
+	if err = windows.GetProcessTimes(h, &creation, &exit, &kernel, &user); err != nil {
+		return processCounters{}, err
+	}
+	out := processCounters{
+		createTime: filetimeTicks(creation),
+		cpuTicks:   filetimeTicks(kernel) + filetimeTicks(user),
+	}
+	var count uint32
+	if r, _, _ := procGetProcessHandleCount.Call(uintptr(h), uintptr(unsafe.Pointer(&count))); r != 0 {
+		out.handleCount = count
+	}
+	return out, nil
+}
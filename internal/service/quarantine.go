@@ -0,0 +1,766 @@
+package service
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	quarantineDirName   = "quarantine"
+	quarantineIndexName = "index.json"
+	quarantineBlobExt   = ".qbin"
+)
+
+// quarantineMagic 隔离文件头；其后为与随机密钥循环异或的原始内容，使文件既不是有效 PE 也不会被杀软再次命中。
+var quarantineMagic = []byte("OSKQUAR1")
+
+// QuarantineEntryModel 隔离区中的一个文件
+type QuarantineEntryModel struct {
+	Id            string `json:"id"`
+	OriginalPath  string `json:"original_path"`
+	StoredPath    string `json:"stored_path"`
+	Size          int64  `json:"size"`
+	MD5           string `json:"md5"`
+	SHA1          string `json:"sha1"`
+	SHA256        string `json:"sha256"`
+	ModTime       string `json:"mod_time,omitempty"`
+	Security      string `json:"security,omitempty"`
+	Reason        string `json:"reason,omitempty"`
+	QuarantinedAt string `json:"quarantined_at"`
+	DeleteMethod  string `json:"delete_method,omitempty"`
+}
+
+// quarantineRecord 索引文件中的记录，额外保存解码密钥。
+type quarantineRecord struct {
+	QuarantineEntryModel
+	Key string `json:"key"`
+}
+
+// quarantineStore 隔离区索引，持久化在可执行文件同目录的 quarantine\index.json。
+type quarantineStore struct {
+	mu      sync.Mutex
+	loaded  bool
+	dir     string
+	records []quarantineRecord
+}
+
+var globalQuarantine = &quarantineStore{}
+
+func (s *quarantineStore) loadLocked() error {
+	if s.loaded {
+		return nil
+	}
+	baseDir := "."
+	if exePath, err := os.Executable(); err == nil {
+		baseDir = filepath.Dir(exePath)
+	}
+	dir := filepath.Join(baseDir, quarantineDirName)
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return fmt.Errorf("创建隔离目录失败: %w", err)
+	}
+	if err := restrictDirToAdmins(dir); err != nil {
+		return fmt.Errorf("设置隔离目录权限失败: %w", err)
+	}
+
+	records := make([]quarantineRecord, 0)
+	data, err := os.ReadFile(filepath.Join(dir, quarantineIndexName))
+	switch {
+	case err == nil:
+		if err := json.Unmarshal(data, &records); err != nil {
+			return fmt.Errorf("解析隔离索引失败: %w", err)
+		}
+	case !errors.Is(err, fs.ErrNotExist):
+		return fmt.Errorf("读取隔离索引失败: %w", err)
+	}
+	s.dir = dir
+	s.records = records
+	s.loaded = true
+	return nil
+}
+
+// saveLocked 先写临时文件再替换，避免写到一半时索引损坏。
+func (s *quarantineStore) saveLocked() error {
+	data, err := json.MarshalIndent(s.records, "", "  ")
+	if err != nil {
+		return fmt.Errorf("序列化隔离索引失败: %w", err)
+	}
+	path := filepath.Join(s.dir, quarantineIndexName)
+	if err := os.WriteFile(path+".tmp", data, 0o600); err != nil {
+		return fmt.Errorf("写入隔离索引失败: %w", err)
+	}
+	if err := os.Rename(path+".tmp", path); err != nil {
+		return fmt.Errorf("写入隔离索引失败: %w", err)
+	}
+	return nil
+}
+
+func (s *quarantineStore) indexLocked(id string) int {
+	for i := range s.records {
+		if s.records[i].Id == id {
+			return i
+		}
+	}
+	return -1
+}
+
+// xorWriter 以循环密钥异或后写入，读取时用同一变换还原。
+type xorWriter struct {
+	w   io.Writer
+	key []byte
+	off int
+	buf []byte
+}
+
+func (x *xorWriter) Write(p []byte) (int, error) {
+	if cap(x.buf) < len(p) {
+		x.buf = make([]byte, len(p))
+	}
+	out := x.buf[:len(p)]
+	for i, b := range p {
+		out[i] = b ^ x.key[(x.off+i)%len(x.key)]
+	}
+	x.off = (x.off + len(p)) % len(x.key)
+	return x.w.Write(out)
+}
+
+// xorReader 还原 xorWriter 写入的内容。
+type xorReader struct {
+	r   io.Reader
+	key []byte
+	off int
+}
+
+func (x *xorReader) Read(p []byte) (int, error) {
+	n, err := x.r.Read(p)
+	for i := 0; i < n; i++ {
+		p[i] ^= x.key[(x.off+i)%len(x.key)]
+	}
+	x.off = (x.off + n) % len(x.key)
+	return n, err
+}
+
+// QuarantineFileArgs 隔离文件请求参数。
+// CloseHandles 为 true 时，文件被占用无法读取或删除会先经驱动关闭指向它的句柄；
+// 用户态删除失败且驱动已加载时回退到内核删除。
+type QuarantineFileArgs struct {
+	Path         string `json:"path"`
+	Reason       string `json:"reason"`
+	CloseHandles bool   `json:"close_handles"`
+	Force        bool   `json:"force"`
+}
+
+// QuarantineFileReply 隔离文件响应。
+// StagingLeftover 非空表示改名路径下的暂存文件（原文件明文）未能立即删除，StagingCleanup 说明后续处理方式。
+type QuarantineFileReply struct {
+	Entry           QuarantineEntryModel `json:"entry"`
+	HandlesClosed   int                  `json:"handles_closed"`
+	UndoId          int64                `json:"undo_id"`
+	StagingLeftover string               `json:"staging_leftover,omitempty"`
+	StagingCleanup  string               `json:"staging_cleanup,omitempty"`
+}
+
+// quarantineCopy 把 src 编码写入 dst，同时计算原始内容的 MD5/SHA1/SHA256。
+func quarantineCopy(dst io.Writer, src io.Reader, key []byte, entry *QuarantineEntryModel) error {
+	bw := bufio.NewWriter(dst)
+	if _, err := bw.Write(quarantineMagic); err != nil {
+		return err
+	}
+	hMD5, hSHA1, hSHA256 := md5.New(), sha1.New(), sha256.New()
+	n, err := io.Copy(io.MultiWriter(hMD5, hSHA1, hSHA256, &xorWriter{w: bw, key: key}), src)
+	if err != nil {
+		return err
+	}
+	if err := bw.Flush(); err != nil {
+		return err
+	}
+	entry.Size = n
+	entry.MD5 = hex.EncodeToString(hMD5.Sum(nil))
+	entry.SHA1 = hex.EncodeToString(hSHA1.Sum(nil))
+	entry.SHA256 = hex.EncodeToString(hSHA256.Sum(nil))
+	return nil
+}
+
+// writeQuarantineBlob 新建 entry.StoredPath 并写入编码后的内容，失败时删除不完整的隔离文件。
+func writeQuarantineBlob(src io.Reader, key []byte, entry *QuarantineEntryModel) error {
+	dst, err := os.OpenFile(entry.StoredPath, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+	if err != nil {
+		return fmt.Errorf("创建隔离文件失败: %w", err)
+	}
+	err = quarantineCopy(dst, src, key, entry)
+	if closeErr := dst.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(entry.StoredPath)
+		return fmt.Errorf("写入隔离文件失败: %w", err)
+	}
+	return nil
+}
+
+// quarantineStagingPath 返回隔离文件对应的暂存文件路径。
+func quarantineStagingPath(storedPath string) string {
+	return strings.TrimSuffix(storedPath, quarantineBlobExt) + ".staging"
+}
+
+// removeQuarantineStaging 删除暂存文件；用户态删除失败时依次回退到驱动删除与重启删除。
+// 返回空串表示已删除；否则返回 reboot（已登记重启删除）或 none（无法处理）以及删除失败的原因。
+func (t *ToolkitService) removeQuarantineStaging(staging string) (string, error) {
+	err := os.Remove(staging)
+	if err == nil || errors.Is(err, fs.ErrNotExist) {
+		return "", nil
+	}
+	if t.Driver != nil {
+		if kerr := deleteFileViaDriver(t.Driver, staging); kerr != nil {
+			err = fmt.Errorf("%v; 内核删除文件失败: %w", err, kerr)
+		} else if _, statErr := os.Lstat(staging); statErr != nil {
+			return "", nil
+		} else {
+			err = fmt.Errorf("%v; 内核删除后文件仍存在", err)
+		}
+	}
+	if rerr := scheduleDeleteOnReboot(staging); rerr != nil {
+		return "none", fmt.Errorf("删除暂存文件失败: %v; 登记重启删除失败: %w", err, rerr)
+	}
+	now := time.Now()
+	globalRebootDeletes.mu.Lock()
+	if globalRebootDeletes.loadLocked() == nil {
+		globalRebootDeletes.addLocked(staging, now)
+		globalRebootDeletes.saveLocked()
+	}
+	globalRebootDeletes.mu.Unlock()
+	return "reboot", fmt.Errorf("删除暂存文件失败，已登记重启删除: %w", err)
+}
+
+// quarantineByRename 把 target 改名为隔离目录中的暂存文件，再由暂存文件编码生成隔离文件，返回暂存路径。
+// 改名失败时返回空串，由调用方回退到复制；编码失败时把文件移回原路径。
+func quarantineByRename(target string, key []byte, entry *QuarantineEntryModel) (string, error) {
+	staging := quarantineStagingPath(entry.StoredPath)
+	if err := os.Rename(target, staging); err != nil {
+		return "", nil
+	}
+	src, err := os.Open(staging)
+	if err == nil {
+		err = writeQuarantineBlob(src, key, entry)
+		src.Close()
+	} else {
+		err = fmt.Errorf("读取文件失败: %w", err)
+	}
+	if err != nil {
+		if rerr := os.Rename(staging, target); rerr != nil {
+			return "", fmt.Errorf("%w; 移回原路径失败，文件位于 %s: %v", err, staging, rerr)
+		}
+		return "", err
+	}
+	return staging, nil
+}
+
+// QuarantineFile 把文件编码后移入隔离区并删除原文件，保留原路径、哈希、时间、原因与 ACL，可通过 RestoreQuarantined 还原
+func (t *ToolkitService) QuarantineFile(args *QuarantineFileArgs, reply *QuarantineFileReply) error {
+	params := map[string]any{"path": args.Path, "reason": args.Reason, "close_handles": args.CloseHandles}
+	if strings.TrimSpace(args.Path) == "" {
+		err := fmt.Errorf("path 不能为空")
+		auditWrite("quarantine_file", params, err)
+		return err
+	}
+	if args.CloseHandles && t.Driver == nil {
+		err := fmt.Errorf("驱动未加载，无法关闭占用句柄")
+		auditWrite("quarantine_file", params, err)
+		return err
+	}
+	target, err := win32Path(args.Path)
+	if err != nil {
+		retErr := fmt.Errorf("路径解析失败: %w", err)
+		auditWrite("quarantine_file", params, retErr)
+		return retErr
+	}
+	info, err := os.Lstat(target)
+	if err != nil {
+		retErr := fmt.Errorf("文件不存在或不可访问: %s", target)
+		auditWrite("quarantine_file", params, retErr)
+		return retErr
+	}
+	if !info.Mode().IsRegular() {
+		err := fmt.Errorf("仅支持隔离普通文件: %s", target)
+		auditWrite("quarantine_file", params, err)
+		return err
+	}
+
+	globalQuarantine.mu.Lock()
+	err = globalQuarantine.loadLocked()
+	dir := globalQuarantine.dir
+	globalQuarantine.mu.Unlock()
+	if err != nil {
+		auditWrite("quarantine_file", params, err)
+		return err
+	}
+
+	raw := make([]byte, 8+32)
+	if _, err := rand.Read(raw); err != nil {
+		retErr := fmt.Errorf("生成隔离 ID 失败: %w", err)
+		auditWrite("quarantine_file", params, retErr)
+		return retErr
+	}
+	entry := QuarantineEntryModel{
+		Id:            hex.EncodeToString(raw[:8]),
+		OriginalPath:  target,
+		ModTime:       info.ModTime().Format(time.RFC3339Nano),
+		Reason:        args.Reason,
+		QuarantinedAt: time.Now().Format(time.RFC3339),
+	}
+	entry.StoredPath = filepath.Join(dir, entry.Id+quarantineBlobExt)
+	key := raw[8:]
+	if sddl, err := fileSecuritySDDL(target); err == nil {
+		entry.Security = sddl
+	}
+
+	// 被独占打开时先关闭占用句柄再读取；句柄表不完整或关闭失败时记下原因，随后续错误一并返回
+	var handles *fileHandleIndex
+	var handlesErr error
+	closeHandles := func() {
+		if handles == nil {
+			handles = newFileHandleIndex("quarantine_file", target)
+			n, err := handles.closeFor(t, target, args.Force)
+			reply.HandlesClosed += n
+			handlesErr = err
+		}
+	}
+
+	// 与隔离区同卷时先把原文件改名移入隔离区，原路径立即消失；改名失败（跨卷、被占用）回退到复制后删除
+	var staging string
+	if strings.EqualFold(filepath.VolumeName(target), filepath.VolumeName(dir)) {
+		if staging, err = quarantineByRename(target, key, &entry); err != nil {
+			auditWrite("quarantine_file", params, err)
+			return err
+		}
+	}
+	var method string
+	if staging != "" {
+		method = "rename"
+	} else {
+		src, err := os.Open(target)
+		if err != nil && args.CloseHandles {
+			closeHandles()
+			src, err = os.Open(target)
+		}
+		if err != nil {
+			retErr := fmt.Errorf("读取文件失败: %w", err)
+			if handlesErr != nil {
+				retErr = fmt.Errorf("%w; %v", retErr, handlesErr)
+			}
+			auditWrite("quarantine_file", params, retErr)
+			return retErr
+		}
+		err = writeQuarantineBlob(src, key, &entry)
+		src.Close()
+		if err != nil {
+			auditWrite("quarantine_file", params, err)
+			return err
+		}
+	}
+
+	// 先落索引再删原文件，保证任何时刻都能找回内容
+	entry.DeleteMethod = method
+	record := quarantineRecord{QuarantineEntryModel: entry, Key: hex.EncodeToString(key)}
+	globalQuarantine.mu.Lock()
+	globalQuarantine.records = append(globalQuarantine.records, record)
+	err = globalQuarantine.saveLocked()
+	if err != nil {
+		globalQuarantine.records = globalQuarantine.records[:len(globalQuarantine.records)-1]
+	}
+	globalQuarantine.mu.Unlock()
+	if err != nil {
+		os.Remove(entry.StoredPath)
+		if staging != "" {
+			if rerr := os.Rename(staging, target); rerr != nil {
+				err = fmt.Errorf("%w; 移回原路径失败，文件位于 %s: %v", err, staging, rerr)
+			}
+		}
+		auditWrite("quarantine_file", params, err)
+		return err
+	}
+
+	if staging != "" {
+		// 暂存文件是原文件的明文副本，删不掉时必须如实报告，不能当作已清理
+		cleanup, rmErr := t.removeQuarantineStaging(staging)
+		if rmErr != nil {
+			reply.StagingLeftover = staging
+			reply.StagingCleanup = cleanup
+			params["staging_leftover"] = staging
+			params["staging_cleanup"] = cleanup
+			params["staging_error"] = rmErr.Error()
+		}
+	} else {
+		method = "user"
+		delErr := removeUserMode(target)
+		if delErr != nil && args.CloseHandles {
+			closeHandles()
+			delErr = removeUserMode(target)
+			if delErr != nil && handlesErr != nil {
+				delErr = fmt.Errorf("%v; %w", delErr, handlesErr)
+			}
+		}
+		if delErr != nil && t.Driver != nil {
+			if kerr := deleteFileViaDriver(t.Driver, target); kerr != nil {
+				delErr = fmt.Errorf("%v; 内核删除文件失败: %w", delErr, kerr)
+			} else if _, statErr := os.Lstat(target); statErr == nil {
+				delErr = fmt.Errorf("%v; 内核删除后文件仍存在", delErr)
+			} else {
+				delErr = nil
+				method = "kernel"
+			}
+		}
+
+		globalQuarantine.mu.Lock()
+		i := globalQuarantine.indexLocked(entry.Id)
+		if delErr != nil {
+			if i >= 0 {
+				globalQuarantine.records = append(globalQuarantine.records[:i], globalQuarantine.records[i+1:]...)
+			}
+		} else if i >= 0 {
+			globalQuarantine.records[i].DeleteMethod = method
+		}
+		saveErr := globalQuarantine.saveLocked()
+		globalQuarantine.mu.Unlock()
+		if delErr != nil {
+			os.Remove(entry.StoredPath)
+			retErr := fmt.Errorf("删除原文件失败: %w", delErr)
+			auditWrite("quarantine_file", params, retErr)
+			return retErr
+		}
+		if saveErr != nil {
+			auditWrite("quarantine_file", params, saveErr)
+			return saveErr
+		}
+	}
+
+	entry.DeleteMethod = method
+	reply.Entry = entry
+	id := entry.Id
+	reply.UndoId = journalPush("quarantine_file", map[string]any{"id": id, "path": target}, "restore_quarantined", func(t *ToolkitService) error {
+		_, err := restoreQuarantined(id, "", false)
+		return err
+	})
+	params["id"] = id
+	params["sha256"] = entry.SHA256
+	params["delete_method"] = method
+	params["handles_closed"] = reply.HandlesClosed
+	auditWrite("quarantine_file", params, nil)
+	return nil
+}
+
+// ListQuarantineArgs 隔离区列表请求参数
+type ListQuarantineArgs struct{}
+
+// ListQuarantineReply 隔离区列表响应
+type ListQuarantineReply struct {
+	Dir        string                 `json:"dir"`
+	Total      int                    `json:"total"`
+	TotalBytes int64                  `json:"total_bytes"`
+	Entries    []QuarantineEntryModel `json:"entries"`
+}
+
+// ListQuarantine 列出隔离区中的文件，按隔离时间先后排列
+func (t *ToolkitService) ListQuarantine(_ *ListQuarantineArgs, reply *ListQuarantineReply) error {
+	globalQuarantine.mu.Lock()
+	defer globalQuarantine.mu.Unlock()
+	if err := globalQuarantine.loadLocked(); err != nil {
+		auditWrite("list_quarantine", nil, err)
+		return err
+	}
+	reply.Dir = globalQuarantine.dir
+	reply.Entries = make([]QuarantineEntryModel, 0, len(globalQuarantine.records))
+	for _, r := range globalQuarantine.records {
+		reply.Entries = append(reply.Entries, r.QuarantineEntryModel)
+		reply.TotalBytes += r.Size
+	}
+	reply.Total = len(reply.Entries)
+	auditWrite("list_quarantine", map[string]any{"total": reply.Total}, nil)
+	return nil
+}
+
+// RestoreQuarantinedArgs 还原隔离文件请求参数，Path 为空时还原到原路径
+type RestoreQuarantinedArgs struct {
+	Id        string `json:"id"`
+	Path      string `json:"path"`
+	Overwrite bool   `json:"overwrite"`
+}
+
+// RestoreQuarantinedReply 还原隔离文件响应
+type RestoreQuarantinedReply struct {
+	Entry            QuarantineEntryModel `json:"entry"`
+	RestoredPath     string               `json:"restored_path"`
+	SecurityRestored bool                 `json:"security_restored"`
+	Warning          string               `json:"warning,omitempty"`
+}
+
+// restoreQuarantined 解码隔离文件写回 path（为空时写回原路径），校验 SHA256 后从隔离区移除。
+func restoreQuarantined(id string, path string, overwrite bool) (RestoreQuarantinedReply, error) {
+	var out RestoreQuarantinedReply
+	globalQuarantine.mu.Lock()
+	if err := globalQuarantine.loadLocked(); err != nil {
+		globalQuarantine.mu.Unlock()
+		return out, err
+	}
+	i := globalQuarantine.indexLocked(id)
+	if i < 0 {
+		globalQuarantine.mu.Unlock()
+		return out, fmt.Errorf("隔离记录不存在: %s", id)
+	}
+	// 先从列表中取出，防止并发还原同一条记录；失败时放回
+	record := globalQuarantine.records[i]
+	globalQuarantine.records = append(globalQuarantine.records[:i], globalQuarantine.records[i+1:]...)
+	globalQuarantine.mu.Unlock()
+	putBack := func() {
+		globalQuarantine.mu.Lock()
+		globalQuarantine.records = append(globalQuarantine.records, record)
+		globalQuarantine.mu.Unlock()
+	}
+
+	target := record.OriginalPath
+	if strings.TrimSpace(path) != "" {
+		abs, err := win32Path(path)
+		if err != nil {
+			putBack()
+			return out, fmt.Errorf("路径解析失败: %w", err)
+		}
+		target = abs
+	}
+	if err := writeQuarantinedFile(record, target, overwrite); err != nil {
+		putBack()
+		return out, err
+	}
+
+	out.Entry = record.QuarantineEntryModel
+	out.RestoredPath = target
+	if record.Security != "" {
+		if err := applyFileSecuritySDDL(target, record.Security); err != nil {
+			out.Warning = fmt.Sprintf("恢复 ACL 失败: %v", err)
+		} else {
+			out.SecurityRestored = true
+		}
+	}
+	if mt, err := time.Parse(time.RFC3339Nano, record.ModTime); err == nil {
+		os.Chtimes(target, mt, mt)
+	}
+
+	// 文件已还原：先更新索引再删隔离文件，后续清理失败只作为警告返回
+	globalQuarantine.mu.Lock()
+	err := globalQuarantine.saveLocked()
+	globalQuarantine.mu.Unlock()
+	var warnings []string
+	if out.Warning != "" {
+		warnings = append(warnings, out.Warning)
+	}
+	if err != nil {
+		warnings = append(warnings, fmt.Sprintf("更新隔离索引失败，隔离文件保留: %v", err))
+	} else if err := os.Remove(record.StoredPath); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		warnings = append(warnings, fmt.Sprintf("删除隔离文件失败: %v", err))
+	}
+	out.Warning = strings.Join(warnings, "; ")
+	return out, nil
+}
+
+// writeQuarantinedFile 解码隔离文件到 target，内容 SHA256 与记录不符时删除 target 并报错。
+func writeQuarantinedFile(record quarantineRecord, target string, overwrite bool) error {
+	key, err := hex.DecodeString(record.Key)
+	if err != nil || len(key) == 0 {
+		return fmt.Errorf("隔离记录密钥无效: %s", record.Id)
+	}
+	src, err := os.Open(record.StoredPath)
+	if err != nil {
+		return fmt.Errorf("打开隔离文件失败: %w", err)
+	}
+	defer src.Close()
+	br := bufio.NewReader(src)
+	head := make([]byte, len(quarantineMagic))
+	if _, err := io.ReadFull(br, head); err != nil || !bytes.Equal(head, quarantineMagic) {
+		return fmt.Errorf("隔离文件格式无效: %s", record.StoredPath)
+	}
+
+	if _, err := os.Lstat(target); err == nil && !overwrite {
+		return fmt.Errorf("目标已存在: %s", target)
+	}
+	if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+		return fmt.Errorf("创建目标目录失败: %w", err)
+	}
+	// 先写到同目录临时文件，校验通过后再替换，避免覆盖时损坏已有文件
+	tmp := target + ".restore-" + record.Id
+	dst, err := os.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+	if err != nil {
+		return fmt.Errorf("创建目标文件失败: %w", err)
+	}
+	h := sha256.New()
+	_, err = io.Copy(io.MultiWriter(h, dst), &xorReader{r: br, key: key})
+	if closeErr := dst.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil && hex.EncodeToString(h.Sum(nil)) != record.SHA256 {
+		err = fmt.Errorf("SHA256 与隔离记录不符")
+	}
+	if err == nil {
+		err = os.Rename(tmp, target)
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("还原文件失败: %w", err)
+	}
+	return nil
+}
+
+// RestoreQuarantined 把隔离文件解码还原到原路径（或指定路径），校验 SHA256 并尽量恢复原 ACL 与修改时间
+func (t *ToolkitService) RestoreQuarantined(args *RestoreQuarantinedArgs, reply *RestoreQuarantinedReply) error {
+	params := map[string]any{"id": args.Id, "path": args.Path, "overwrite": args.Overwrite}
+	if strings.TrimSpace(args.Id) == "" {
+		err := fmt.Errorf("id 不能为空")
+		auditWrite("restore_quarantined", params, err)
+		return err
+	}
+	out, err := restoreQuarantined(args.Id, args.Path, args.Overwrite)
+	if err != nil {
+		auditWrite("restore_quarantined", params, err)
+		return err
+	}
+	*reply = out
+	params["restored_path"] = out.RestoredPath
+	params["security_restored"] = out.SecurityRestored
+	auditWrite("restore_quarantined", params, nil)
+	return nil
+}
+
+// PurgeQuarantineArgs 清除隔离文件请求参数。
+// 按 Ids 指定，或 All 清除全部；OlderThanDays 大于 0 时只清除隔离时间早于该天数的记录。
+type PurgeQuarantineArgs struct {
+	Ids           []string `json:"ids"`
+	All           bool     `json:"all"`
+	OlderThanDays int      `json:"older_than_days"`
+	DryRun        bool     `json:"dry_run"`
+	ConfirmToken  string   `json:"confirm_token"`
+}
+
+// PurgeQuarantineResult 单条隔离记录的清除结果
+type PurgeQuarantineResult struct {
+	Id           string `json:"id"`
+	OriginalPath string `json:"original_path"`
+	Success      bool   `json:"success"`
+	Error        string `json:"error,omitempty"`
+}
+
+// PurgeQuarantineReply 清除隔离文件响应
+type PurgeQuarantineReply struct {
+	Purged  int                     `json:"purged"`
+	Failed  int                     `json:"failed"`
+	Results []PurgeQuarantineResult `json:"results"`
+	Plan    *ConfirmPlanModel       `json:"plan,omitempty"`
+}
+
+// PurgeQuarantine 永久删除隔离文件及其索引记录，需要两阶段确认
+func (t *ToolkitService) PurgeQuarantine(args *PurgeQuarantineArgs, reply *PurgeQuarantineReply) error {
+	params := map[string]any{"ids": args.Ids, "all": args.All, "older_than_days": args.OlderThanDays}
+	if len(args.Ids) == 0 && !args.All {
+		err := fmt.Errorf("ids 不能为空（或指定 all）")
+		auditWrite("purge_quarantine", params, err)
+		return err
+	}
+
+	globalQuarantine.mu.Lock()
+	if err := globalQuarantine.loadLocked(); err != nil {
+		globalQuarantine.mu.Unlock()
+		auditWrite("purge_quarantine", params, err)
+		return err
+	}
+	wanted := make(map[string]bool, len(args.Ids))
+	for _, id := range args.Ids {
+		wanted[id] = true
+	}
+	cutoff := time.Time{}
+	if args.OlderThanDays > 0 {
+		cutoff = time.Now().AddDate(0, 0, -args.OlderThanDays)
+	}
+	selected := make(map[string]quarantineRecord)
+	current := make([]PlanTargetModel, 0)
+	for _, r := range globalQuarantine.records {
+		if !args.All && !wanted[r.Id] {
+			continue
+		}
+		if !cutoff.IsZero() {
+			if at, err := time.Parse(time.RFC3339, r.QuarantinedAt); err != nil || !at.Before(cutoff) {
+				continue
+			}
+		}
+		delete(wanted, r.Id)
+		pt := filePlanTarget(r.StoredPath)
+		pt.ObjectName = r.OriginalPath
+		selected[pt.key()] = r
+		current = append(current, pt)
+	}
+	globalQuarantine.mu.Unlock()
+	if !args.All && cutoff.IsZero() {
+		for id := range wanted {
+			err := fmt.Errorf("隔离记录不存在: %s", id)
+			auditWrite("purge_quarantine", params, err)
+			return err
+		}
+	}
+
+	ids := append([]string(nil), args.Ids...)
+	sort.Strings(ids)
+	scope := fmt.Sprintf("%s|%t|%d", strings.Join(ids, ","), args.All, args.OlderThanDays)
+	plan, targets, err := confirmGate("purge_quarantine", scope, args.DryRun, args.ConfirmToken, current)
+	if err != nil {
+		auditWrite("purge_quarantine", params, err)
+		return err
+	}
+	reply.Results = make([]PurgeQuarantineResult, 0, len(current))
+	if plan != nil {
+		reply.Plan = plan
+		params["dry_run"] = true
+		params["selected"] = len(current)
+		auditWrite("purge_quarantine", params, nil)
+		return nil
+	}
+
+	globalQuarantine.mu.Lock()
+	for _, pt := range targets {
+		r := selected[pt.key()]
+		res := PurgeQuarantineResult{Id: r.Id, OriginalPath: r.OriginalPath}
+		err := os.Remove(r.StoredPath)
+		if err == nil || errors.Is(err, fs.ErrNotExist) {
+			// 改名隔离时未能删掉的暂存文件随记录一起清除
+			err = os.Remove(quarantineStagingPath(r.StoredPath))
+		}
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			res.Error = err.Error()
+			reply.Failed++
+		} else {
+			if i := globalQuarantine.indexLocked(r.Id); i >= 0 {
+				globalQuarantine.records = append(globalQuarantine.records[:i], globalQuarantine.records[i+1:]...)
+			}
+			res.Success = true
+			reply.Purged++
+		}
+		reply.Results = append(reply.Results, res)
+	}
+	err = globalQuarantine.saveLocked()
+	globalQuarantine.mu.Unlock()
+
+	params["purged"] = reply.Purged
+	params["failed"] = reply.Failed
+	auditWrite("purge_quarantine", params, err)
+	return err
+}
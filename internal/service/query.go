@@ -0,0 +1,441 @@
+package service
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// QueryFilter 单个字段过滤条件，Field 为返回结构中的 JSON 字段名。
+// Op 取值：eq（相等，字符串不区分大小写）、contains（子串，不区分大小写）、
+// regex（RE2 正则，需要忽略大小写时加 (?i)）、range（闭区间 [min,max]，任一端可省略）。
+// 超过 2^53 的整数请以字符串传入，支持 0x 前缀。
+type QueryFilter struct {
+	Field string `json:"field"`
+	Op    string `json:"op"`
+	Value any    `json:"value,omitempty"`
+	Min   any    `json:"min,omitempty"`
+	Max   any    `json:"max,omitempty"`
+}
+
+// QuerySort 排序键，多个键按顺序依次比较。
+type QuerySort struct {
+	Field string `json:"field"`
+	Desc  bool   `json:"desc"`
+}
+
+// QuerySpec 列表类接口共用的查询条件：先过滤，再排序，最后分页。
+// Limit 为 0 表示不分页；Cursor 非空时忽略 Offset，从上一页返回的 next_cursor 继续。
+type QuerySpec struct {
+	Filters []QueryFilter `json:"filters"`
+	Sort    []QuerySort   `json:"sort"`
+	Offset  int           `json:"offset"`
+	Limit   int           `json:"limit"`
+	Cursor  string        `json:"cursor"`
+}
+
+// QueryPageModel 查询结果统计。Total 为过滤前总数，Matched 为过滤后总数。
+type QueryPageModel struct {
+	Total      int    `json:"total"`
+	Matched    int    `json:"matched"`
+	Offset     int    `json:"offset"`
+	Returned   int    `json:"returned"`
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+// 可参与查询的字段值类别。
+const (
+	queryClassString = iota
+	queryClassInt
+	queryClassUint
+	queryClassFloat
+	queryClassBool
+)
+
+type queryField struct {
+	index []int
+	class int
+}
+
+type queryOperand struct {
+	s string
+	i int64
+	u uint64
+	f float64
+	b bool
+}
+
+type compiledFilter struct {
+	field    queryField
+	op       string
+	value    queryOperand
+	min, max *queryOperand
+	re       *regexp.Regexp
+}
+
+type compiledSort struct {
+	field queryField
+	desc  bool
+}
+
+var queryFieldCache sync.Map // reflect.Type -> map[string]queryField
+
+// queryFields 按 JSON 标签收集结构体（含嵌入结构体）中可查询的标量字段。
+func queryFields(typ reflect.Type) map[string]queryField {
+	if cached, ok := queryFieldCache.Load(typ); ok {
+		return cached.(map[string]queryField)
+	}
+
+	fields := make(map[string]queryField)
+	for _, sf := range reflect.VisibleFields(typ) {
+		if !sf.IsExported() || sf.Anonymous {
+			continue
+		}
+		name, _, _ := strings.Cut(sf.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = sf.Name
+		}
+
+		var class int
+		switch sf.Type.Kind() {
+		case reflect.String:
+			class = queryClassString
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			class = queryClassInt
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+			class = queryClassUint
+		case reflect.Float32, reflect.Float64:
+			class = queryClassFloat
+		case reflect.Bool:
+			class = queryClassBool
+		default:
+			continue
+		}
+		fields[name] = queryField{index: sf.Index, class: class}
+	}
+
+	queryFieldCache.Store(typ, fields)
+	return fields
+}
+
+func parseQueryOperand(class int, v any) (queryOperand, error) {
+	var out queryOperand
+	switch class {
+	case queryClassString:
+		s, ok := v.(string)
+		if !ok {
+			return out, fmt.Errorf("需要字符串")
+		}
+		out.s = s
+	case queryClassInt:
+		switch x := v.(type) {
+		case float64:
+			out.i = int64(x)
+		case string:
+			n, err := strconv.ParseInt(strings.TrimSpace(x), 0, 64)
+			if err != nil {
+				return out, fmt.Errorf("需要整数")
+			}
+			out.i = n
+		default:
+			return out, fmt.Errorf("需要整数")
+		}
+	case queryClassUint:
+		switch x := v.(type) {
+		case float64:
+			if x < 0 {
+				return out, fmt.Errorf("需要非负整数")
+			}
+			out.u = uint64(x)
+		case string:
+			n, err := strconv.ParseUint(strings.TrimSpace(x), 0, 64)
+			if err != nil {
+				return out, fmt.Errorf("需要非负整数")
+			}
+			out.u = n
+		default:
+			return out, fmt.Errorf("需要非负整数")
+		}
+	case queryClassFloat:
+		switch x := v.(type) {
+		case float64:
+			out.f = x
+		case string:
+			n, err := strconv.ParseFloat(strings.TrimSpace(x), 64)
+			if err != nil {
+				return out, fmt.Errorf("需要数值")
+			}
+			out.f = n
+		default:
+			return out, fmt.Errorf("需要数值")
+		}
+	case queryClassBool:
+		switch x := v.(type) {
+		case bool:
+			out.b = x
+		case string:
+			b, err := strconv.ParseBool(strings.TrimSpace(x))
+			if err != nil {
+				return out, fmt.Errorf("需要布尔值")
+			}
+			out.b = b
+		default:
+			return out, fmt.Errorf("需要布尔值")
+		}
+	}
+	return out, nil
+}
+
+// compareQueryValue 比较字段值与操作数，字符串比较不区分大小写。
+func compareQueryValue(class int, v reflect.Value, o queryOperand) int {
+	switch class {
+	case queryClassString:
+		return strings.Compare(strings.ToLower(v.String()), strings.ToLower(o.s))
+	case queryClassInt:
+		return cmpOrdered(v.Int(), o.i)
+	case queryClassUint:
+		return cmpOrdered(v.Uint(), o.u)
+	case queryClassFloat:
+		return cmpOrdered(v.Float(), o.f)
+	default:
+		a, b := v.Bool(), o.b
+		switch {
+		case a == b:
+			return 0
+		case !a:
+			return -1
+		default:
+			return 1
+		}
+	}
+}
+
+func compareQueryFields(class int, a reflect.Value, b reflect.Value) int {
+	switch class {
+	case queryClassString:
+		return strings.Compare(strings.ToLower(a.String()), strings.ToLower(b.String()))
+	case queryClassInt:
+		return cmpOrdered(a.Int(), b.Int())
+	case queryClassUint:
+		return cmpOrdered(a.Uint(), b.Uint())
+	case queryClassFloat:
+		return cmpOrdered(a.Float(), b.Float())
+	default:
+		return compareQueryValue(class, a, queryOperand{b: b.Bool()})
+	}
+}
+
+func cmpOrdered[V int64 | uint64 | float64](a V, b V) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	default:
+		return 0
+	}
+}
+
+func compileQueryFilter(fields map[string]queryField, f QueryFilter) (compiledFilter, error) {
+	field, ok := fields[f.Field]
+	if !ok {
+		return compiledFilter{}, fmt.Errorf("query 不支持的字段: %s", f.Field)
+	}
+	cf := compiledFilter{field: field, op: strings.ToLower(strings.TrimSpace(f.Op))}
+	if cf.op == "" {
+		cf.op = "eq"
+	}
+
+	var err error
+	switch cf.op {
+	case "eq":
+		if cf.value, err = parseQueryOperand(field.class, f.Value); err != nil {
+			return cf, fmt.Errorf("query 字段 %s 的 value %w", f.Field, err)
+		}
+	case "contains", "regex":
+		if field.class != queryClassString {
+			return cf, fmt.Errorf("query 字段 %s 不是字符串，不支持 %s", f.Field, cf.op)
+		}
+		if cf.value, err = parseQueryOperand(field.class, f.Value); err != nil {
+			return cf, fmt.Errorf("query 字段 %s 的 value %w", f.Field, err)
+		}
+		if cf.op == "regex" {
+			if cf.re, err = regexp.Compile(cf.value.s); err != nil {
+				return cf, fmt.Errorf("query 字段 %s 的正则无效: %w", f.Field, err)
+			}
+		}
+	case "range":
+		if f.Min == nil && f.Max == nil {
+			return cf, fmt.Errorf("query 字段 %s 的 range 需要 min 或 max", f.Field)
+		}
+		if f.Min != nil {
+			o, err := parseQueryOperand(field.class, f.Min)
+			if err != nil {
+				return cf, fmt.Errorf("query 字段 %s 的 min %w", f.Field, err)
+			}
+			cf.min = &o
+		}
+		if f.Max != nil {
+			o, err := parseQueryOperand(field.class, f.Max)
+			if err != nil {
+				return cf, fmt.Errorf("query 字段 %s 的 max %w", f.Field, err)
+			}
+			cf.max = &o
+		}
+	default:
+		return cf, fmt.Errorf("query op 仅支持 eq/contains/regex/range")
+	}
+	return cf, nil
+}
+
+func (cf compiledFilter) match(item reflect.Value) bool {
+	v := item.FieldByIndex(cf.field.index)
+	switch cf.op {
+	case "eq":
+		return compareQueryValue(cf.field.class, v, cf.value) == 0
+	case "contains":
+		return strings.Contains(strings.ToLower(v.String()), strings.ToLower(cf.value.s))
+	case "regex":
+		return cf.re.MatchString(v.String())
+	default:
+		if cf.min != nil && compareQueryValue(cf.field.class, v, *cf.min) < 0 {
+			return false
+		}
+		if cf.max != nil && compareQueryValue(cf.field.class, v, *cf.max) > 0 {
+			return false
+		}
+		return true
+	}
+}
+
+// queryFingerprint 游标绑定过滤与排序条件，换条件后旧游标失效。
+func queryFingerprint(spec *QuerySpec) string {
+	data, _ := json.Marshal(struct {
+		Filters []QueryFilter `json:"f"`
+		Sort    []QuerySort   `json:"s"`
+	}{spec.Filters, spec.Sort})
+	h := fnv.New32a()
+	h.Write(data)
+	return strconv.FormatUint(uint64(h.Sum32()), 16)
+}
+
+func encodeQueryCursor(spec *QuerySpec, offset int) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(fmt.Sprintf("%s:%d", queryFingerprint(spec), offset)))
+}
+
+func decodeQueryCursor(spec *QuerySpec) (int, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(spec.Cursor)
+	if err != nil {
+		return 0, fmt.Errorf("cursor 无效")
+	}
+	fp, offsetPart, ok := strings.Cut(string(raw), ":")
+	if !ok {
+		return 0, fmt.Errorf("cursor 无效")
+	}
+	offset, err := strconv.Atoi(offsetPart)
+	if err != nil || offset < 0 {
+		return 0, fmt.Errorf("cursor 无效")
+	}
+	if fp != queryFingerprint(spec) {
+		return 0, fmt.Errorf("cursor 与查询条件不匹配，请从第一页重新查询")
+	}
+	return offset, nil
+}
+
+// applyQuery 对结果集依次执行过滤、排序与分页；spec 为 nil 时原样返回并只统计总数。
+func applyQuery[T any](items []T, spec *QuerySpec) ([]T, *QueryPageModel, error) {
+	page := &QueryPageModel{Total: len(items), Matched: len(items), Returned: len(items)}
+	if spec == nil {
+		return items, page, nil
+	}
+	if spec.Offset < 0 || spec.Limit < 0 {
+		return nil, nil, fmt.Errorf("query offset/limit 不能为负数")
+	}
+
+	fields := queryFields(reflect.TypeOf((*T)(nil)).Elem())
+	filters := make([]compiledFilter, 0, len(spec.Filters))
+	for _, f := range spec.Filters {
+		cf, err := compileQueryFilter(fields, f)
+		if err != nil {
+			return nil, nil, err
+		}
+		filters = append(filters, cf)
+	}
+	sorts := make([]compiledSort, 0, len(spec.Sort))
+	for _, s := range spec.Sort {
+		field, ok := fields[s.Field]
+		if !ok {
+			return nil, nil, fmt.Errorf("query 不支持的排序字段: %s", s.Field)
+		}
+		sorts = append(sorts, compiledSort{field: field, desc: s.Desc})
+	}
+
+	offset := spec.Offset
+	if spec.Cursor != "" {
+		var err error
+		if offset, err = decodeQueryCursor(spec); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	matched := items
+	if len(filters) > 0 {
+		matched = make([]T, 0, len(items))
+		for _, item := range items {
+			v := reflect.ValueOf(item)
+			ok := true
+			for _, cf := range filters {
+				if !cf.match(v) {
+					ok = false
+					break
+				}
+			}
+			if ok {
+				matched = append(matched, item)
+			}
+		}
+	} else if len(sorts) > 0 {
+		matched = append([]T(nil), items...)
+	}
+
+	if len(sorts) > 0 {
+		sort.SliceStable(matched, func(i, j int) bool {
+			a, b := reflect.ValueOf(matched[i]), reflect.ValueOf(matched[j])
+			for _, s := range sorts {
+				c := compareQueryFields(s.field.class, a.FieldByIndex(s.field.index), b.FieldByIndex(s.field.index))
+				if c == 0 {
+					continue
+				}
+				if s.desc {
+					return c > 0
+				}
+				return c < 0
+			}
+			return false
+		})
+	}
+
+	page.Matched = len(matched)
+	page.Offset = offset
+	if offset > len(matched) {
+		offset = len(matched)
+	}
+	end := len(matched)
+	if spec.Limit > 0 && offset+spec.Limit < end {
+		end = offset + spec.Limit
+		page.NextCursor = encodeQueryCursor(spec, end)
+	}
+	result := matched[offset:end]
+	page.Returned = len(result)
+	return result, page, nil
+}
@@ -10,7 +10,6 @@ import (
 	"sort"
 	"strconv"
 	"strings"
-	"syscall"
 	"time"
 
 	"github.com/OpenSysKit/backend/internal/driver"
@@ -44,18 +43,24 @@ type ProcessInfoModel struct {
 	ThreadCount     uint32 `json:"thread_count"`
 	WorkingSetSize  uint64 `json:"working_set_size"`
 	ImageName       string `json:"image_name"`
+	CreateTime      uint64 `json:"create_time"`
+	ProcessKey      string `json:"process_key"`
+	IdentitySource  string `json:"identity_source"`
 }
 
 // EnumProcessesArgs 枚举进程请求参数
-type EnumProcessesArgs struct{}
+type EnumProcessesArgs struct {
+	Query *QuerySpec `json:"query,omitempty"`
+}
 
 // EnumProcessesReply 枚举进程响应
 type EnumProcessesReply struct {
 	Processes []ProcessInfoModel `json:"processes"`
+	Page      *QueryPageModel    `json:"page"`
 }
 
 // EnumProcesses 枚举系统进程
-func (t *ToolkitService) EnumProcesses(_ *EnumProcessesArgs, reply *EnumProcessesReply) error {
+func (t *ToolkitService) EnumProcesses(args *EnumProcessesArgs, reply *EnumProcessesReply) error {
 	if t.Driver == nil {
 		return fmt.Errorf("驱动未加载")
 	}
@@ -66,48 +71,20 @@ func (t *ToolkitService) EnumProcesses(_ *EnumProcessesArgs, reply *EnumProcesse
 	if err != nil {
 		return fmt.Errorf("枚举进程失败: %w", err)
 	}
-
-	if len(outBuf) < 8 {
-		return fmt.Errorf("返回数据过小")
-	}
-
-	header := driver.ProcessListHeader{}
-	err = binary.Read(bytes.NewReader(outBuf[:8]), binary.LittleEndian, &header)
-	if err != nil {
-		return fmt.Errorf("解析头部失败: %w", err)
+	if reply.Processes, err = parseDriverProcessList(outBuf); err != nil {
+		return fmt.Errorf("解析进程列表失败: %w", err)
 	}
 
-	// 解析进程信息
-	offset := uint32(8)
-	reply.Processes = make([]ProcessInfoModel, 0, header.Count)
-	for i := uint32(0); i < header.Count; i++ {
-		if offset+uint32(binary.Size(driver.ProcessInfo{})) > uint32(len(outBuf)) {
-			break
-		}
-
-		info := driver.ProcessInfo{}
-		err = binary.Read(bytes.NewReader(outBuf[offset:offset+uint32(binary.Size(info))]), binary.LittleEndian, &info)
-		if err != nil {
-			break
-		}
-
-		reply.Processes = append(reply.Processes, ProcessInfoModel{
-			ProcessId:       info.ProcessId,
-			ParentProcessId: info.ParentProcessId,
-			ThreadCount:     info.ThreadCount,
-			WorkingSetSize:  info.WorkingSetSize,
-			ImageName:       syscall.UTF16ToString(info.ImageName[:]),
-		})
-
-		offset += uint32(binary.Size(info))
-	}
-
-	return nil
+	fillProcessIdentity(reply.Processes)
+	reply.Processes, reply.Page, err = applyQuery(reply.Processes, args.Query)
+	return err
 }
 
 // KillProcessArgs 结束进程请求参数
 type KillProcessArgs struct {
-	ProcessId uint32 `json:"process_id"`
+	ProcessId  uint32 `json:"process_id"`
+	ProcessKey string `json:"process_key,omitempty"`
+	Force      bool   `json:"force"`
 }
 
 // KillProcessReply 结束进程响应
@@ -119,8 +96,10 @@ type KillProcessReply struct {
 
 // TaskKillProcessArgs 使用用户态 taskkill 结束进程请求参数。
 type TaskKillProcessArgs struct {
-	ProcessId uint32 `json:"process_id"`
-	Tree      bool   `json:"tree"`
+	ProcessId  uint32 `json:"process_id"`
+	ProcessKey string `json:"process_key,omitempty"`
+	Tree       bool   `json:"tree"`
+	Force      bool   `json:"force"`
 }
 
 // TaskKillProcessReply 使用用户态 taskkill 结束进程响应。
@@ -137,6 +116,14 @@ func (t *ToolkitService) KillProcess(args *KillProcessArgs, reply *KillProcessRe
 		auditWrite("kill_process", map[string]any{"process_id": args.ProcessId}, err)
 		return err
 	}
+	if err := t.verifyProcessKey(args.ProcessId, args.ProcessKey); err != nil {
+		auditWrite("kill_process", map[string]any{"process_id": args.ProcessId, "process_key": args.ProcessKey}, err)
+		return err
+	}
+	if err := t.guardTarget("kill_process", args.ProcessId, "", args.Force); err != nil {
+		auditWrite("kill_process", map[string]any{"process_id": args.ProcessId}, err)
+		return err
+	}
 
 	result, err := executeKillProcess(t.Driver, args.ProcessId)
 	if err != nil {
@@ -175,6 +162,14 @@ func (t *ToolkitService) TaskKillProcess(args *TaskKillProcessArgs, reply *TaskK
 		auditWrite("taskkill_process", map[string]any{"process_id": args.ProcessId, "tree": args.Tree}, err)
 		return err
 	}
+	if err := t.verifyProcessKey(args.ProcessId, args.ProcessKey); err != nil {
+		auditWrite("taskkill_process", map[string]any{"process_id": args.ProcessId, "process_key": args.ProcessKey}, err)
+		return err
+	}
+	if err := t.guardTaskKillTargets(args.ProcessId, args.Tree, args.Force); err != nil {
+		auditWrite("taskkill_process", map[string]any{"process_id": args.ProcessId, "tree": args.Tree}, err)
+		return err
+	}
 
 	commandArgs := []string{"/PID", strconv.FormatUint(uint64(args.ProcessId), 10), "/F"}
 	if args.Tree {
@@ -209,8 +204,9 @@ func (t *ToolkitService) TaskKillProcess(args *TaskKillProcessArgs, reply *TaskK
 
 // ElevateProcessArgs 提权进程请求参数
 type ElevateProcessArgs struct {
-	ProcessId uint32 `json:"process_id"`
-	Level     uint32 `json:"level"`
+	ProcessId  uint32 `json:"process_id"`
+	ProcessKey string `json:"process_key,omitempty"`
+	Level      uint32 `json:"level"`
 }
 
 // ElevateProcessReply 提权进程响应
@@ -228,6 +224,10 @@ func (t *ToolkitService) ElevateProcess(args *ElevateProcessArgs, reply *Elevate
 		auditWrite("elevate_process", map[string]any{"process_id": args.ProcessId, "level": args.Level}, err)
 		return err
 	}
+	if err := t.verifyProcessKey(args.ProcessId, args.ProcessKey); err != nil {
+		auditWrite("elevate_process", map[string]any{"process_id": args.ProcessId, "process_key": args.ProcessKey}, err)
+		return err
+	}
 
 	reply.Level = args.Level
 	reply.LevelName = levelName
@@ -252,6 +252,11 @@ func (t *ToolkitService) ElevateProcess(args *ElevateProcessArgs, reply *Elevate
 		return err
 	}
 
+	original := "unknown"
+	if account, qErr := processAccountName(args.ProcessId); qErr == nil {
+		original = account
+	}
+
 	req := driver.ProcessElevateRequest{
 		ProcessId: args.ProcessId,
 		Level:     args.Level,
@@ -273,6 +278,7 @@ func (t *ToolkitService) ElevateProcess(args *ElevateProcessArgs, reply *Elevate
 	}
 
 	reply.Success = true
+	globalStateLedger.record(StateKindElevated, args.ProcessId, t.currentProcessKey(args.ProcessId), original, levelName)
 	auditWrite("elevate_process", map[string]any{
 		"process_id": args.ProcessId,
 		"level":      args.Level,
@@ -284,14 +290,19 @@ func (t *ToolkitService) ElevateProcess(args *ElevateProcessArgs, reply *Elevate
 // ProtectProcessArgs 保护进程请求参数
 // Level 使用 PS_PROTECTION.Level 编码：(Signer << 4) | Type
 // 默认 0x31 (Antimalware-Light)
+// AutoRevertAfterMs 非 0 时到期自动取消保护
 type ProtectProcessArgs struct {
-	ProcessId uint32 `json:"process_id"`
-	Level     *uint8 `json:"level,omitempty"`
+	ProcessId         uint32 `json:"process_id"`
+	ProcessKey        string `json:"process_key,omitempty"`
+	Level             *uint8 `json:"level,omitempty"`
+	AutoRevertAfterMs uint32 `json:"auto_revert_after_ms,omitempty"`
 }
 
 // ProtectProcessReply 保护进程响应
 type ProtectProcessReply struct {
-	Success bool `json:"success"`
+	Success  bool   `json:"success"`
+	LeaseId  int64  `json:"lease_id,omitempty"`
+	RevertAt string `json:"revert_at,omitempty"`
 }
 
 // ProtectProcess 保护指定进程（基于 OpenSysKit PPL）
@@ -301,6 +312,10 @@ func (t *ToolkitService) ProtectProcess(args *ProtectProcessArgs, reply *Protect
 		auditWrite("protect_process", map[string]any{"process_id": args.ProcessId, "level": args.Level}, err)
 		return err
 	}
+	if err := t.verifyProcessKey(args.ProcessId, args.ProcessKey); err != nil {
+		auditWrite("protect_process", map[string]any{"process_id": args.ProcessId, "process_key": args.ProcessKey}, err)
+		return err
+	}
 
 	if args.ProcessId == 0 || args.ProcessId == 4 {
 		err := fmt.Errorf("process_id 不合法，不能为 0 或 4")
@@ -308,11 +323,21 @@ func (t *ToolkitService) ProtectProcess(args *ProtectProcessArgs, reply *Protect
 		return err
 	}
 
+	if err := validateAutoRevertMs(args.AutoRevertAfterMs); err != nil {
+		auditWrite("protect_process", map[string]any{"process_id": args.ProcessId, "level": args.Level, "auto_revert_after_ms": args.AutoRevertAfterMs}, err)
+		return err
+	}
+
 	level := uint8(0x31)
 	if args.Level != nil {
 		level = *args.Level
 	}
 
+	original := "unknown"
+	if prev, qErr := queryProcessProtection(args.ProcessId); qErr == nil {
+		original = formatProtectionLevel(prev)
+	}
+
 	req := driver.ProcessProtectRequest{ProcessId: args.ProcessId, ProtectionLevel: level}
 	inBuf := new(bytes.Buffer)
 	err := binary.Write(inBuf, binary.LittleEndian, req)
@@ -329,13 +354,28 @@ func (t *ToolkitService) ProtectProcess(args *ProtectProcessArgs, reply *Protect
 	}
 
 	reply.Success = true
-	auditWrite("protect_process", map[string]any{"process_id": args.ProcessId, "level": level}, nil)
+	globalStateLedger.record(StateKindProtected, args.ProcessId, t.currentProcessKey(args.ProcessId), original, formatProtectionLevel(level))
+	params := map[string]any{"process_id": args.ProcessId, "level": level}
+	if args.AutoRevertAfterMs > 0 {
+		leaseID, revertAt := globalRevertLeases.schedule(t, StateKindProtected, args.ProcessId, time.Duration(args.AutoRevertAfterMs)*time.Millisecond)
+		reply.LeaseId = leaseID
+		reply.RevertAt = revertAt.Format(time.RFC3339)
+		params["auto_revert_after_ms"] = args.AutoRevertAfterMs
+		params["lease_id"] = leaseID
+	}
+	pid := args.ProcessId
+	key := t.currentProcessKey(pid)
+	journalPush("protect_process", map[string]any{"process_id": pid, "process_key": key, "level": level}, "unprotect_process", func(t *ToolkitService) error {
+		return t.UnprotectProcess(&UnprotectProcessArgs{ProcessId: pid, ProcessKey: key}, &UnprotectProcessReply{})
+	})
+	auditWrite("protect_process", params, nil)
 	return nil
 }
 
 // UnprotectProcessArgs 取消保护进程请求参数
 type UnprotectProcessArgs struct {
-	ProcessId uint32 `json:"process_id"`
+	ProcessId  uint32 `json:"process_id"`
+	ProcessKey string `json:"process_key,omitempty"`
 }
 
 // UnprotectProcessReply 取消保护进程响应
@@ -345,17 +385,40 @@ type UnprotectProcessReply struct {
 
 // UnprotectProcess 取消保护指定进程（恢复原始 Protection）
 func (t *ToolkitService) UnprotectProcess(args *UnprotectProcessArgs, reply *UnprotectProcessReply) error {
+	prevLevel, err := t.unprotectProcess(args, reply)
+	if err != nil {
+		return err
+	}
+	if prevLevel != 0 {
+		pid := args.ProcessId
+		key := t.currentProcessKey(pid)
+		journalPush("unprotect_process", map[string]any{"process_id": pid, "process_key": key, "previous_level": prevLevel}, "protect_process", func(t *ToolkitService) error {
+			return t.ProtectProcess(&ProtectProcessArgs{ProcessId: pid, ProcessKey: key, Level: &prevLevel}, &ProtectProcessReply{})
+		})
+	}
+	return nil
+}
+
+// unprotectProcess 执行取消保护并清理台账，不登记撤销日志；返回取消前的保护级别（无法读取时为 0）。
+func (t *ToolkitService) unprotectProcess(args *UnprotectProcessArgs, reply *UnprotectProcessReply) (uint8, error) {
 	if t.Driver == nil {
 		err := fmt.Errorf("驱动未加载")
 		auditWrite("unprotect_process", map[string]any{"process_id": args.ProcessId}, err)
-		return err
+		return 0, err
 	}
+	if err := t.verifyProcessKey(args.ProcessId, args.ProcessKey); err != nil {
+		auditWrite("unprotect_process", map[string]any{"process_id": args.ProcessId, "process_key": args.ProcessKey}, err)
+		return 0, err
+	}
+
+	// 取消前记下当前保护级别，撤销时据此重新保护
+	prevLevel, prevErr := queryProcessProtection(args.ProcessId)
 
 	req := driver.ProcessRequest{ProcessId: args.ProcessId}
 	inBuf := new(bytes.Buffer)
 	err := binary.Write(inBuf, binary.LittleEndian, req)
 	if err != nil {
-		return fmt.Errorf("构造请求失败: %w", err)
+		return 0, fmt.Errorf("构造请求失败: %w", err)
 	}
 
 	_, err = t.Driver.IoControl(driver.IOCTL_UNPROTECT_PROCESS, inBuf.Bytes(), 0)
@@ -363,12 +426,16 @@ func (t *ToolkitService) UnprotectProcess(args *UnprotectProcessArgs, reply *Unp
 		reply.Success = false
 		retErr := fmt.Errorf("取消保护进程失败: %w", err)
 		auditWrite("unprotect_process", map[string]any{"process_id": args.ProcessId}, retErr)
-		return retErr
+		return 0, retErr
 	}
 
 	reply.Success = true
+	globalStateLedger.clear(StateKindProtected, args.ProcessId)
 	auditWrite("unprotect_process", map[string]any{"process_id": args.ProcessId}, nil)
-	return nil
+	if prevErr != nil {
+		return 0, nil
+	}
+	return prevLevel, nil
 }
 
 // SetProtectPolicyArgs 设置 WinDrive 保护策略请求参数
@@ -391,18 +458,42 @@ func (t *ToolkitService) SetProtectPolicy(args *SetProtectPolicyArgs, reply *Set
 }
 
 // ListDirectoryArgs 文件管理-列目录请求参数
-// Path 支持绝对路径，空值时使用系统盘根目录。
+// Path 支持绝对路径，空值时返回各盘符根目录。
+// SortBy 为 name（默认）/size/mod_time/create_time/ext；DirsFirst 缺省为 true。
+// Pattern 为不区分大小写的通配符（* 与 ?），同时作用于文件与目录；
+// 隐藏与系统文件默认不返回，IncludeHidden 为 true 时返回；Limit 为 0 表示不分页。
 type ListDirectoryArgs struct {
-	Path string `json:"path"`
+	Path          string `json:"path"`
+	SortBy        string `json:"sort_by"`
+	Desc          bool   `json:"desc"`
+	DirsFirst     *bool  `json:"dirs_first"`
+	Pattern       string `json:"pattern"`
+	IncludeHidden bool   `json:"include_hidden"`
+	IncludeOwner  bool   `json:"include_owner"`
+	Offset        int    `json:"offset"`
+	Limit         int    `json:"limit"`
 }
 
 // FileEntryModel 单个目录项
 type FileEntryModel struct {
-	Name    string `json:"name"`
-	Path    string `json:"path"`
-	IsDir   bool   `json:"is_dir"`
-	Size    int64  `json:"size"`
-	ModTime string `json:"mod_time"`
+	Name         string `json:"name"`
+	Path         string `json:"path"`
+	IsDir        bool   `json:"is_dir"`
+	Size         int64  `json:"size"`
+	ModTime      string `json:"mod_time"`
+	CreateTime   string `json:"create_time,omitempty"`
+	AccessTime   string `json:"access_time,omitempty"`
+	Attributes   uint32 `json:"attributes"`
+	Hidden       bool   `json:"hidden"`
+	System       bool   `json:"system"`
+	ReadOnly     bool   `json:"readonly"`
+	ReparsePoint bool   `json:"reparse_point"`
+	LinkType     string `json:"link_type,omitempty"`
+	LinkTarget   string `json:"link_target,omitempty"`
+	Owner        string `json:"owner,omitempty"`
+
+	modTime    time.Time
+	createTime time.Time
 }
 
 // ListDirectoryReply 文件管理-列目录响应
@@ -410,29 +501,102 @@ type ListDirectoryReply struct {
 	CurrentPath string           `json:"current_path"`
 	ParentPath  string           `json:"parent_path"`
 	Entries     []FileEntryModel `json:"entries"`
+	Page        *QueryPageModel  `json:"page"`
 }
 
-// ListDirectory 列出目录内容（目录优先、名称排序）
+const (
+	fileAttributeReadOnly     = 0x1
+	fileAttributeHidden       = 0x2
+	fileAttributeSystem       = 0x4
+	fileAttributeDirectory    = 0x10
+	fileAttributeReparsePoint = 0x400
+)
+
+// ListDirectory 列出目录内容，支持排序、通配符过滤、隐藏文件与分页；空路径时列出盘符根目录
 func (t *ToolkitService) ListDirectory(args *ListDirectoryArgs, reply *ListDirectoryReply) error {
-	path := filepath.Clean(args.Path)
-	if path == "." || path == "" {
-		path = `C:\\`
+	var models []FileEntryModel
+	if strings.TrimSpace(args.Path) == "" {
+		volumes, err := listVolumes()
+		if err != nil {
+			return fmt.Errorf("枚举盘符失败: %w", err)
+		}
+		models = make([]FileEntryModel, 0, len(volumes))
+		for _, v := range volumes {
+			models = append(models, FileEntryModel{Name: strings.TrimSuffix(v.Root, `\`), Path: v.Root, IsDir: true})
+		}
+	} else {
+		absPath, err := filepath.Abs(filepath.Clean(args.Path))
+		if err != nil {
+			return fmt.Errorf("路径解析失败: %w", err)
+		}
+		if models, err = readDirectoryEntries(absPath, args.IncludeOwner); err != nil {
+			return err
+		}
+		reply.CurrentPath = absPath
+		reply.ParentPath = filepath.Dir(absPath)
+		if reply.ParentPath == absPath {
+			reply.ParentPath = ""
+		}
 	}
 
-	absPath, err := filepath.Abs(path)
-	if err != nil {
-		return fmt.Errorf("路径解析失败: %w", err)
+	pattern := strings.ToLower(args.Pattern)
+	if pattern != "" {
+		if _, err := filepath.Match(pattern, ""); err != nil {
+			return fmt.Errorf("pattern 无效: %w", err)
+		}
+	}
+	total := len(models)
+	filtered := models[:0]
+	for _, m := range models {
+		if !args.IncludeHidden && (m.Hidden || m.System) {
+			continue
+		}
+		if pattern != "" {
+			if ok, _ := filepath.Match(pattern, strings.ToLower(m.Name)); !ok {
+				continue
+			}
+		}
+		filtered = append(filtered, m)
 	}
 
-	entries, err := os.ReadDir(absPath)
+	less, err := fileEntryLess(args.SortBy)
 	if err != nil {
-		return fmt.Errorf("读取目录失败: %w", err)
+		return err
 	}
+	dirsFirst := args.DirsFirst == nil || *args.DirsFirst
+	sort.SliceStable(filtered, func(i, j int) bool {
+		a, b := filtered[i], filtered[j]
+		if dirsFirst && a.IsDir != b.IsDir {
+			return a.IsDir
+		}
+		if args.Desc {
+			return less(b, a)
+		}
+		return less(a, b)
+	})
 
-	reply.CurrentPath = absPath
-	reply.ParentPath = filepath.Dir(absPath)
-	if reply.ParentPath == absPath {
-		reply.ParentPath = ""
+	offset := args.Offset
+	if offset < 0 {
+		offset = 0
+	}
+	if offset > len(filtered) {
+		offset = len(filtered)
+	}
+	end := len(filtered)
+	if args.Limit > 0 && offset+args.Limit < end {
+		end = offset + args.Limit
+	}
+
+	reply.Entries = filtered[offset:end]
+	reply.Page = &QueryPageModel{Total: total, Matched: len(filtered), Offset: offset, Returned: end - offset}
+	return nil
+}
+
+// readDirectoryEntries 读取目录项及其属性、时间与链接目标；includeOwner 时逐项查询所有者（较慢）。
+func readDirectoryEntries(absPath string, includeOwner bool) ([]FileEntryModel, error) {
+	entries, err := os.ReadDir(absPath)
+	if err != nil {
+		return nil, fmt.Errorf("读取目录失败: %w", err)
 	}
 
 	models := make([]FileEntryModel, 0, len(entries))
@@ -448,38 +612,79 @@ func (t *ToolkitService) ListDirectory(args *ListDirectoryArgs, reply *ListDirec
 			if !entry.IsDir() {
 				model.Size = info.Size()
 			}
-			model.ModTime = info.ModTime().Format(time.RFC3339)
+			model.modTime = info.ModTime()
+			model.ModTime = model.modTime.Format(time.RFC3339)
+
+			ext := queryFileExtInfo(fullPath, info)
+			model.Attributes = ext.attributes
+			model.Hidden = ext.attributes&fileAttributeHidden != 0
+			model.System = ext.attributes&fileAttributeSystem != 0
+			model.ReadOnly = ext.attributes&fileAttributeReadOnly != 0
+			model.ReparsePoint = ext.attributes&fileAttributeReparsePoint != 0
+			model.LinkType = ext.linkType
+			model.LinkTarget = ext.linkTarget
+			// 目录符号链接与联接在 Go 中不报告为目录，按属性归为目录以便前端进入
+			if model.ReparsePoint && ext.attributes&fileAttributeDirectory != 0 {
+				model.IsDir = true
+				model.Size = 0
+			}
+			if !ext.createTime.IsZero() {
+				model.createTime = ext.createTime
+				model.CreateTime = ext.createTime.Format(time.RFC3339)
+			}
+			if !ext.accessTime.IsZero() {
+				model.AccessTime = ext.accessTime.Format(time.RFC3339)
+			}
+		}
+		if includeOwner {
+			model.Owner, _ = fileOwner(fullPath)
 		}
 
 		models = append(models, model)
 	}
-
-	sort.SliceStable(models, func(i, j int) bool {
-		if models[i].IsDir != models[j].IsDir {
-			return models[i].IsDir
-		}
-		return models[i].Name < models[j].Name
-	})
-
-	reply.Entries = models
-	return nil
+	return models, nil
 }
 
-func normalizeKernelPath(path string) string {
-	p := strings.TrimSpace(path)
-	if p == "" {
-		return p
-	}
-
-	if strings.HasPrefix(p, `\\??\\`) || strings.HasPrefix(p, `\\Device\\`) {
-		return p
+// fileEntryLess 返回按 sortBy 比较两个目录项的函数，名称比较不区分大小写。
+func fileEntryLess(sortBy string) (func(a, b FileEntryModel) bool, error) {
+	byName := func(a, b FileEntryModel) bool {
+		return strings.ToLower(a.Name) < strings.ToLower(b.Name)
 	}
-
-	if len(p) >= 2 && p[1] == ':' {
-		return `\\??\\` + p
+	switch strings.ToLower(strings.TrimSpace(sortBy)) {
+	case "", "name":
+		return byName, nil
+	case "size":
+		return func(a, b FileEntryModel) bool {
+			if a.Size != b.Size {
+				return a.Size < b.Size
+			}
+			return byName(a, b)
+		}, nil
+	case "mod_time":
+		return func(a, b FileEntryModel) bool {
+			if !a.modTime.Equal(b.modTime) {
+				return a.modTime.Before(b.modTime)
+			}
+			return byName(a, b)
+		}, nil
+	case "create_time":
+		return func(a, b FileEntryModel) bool {
+			if !a.createTime.Equal(b.createTime) {
+				return a.createTime.Before(b.createTime)
+			}
+			return byName(a, b)
+		}, nil
+	case "ext":
+		return func(a, b FileEntryModel) bool {
+			ea, eb := strings.ToLower(filepath.Ext(a.Name)), strings.ToLower(filepath.Ext(b.Name))
+			if ea != eb {
+				return ea < eb
+			}
+			return byName(a, b)
+		}, nil
+	default:
+		return nil, fmt.Errorf("sort_by 仅支持 name/size/mod_time/create_time/ext")
 	}
-
-	return p
 }
 
 func elevateLevelName(level uint32) (string, bool) {
@@ -499,15 +704,18 @@ func elevateLevelName(level uint32) (string, bool) {
 
 // DeleteFileKernelArgs 内核删除文件请求参数
 type DeleteFileKernelArgs struct {
-	Path string `json:"path"`
+	Path         string `json:"path"`
+	DryRun       bool   `json:"dry_run"`
+	ConfirmToken string `json:"confirm_token"`
 }
 
 // DeleteFileKernelReply 内核删除文件响应
 type DeleteFileKernelReply struct {
-	Success bool `json:"success"`
+	Success bool              `json:"success"`
+	Plan    *ConfirmPlanModel `json:"plan,omitempty"`
 }
 
-// DeleteFileKernel 使用 OpenSysKit 内核 IOCTL 删除文件
+// DeleteFileKernel 使用 OpenSysKit 内核 IOCTL 删除文件（需 dry_run + confirm_token 两阶段确认）
 func (t *ToolkitService) DeleteFileKernel(args *DeleteFileKernelArgs, reply *DeleteFileKernelReply) error {
 	if t.Driver == nil {
 		err := fmt.Errorf("驱动未加载")
@@ -521,24 +729,23 @@ func (t *ToolkitService) DeleteFileKernel(args *DeleteFileKernelArgs, reply *Del
 		return err
 	}
 
-	kernelPath := normalizeKernelPath(args.Path)
-	utf16Path, err := syscall.UTF16FromString(kernelPath)
-	if err != nil {
-		return fmt.Errorf("路径编码失败: %w", err)
+	// 计划目标按 Win32 形式展示，使 \Device\、\??\ 等路径也能取到大小与修改时间
+	planPath := args.Path
+	if p, err := win32Path(args.Path); err == nil {
+		planPath = p
 	}
-
-	var req driver.FilePathRequest
-	if len(utf16Path) > len(req.Path) {
-		return fmt.Errorf("路径过长，最大支持 %d UTF-16 字符", len(req.Path)-1)
+	plan, _, err := confirmGate("delete_file_kernel", args.Path, args.DryRun, args.ConfirmToken, []PlanTargetModel{filePlanTarget(planPath)})
+	if err != nil {
+		auditWrite("delete_file_kernel", map[string]any{"path": args.Path}, err)
+		return err
 	}
-	copy(req.Path[:], utf16Path)
-
-	inBuf := new(bytes.Buffer)
-	if err := binary.Write(inBuf, binary.LittleEndian, req); err != nil {
-		return fmt.Errorf("构造请求失败: %w", err)
+	if plan != nil {
+		reply.Plan = plan
+		auditWrite("delete_file_kernel", map[string]any{"path": args.Path, "dry_run": true}, nil)
+		return nil
 	}
 
-	if _, err := t.Driver.IoControl(driver.IOCTL_DELETE_FILE, inBuf.Bytes(), 0); err != nil {
+	if err := deleteFileViaDriver(t.Driver, args.Path); err != nil {
 		reply.Success = false
 		retErr := fmt.Errorf("内核删除文件失败: %w", err)
 		auditWrite("delete_file_kernel", map[string]any{"path": args.Path}, retErr)
@@ -552,7 +759,10 @@ func (t *ToolkitService) DeleteFileKernel(args *DeleteFileKernelArgs, reply *Del
 
 // KillFileLockingProcessesArgs 结束占用文件进程请求参数
 type KillFileLockingProcessesArgs struct {
-	Path string `json:"path"`
+	Path         string `json:"path"`
+	DryRun       bool   `json:"dry_run"`
+	ConfirmToken string `json:"confirm_token"`
+	Force        bool   `json:"force"`
 }
 
 // KillResult 单个 PID 的处理结果
@@ -566,11 +776,12 @@ type KillResult struct {
 
 // KillFileLockingProcessesReply 结束占用文件进程响应
 type KillFileLockingProcessesReply struct {
-	FoundPids []uint32     `json:"found_pids"`
-	Results   []KillResult `json:"results"`
+	FoundPids []uint32          `json:"found_pids"`
+	Results   []KillResult      `json:"results"`
+	Plan      *ConfirmPlanModel `json:"plan,omitempty"`
 }
 
-// KillFileLockingProcesses 先找占用文件 PID，再通过内核 IOCTL 结束进程
+// KillFileLockingProcesses 先找占用文件 PID，再通过内核 IOCTL 结束进程（需 dry_run + confirm_token 两阶段确认）
 func (t *ToolkitService) KillFileLockingProcesses(args *KillFileLockingProcessesArgs, reply *KillFileLockingProcessesReply) error {
 	if t.Driver == nil {
 		err := fmt.Errorf("驱动未加载")
@@ -583,20 +794,52 @@ func (t *ToolkitService) KillFileLockingProcesses(args *KillFileLockingProcesses
 		return err
 	}
 
-	pids, err := findLockingProcessIDs(args.Path)
+	lockPath := args.Path
+	if p, err := win32Path(args.Path); err == nil {
+		lockPath = p
+	}
+	pids, err := findLockingProcessIDs(lockPath)
 	if err != nil {
 		retErr := fmt.Errorf("查询占用进程失败: %w", err)
 		auditWrite("kill_file_lockers", map[string]any{"path": args.Path}, retErr)
 		return retErr
 	}
 
-	reply.FoundPids = pids
-	reply.Results = make([]KillResult, 0, len(pids))
-
+	names, _ := processNameMapViaDriver(t.Driver)
+	current := make([]PlanTargetModel, 0, len(pids))
 	for _, pid := range pids {
 		if pid == 0 {
 			continue
 		}
+		pt, err := t.processPlanTarget(pid, names[pid])
+		if err != nil {
+			auditWrite("kill_file_lockers", map[string]any{"path": args.Path}, err)
+			return err
+		}
+		current = append(current, pt)
+	}
+
+	plan, targets, err := confirmGate("kill_file_lockers", args.Path, args.DryRun, args.ConfirmToken, current)
+	if err != nil {
+		auditWrite("kill_file_lockers", map[string]any{"path": args.Path}, err)
+		return err
+	}
+
+	reply.FoundPids = pids
+	if plan != nil {
+		reply.Results = make([]KillResult, 0)
+		reply.Plan = plan
+		auditWrite("kill_file_lockers", map[string]any{"path": args.Path, "dry_run": true, "found_pids": len(pids)}, nil)
+		return nil
+	}
+	reply.Results = make([]KillResult, 0, len(targets))
+
+	for _, target := range targets {
+		pid := target.ProcessId
+		if err := t.guardTarget("kill_file_lockers", pid, target.ImageName, args.Force); err != nil {
+			reply.Results = append(reply.Results, KillResult{ProcessId: pid, Success: false, Error: err.Error()})
+			continue
+		}
 
 		result, err := executeKillProcess(t.Driver, pid)
 		if err != nil {
@@ -672,7 +915,8 @@ func (t *ToolkitService) EnumProcessModules(args *EnumProcessModulesArgs, reply
 
 // EnumNetworkConnectionsArgs 网络连接枚举请求参数
 type EnumNetworkConnectionsArgs struct {
-	Protocol string `json:"protocol"`
+	Protocol string     `json:"protocol"`
+	Query    *QuerySpec `json:"query,omitempty"`
 }
 
 // NetworkConnectionModel 网络连接信息
@@ -691,6 +935,7 @@ type NetworkConnectionModel struct {
 type EnumNetworkConnectionsReply struct {
 	Protocol    string                   `json:"protocol"`
 	Connections []NetworkConnectionModel `json:"connections"`
+	Page        *QueryPageModel          `json:"page"`
 }
 
 // EnumNetworkConnections 枚举 TCP/UDP 到 PID 的关联信息
@@ -715,9 +960,14 @@ func (t *ToolkitService) EnumNetworkConnections(args *EnumNetworkConnectionsArgs
 	if err != nil {
 		return fmt.Errorf("枚举网络连接失败: %w", err)
 	}
+	connections, page, err := applyQuery(connections, args.Query)
+	if err != nil {
+		return err
+	}
 
 	reply.Protocol = protocol
 	reply.Connections = connections
+	reply.Page = page
 	return nil
 }
 
@@ -0,0 +1,198 @@
+package service
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// targetPolicy 结束/冻结/隐藏类操作统一咨询的目标保护策略。
+// PID 0/4 与后端自身属于硬性限制，force 也无法绕过；
+// 前端进程、内置关键映像与用户列表可由 force 绕过，每次绕过都会写审计。
+type targetPolicy struct {
+	mu          sync.RWMutex
+	frontendPid uint32
+	images      map[string]struct{}
+	pids        map[uint32]struct{}
+}
+
+var globalTargetPolicy = &targetPolicy{
+	images: make(map[string]struct{}),
+	pids:   make(map[uint32]struct{}),
+}
+
+// SetFrontendProcessId 由主进程在拉起前端后登记其 PID，0 表示清除。
+func SetFrontendProcessId(pid uint32) {
+	globalTargetPolicy.mu.Lock()
+	globalTargetPolicy.frontendPid = pid
+	globalTargetPolicy.mu.Unlock()
+}
+
+// AddProtectedImages 追加用户保护映像名（不区分大小写），供主进程按配置初始化。
+func AddProtectedImages(names []string) {
+	globalTargetPolicy.mu.Lock()
+	defer globalTargetPolicy.mu.Unlock()
+	for _, n := range names {
+		if n = strings.ToLower(strings.TrimSpace(n)); n != "" {
+			globalTargetPolicy.images[n] = struct{}{}
+		}
+	}
+}
+
+// violation 返回目标被拒绝的原因；hard 为 true 表示不允许 force 绕过。
+func (p *targetPolicy) violation(pid uint32, imageName string) (reason string, hard bool) {
+	if pid == 0 || pid == 4 {
+		return "PID 0/4 为系统进程", true
+	}
+	if pid == uint32(os.Getpid()) {
+		return "后端自身进程", true
+	}
+
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	if p.frontendPid != 0 && pid == p.frontendPid {
+		return "前端进程", false
+	}
+	if isHighRiskProcessName(imageName) {
+		return fmt.Sprintf("关键系统进程 %s", imageName), false
+	}
+	if _, ok := p.pids[pid]; ok {
+		return "用户保护 PID", false
+	}
+	if _, ok := p.images[strings.ToLower(strings.TrimSpace(imageName))]; ok {
+		return fmt.Sprintf("用户保护映像 %s", imageName), false
+	}
+	return "", false
+}
+
+// guardTarget 在执行结束/冻结/隐藏前调用；imageName 为空时自动查询。
+func (t *ToolkitService) guardTarget(action string, pid uint32, imageName string, force bool) error {
+	if imageName == "" {
+		imageName = t.targetImageName(pid)
+	}
+	reason, hard := globalTargetPolicy.violation(pid, imageName)
+	if reason == "" {
+		return nil
+	}
+	if hard {
+		return fmt.Errorf("目标受保护策略限制（%s），不允许操作", reason)
+	}
+	if !force {
+		return fmt.Errorf("目标受保护策略限制（%s），如确需操作请传 force=true", reason)
+	}
+
+	auditWrite("target_policy_override", map[string]any{
+		"action":     action,
+		"process_id": pid,
+		"image_name": imageName,
+		"reason":     reason,
+	}, nil)
+	return nil
+}
+
+// guardTaskKillTargets taskkill /T 会连带结束子进程，能取得进程列表时对整棵子树逐一检查。
+func (t *ToolkitService) guardTaskKillTargets(pid uint32, tree bool, force bool) error {
+	if !tree || t.Driver == nil {
+		return t.guardTarget("taskkill_process", pid, "", force)
+	}
+	processes, err := t.getProcessList()
+	if err != nil {
+		return t.guardTarget("taskkill_process", pid, "", force)
+	}
+	names := make(map[uint32]string, len(processes))
+	for _, p := range processes {
+		names[p.ProcessId] = p.ImageName
+	}
+	for _, child := range collectSubtreeOrder(processes, pid, true, false) {
+		if err := t.guardTarget("taskkill_process", child, names[child], force); err != nil {
+			return fmt.Errorf("pid=%d: %w", child, err)
+		}
+	}
+	return nil
+}
+
+func (t *ToolkitService) targetImageName(pid uint32) string {
+	if name := processImageBaseName(pid); name != "" {
+		return name
+	}
+	if t.Driver != nil {
+		if names, err := processNameMapViaDriver(t.Driver); err == nil {
+			return names[pid]
+		}
+	}
+	return ""
+}
+
+// GetTargetPolicyArgs 查询目标保护策略请求参数
+type GetTargetPolicyArgs struct{}
+
+// GetTargetPolicyReply 查询目标保护策略响应
+type GetTargetPolicyReply struct {
+	BuiltinImages   []string `json:"builtin_images"`
+	SelfProcessId   uint32   `json:"self_process_id"`
+	FrontendPid     uint32   `json:"frontend_process_id"`
+	ProtectedImages []string `json:"protected_images"`
+	ProtectedPids   []uint32 `json:"protected_pids"`
+}
+
+// GetTargetPolicy 返回内置与用户配置的受保护目标
+func (t *ToolkitService) GetTargetPolicy(_ *GetTargetPolicyArgs, reply *GetTargetPolicyReply) error {
+	globalTargetPolicy.mu.RLock()
+	defer globalTargetPolicy.mu.RUnlock()
+
+	reply.BuiltinImages = append([]string(nil), highRiskProcessNames...)
+	reply.SelfProcessId = uint32(os.Getpid())
+	reply.FrontendPid = globalTargetPolicy.frontendPid
+	reply.ProtectedImages = make([]string, 0, len(globalTargetPolicy.images))
+	for n := range globalTargetPolicy.images {
+		reply.ProtectedImages = append(reply.ProtectedImages, n)
+	}
+	sort.Strings(reply.ProtectedImages)
+	reply.ProtectedPids = make([]uint32, 0, len(globalTargetPolicy.pids))
+	for pid := range globalTargetPolicy.pids {
+		reply.ProtectedPids = append(reply.ProtectedPids, pid)
+	}
+	sort.Slice(reply.ProtectedPids, func(i, j int) bool { return reply.ProtectedPids[i] < reply.ProtectedPids[j] })
+	return nil
+}
+
+// SetTargetPolicyArgs 设置用户保护列表请求参数（整体替换）
+type SetTargetPolicyArgs struct {
+	ProtectedImages []string `json:"protected_images"`
+	ProtectedPids   []uint32 `json:"protected_pids"`
+}
+
+// SetTargetPolicyReply 设置用户保护列表响应
+type SetTargetPolicyReply struct {
+	Success bool `json:"success"`
+}
+
+// SetTargetPolicy 替换用户配置的受保护映像名与 PID 列表
+func (t *ToolkitService) SetTargetPolicy(args *SetTargetPolicyArgs, reply *SetTargetPolicyReply) error {
+	images := make(map[string]struct{}, len(args.ProtectedImages))
+	for _, n := range args.ProtectedImages {
+		if n = strings.ToLower(strings.TrimSpace(n)); n != "" {
+			images[n] = struct{}{}
+		}
+	}
+	pids := make(map[uint32]struct{}, len(args.ProtectedPids))
+	for _, pid := range args.ProtectedPids {
+		if pid != 0 {
+			pids[pid] = struct{}{}
+		}
+	}
+
+	globalTargetPolicy.mu.Lock()
+	globalTargetPolicy.images = images
+	globalTargetPolicy.pids = pids
+	globalTargetPolicy.mu.Unlock()
+
+	reply.Success = true
+	auditWrite("set_target_policy", map[string]any{
+		"protected_images": args.ProtectedImages,
+		"protected_pids":   args.ProtectedPids,
+	}, nil)
+	return nil
+}
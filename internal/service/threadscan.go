@@ -0,0 +1,247 @@
+package service
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+const (
+	// kernelAddressBase 以上的起始地址视为内核态线程（System 等），与内核模块比对。
+	kernelAddressBase uint64 = 0xFFFF800000000000
+)
+
+// FindSuspiciousThreadsArgs 可疑线程扫描请求参数
+// ProcessId 为 0 时扫描全部进程；LingerMs 为终止中线程的复查间隔。
+type FindSuspiciousThreadsArgs struct {
+	ProcessId uint32 `json:"process_id"`
+	LingerMs  int    `json:"linger_ms"`
+}
+
+// SuspiciousThreadModel 可疑线程
+type SuspiciousThreadModel struct {
+	ThreadId      uint32   `json:"thread_id"`
+	ProcessId     uint32   `json:"process_id"`
+	ProcessName   string   `json:"process_name"`
+	StartAddress  uint64   `json:"start_address"`
+	IsTerminating bool     `json:"is_terminating"`
+	Risk          string   `json:"risk"`
+	Reasons       []string `json:"reasons"`
+}
+
+// FindSuspiciousThreadsReply 可疑线程扫描响应
+type FindSuspiciousThreadsReply struct {
+	ProcessId        uint32                  `json:"process_id"`
+	ScannedProcesses int                     `json:"scanned_processes"`
+	ScannedThreads   int                     `json:"scanned_threads"`
+	SkippedProcesses []uint32                `json:"skipped_processes"`
+	Threads          []SuspiciousThreadModel `json:"threads"`
+}
+
+// FindSuspiciousThreads 比对线程起始地址与模块映像范围，找出起始于私有内存或滞留终止状态的线程
+func (t *ToolkitService) FindSuspiciousThreads(args *FindSuspiciousThreadsArgs, reply *FindSuspiciousThreadsReply) error {
+	if t.Driver == nil {
+		err := fmt.Errorf("驱动未加载")
+		auditWrite("find_suspicious_threads", map[string]any{"process_id": args.ProcessId}, err)
+		return err
+	}
+
+	lingerMs := args.LingerMs
+	if lingerMs <= 0 {
+		lingerMs = 1000
+	}
+	if lingerMs > 10000 {
+		lingerMs = 10000
+	}
+
+	processes, err := t.getProcessList()
+	if err != nil {
+		auditWrite("find_suspicious_threads", map[string]any{"process_id": args.ProcessId}, err)
+		return err
+	}
+	names := make(map[uint32]string, len(processes))
+	for _, p := range processes {
+		names[p.ProcessId] = p.ImageName
+	}
+
+	targets := make([]uint32, 0, len(processes))
+	if args.ProcessId != 0 {
+		targets = append(targets, args.ProcessId)
+	} else {
+		for _, p := range processes {
+			if p.ProcessId != 0 {
+				targets = append(targets, p.ProcessId)
+			}
+		}
+	}
+	sort.Slice(targets, func(i, j int) bool { return targets[i] < targets[j] })
+
+	var kernelRanges moduleRangeSet
+	if kernelModules, kmErr := enumKernelModulesViaDriver(t.Driver); kmErr == nil {
+		for _, m := range kernelModules {
+			kernelRanges.add(m.BaseAddress, uint64(m.Size))
+		}
+		kernelRanges.finish()
+	}
+
+	reply.ProcessId = args.ProcessId
+	reply.SkippedProcesses = make([]uint32, 0)
+	reply.Threads = make([]SuspiciousThreadModel, 0, 16)
+
+	terminating := make(map[uint32][]ThreadInfoModel)
+	for _, pid := range targets {
+		threads, thErr := enumThreadsViaDriver(t.Driver, pid)
+		if thErr != nil {
+			reply.SkippedProcesses = append(reply.SkippedProcesses, pid)
+			continue
+		}
+
+		var userRanges moduleRangeSet
+		if pid != 4 {
+			modules, modErr := enumProcessModulesViaDriver(t.Driver, pid)
+			if modErr != nil || len(modules) == 0 {
+				// 无法取得模块列表时不做范围判断，避免整进程误报
+				reply.SkippedProcesses = append(reply.SkippedProcesses, pid)
+				continue
+			}
+			for _, m := range modules {
+				userRanges.add(m.BaseAddress, uint64(m.Size))
+			}
+			userRanges.finish()
+		}
+
+		reply.ScannedProcesses++
+		reply.ScannedThreads += len(threads)
+		for _, th := range threads {
+			if th.IsTerminating {
+				terminating[pid] = append(terminating[pid], th)
+			}
+			if th.StartAddress == 0 {
+				continue
+			}
+
+			ranges := &userRanges
+			if th.StartAddress >= kernelAddressBase {
+				if len(kernelRanges.ranges) == 0 {
+					continue
+				}
+				ranges = &kernelRanges
+			} else if pid == 4 {
+				continue
+			}
+			if ranges.contains(th.StartAddress) {
+				continue
+			}
+
+			reply.Threads = append(reply.Threads, SuspiciousThreadModel{
+				ThreadId:      th.ThreadId,
+				ProcessId:     pid,
+				ProcessName:   names[pid],
+				StartAddress:  th.StartAddress,
+				IsTerminating: th.IsTerminating,
+				Reasons:       []string{"start_outside_module"},
+			})
+		}
+	}
+
+	if len(terminating) > 0 {
+		time.Sleep(time.Duration(lingerMs) * time.Millisecond)
+		for pid, before := range terminating {
+			after, thErr := enumThreadsViaDriver(t.Driver, pid)
+			if thErr != nil {
+				continue
+			}
+			still := make(map[uint32]ThreadInfoModel, len(after))
+			for _, th := range after {
+				if th.IsTerminating {
+					still[th.ThreadId] = th
+				}
+			}
+			for _, th := range before {
+				if _, ok := still[th.ThreadId]; !ok {
+					continue
+				}
+				if idx := findSuspiciousThread(reply.Threads, th.ThreadId); idx >= 0 {
+					reply.Threads[idx].Reasons = append(reply.Threads[idx].Reasons, "terminating_lingering")
+					continue
+				}
+				reply.Threads = append(reply.Threads, SuspiciousThreadModel{
+					ThreadId:      th.ThreadId,
+					ProcessId:     pid,
+					ProcessName:   names[pid],
+					StartAddress:  th.StartAddress,
+					IsTerminating: true,
+					Reasons:       []string{"terminating_lingering"},
+				})
+			}
+		}
+	}
+
+	for i := range reply.Threads {
+		reply.Threads[i].Risk = suspiciousThreadRisk(reply.Threads[i].Reasons)
+	}
+	sort.SliceStable(reply.Threads, func(i, j int) bool {
+		if len(reply.Threads[i].Reasons) != len(reply.Threads[j].Reasons) {
+			return len(reply.Threads[i].Reasons) > len(reply.Threads[j].Reasons)
+		}
+		if reply.Threads[i].ProcessId != reply.Threads[j].ProcessId {
+			return reply.Threads[i].ProcessId < reply.Threads[j].ProcessId
+		}
+		return reply.Threads[i].ThreadId < reply.Threads[j].ThreadId
+	})
+
+	auditWrite("find_suspicious_threads", map[string]any{
+		"process_id": args.ProcessId,
+		"scanned":    reply.ScannedProcesses,
+		"found":      len(reply.Threads),
+	}, nil)
+	return nil
+}
+
+func findSuspiciousThread(threads []SuspiciousThreadModel, threadID uint32) int {
+	for i := range threads {
+		if threads[i].ThreadId == threadID {
+			return i
+		}
+	}
+	return -1
+}
+
+func suspiciousThreadRisk(reasons []string) string {
+	for _, r := range reasons {
+		if r == "start_outside_module" {
+			return "high"
+		}
+	}
+	return "medium"
+}
+
+type addressRange struct {
+	start uint64
+	end   uint64
+}
+
+// moduleRangeSet 按基址排序的模块映像范围集合，用于地址归属判断。
+type moduleRangeSet struct {
+	ranges []addressRange
+}
+
+func (s *moduleRangeSet) add(base uint64, size uint64) {
+	if size == 0 {
+		return
+	}
+	s.ranges = append(s.ranges, addressRange{start: base, end: base + size})
+}
+
+func (s *moduleRangeSet) finish() {
+	sort.Slice(s.ranges, func(i, j int) bool { return s.ranges[i].start < s.ranges[j].start })
+}
+
+func (s *moduleRangeSet) contains(addr uint64) bool {
+	idx := sort.Search(len(s.ranges), func(i int) bool { return s.ranges[i].start > addr })
+	if idx == 0 {
+		return false
+	}
+	r := s.ranges[idx-1]
+	return addr >= r.start && addr < r.end
+}
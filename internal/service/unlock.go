@@ -0,0 +1,244 @@
+package service
+
+import (
+	"fmt"
+	"strings"
+)
+
+// UnlockFileArgs 解除文件占用请求参数。
+// Recursive 为 true 时同时关闭指向 path 之下任意文件的句柄（用于解锁目录）；
+// KillOnFailure 为 true 时，对句柄未能全部关闭的进程回退为结束进程。
+type UnlockFileArgs struct {
+	Path          string `json:"path"`
+	Recursive     bool   `json:"recursive"`
+	KillOnFailure bool   `json:"kill_on_failure"`
+	DryRun        bool   `json:"dry_run"`
+	ConfirmToken  string `json:"confirm_token"`
+	Force         bool   `json:"force"`
+}
+
+// UnlockHandleResult 单个句柄的关闭结果
+type UnlockHandleResult struct {
+	ProcessId   uint32 `json:"process_id"`
+	ProcessName string `json:"process_name"`
+	Handle      uint64 `json:"handle"`
+	ObjectName  string `json:"object_name"`
+	DosPath     string `json:"dos_path,omitempty"`
+	Success     bool   `json:"success"`
+	Error       string `json:"error,omitempty"`
+}
+
+// UnlockFileReply 解除文件占用响应
+type UnlockFileReply struct {
+	Path    string               `json:"path"`
+	Found   int                  `json:"found"`
+	Closed  int                  `json:"closed"`
+	Failed  int                  `json:"failed"`
+	Results []UnlockHandleResult `json:"results"`
+	Kills   []KillResult         `json:"kills"`
+	Plan    *ConfirmPlanModel    `json:"plan,omitempty"`
+}
+
+// matchesUnlockPath 句柄盘符路径等于目标路径，或 recursive 时位于目标目录之下。
+func matchesUnlockPath(dosPath string, target string, recursive bool) bool {
+	p := strings.ToLower(strings.TrimRight(dosPath, `\`))
+	t := strings.ToLower(target)
+	if p == t {
+		return true
+	}
+	return recursive && strings.HasPrefix(p, t+`\`)
+}
+
+// fileHandleIndex 按需枚举一次全系统文件句柄，按盘符路径索引 root 之下的句柄，供逐项关闭占用时复用。
+type fileHandleIndex struct {
+	action string
+	root   string
+	byPath map[string][]HandleEntryModel
+	names  map[uint32]string
+	err    error
+	loaded bool
+}
+
+func newFileHandleIndex(action string, root string) *fileHandleIndex {
+	return &fileHandleIndex{action: action, root: handleMatchPath(root)}
+}
+
+func (idx *fileHandleIndex) load(t *ToolkitService) error {
+	if idx.loaded {
+		return idx.err
+	}
+	idx.loaded = true
+	// 句柄表不完整时不建索引：只关掉能看到的句柄会让调用方误以为占用已解除
+	handles, err := listHandlesViaDriver(t.Driver, 0)
+	if err != nil {
+		idx.err = fmt.Errorf("枚举句柄明细失败: %w", err)
+		return idx.err
+	}
+	idx.names, _ = processNameMapViaDriver(t.Driver)
+	idx.byPath = make(map[string][]HandleEntryModel)
+	for _, h := range handles {
+		if !strings.EqualFold(h.TypeName, "File") {
+			continue
+		}
+		if h.DosPath == "" || !matchesUnlockPath(h.DosPath, idx.root, true) {
+			continue
+		}
+		key := strings.ToLower(strings.TrimRight(h.DosPath, `\`))
+		idx.byPath[key] = append(idx.byPath[key], h)
+	}
+	return nil
+}
+
+// closeFor 关闭指向 path 的文件句柄（逐个经过目标策略检查），返回成功关闭的数量与首个错误。
+func (idx *fileHandleIndex) closeFor(t *ToolkitService, path string, force bool) (int, error) {
+	if err := idx.load(t); err != nil {
+		return 0, err
+	}
+	key := strings.ToLower(handleMatchPath(path))
+	closed := 0
+	var firstErr error
+	for _, h := range idx.byPath[key] {
+		err := t.guardTarget(idx.action, h.ProcessId, idx.names[h.ProcessId], force)
+		if err == nil {
+			err = closeHandleViaDriver(t.Driver, h.ProcessId, h.Handle)
+		}
+		if err != nil {
+			if firstErr == nil {
+				firstErr = fmt.Errorf("关闭句柄失败 (pid=%d handle=0x%X): %w", h.ProcessId, h.Handle, err)
+			}
+			continue
+		}
+		closed++
+	}
+	delete(idx.byPath, key)
+	return closed, firstErr
+}
+
+// UnlockFile 枚举全系统文件句柄，关闭所有指向 path 的句柄以释放文件，而不结束持有进程
+func (t *ToolkitService) UnlockFile(args *UnlockFileArgs, reply *UnlockFileReply) error {
+	params := map[string]any{"path": args.Path, "recursive": args.Recursive, "kill_on_failure": args.KillOnFailure}
+	if t.Driver == nil {
+		err := fmt.Errorf("驱动未加载")
+		auditWrite("unlock_file", params, err)
+		return err
+	}
+	if strings.TrimSpace(args.Path) == "" {
+		err := fmt.Errorf("path 不能为空")
+		auditWrite("unlock_file", params, err)
+		return err
+	}
+	target, err := win32Path(args.Path)
+	if err != nil {
+		retErr := fmt.Errorf("路径解析失败: %w", err)
+		auditWrite("unlock_file", params, retErr)
+		return retErr
+	}
+	target = handleMatchPath(target)
+
+	// 句柄表不完整（errDriverListTruncated）同样整体报错，不做部分解锁
+	handles, err := listHandlesViaDriver(t.Driver, 0)
+	if err != nil {
+		retErr := fmt.Errorf("枚举句柄明细失败: %w", err)
+		auditWrite("unlock_file", params, retErr)
+		return retErr
+	}
+	names, _ := processNameMapViaDriver(t.Driver)
+
+	matched := make(map[string]HandleEntryModel)
+	current := make([]PlanTargetModel, 0, 8)
+	owners := make(map[uint32]PlanTargetModel)
+	for _, h := range handles {
+		if !strings.EqualFold(h.TypeName, "File") {
+			continue
+		}
+		if h.DosPath == "" || !matchesUnlockPath(h.DosPath, target, args.Recursive) {
+			continue
+		}
+		owner, ok := owners[h.ProcessId]
+		if !ok {
+			if owner, err = t.processPlanTarget(h.ProcessId, names[h.ProcessId]); err != nil {
+				auditWrite("unlock_file", params, err)
+				return err
+			}
+			owners[h.ProcessId] = owner
+		}
+		pt := handleEntryPlanTarget(owner, h)
+		matched[pt.key()] = h
+		current = append(current, pt)
+	}
+
+	scope := fmt.Sprintf("%s|%t|%t", strings.ToLower(target), args.Recursive, args.KillOnFailure)
+	plan, targets, err := confirmGate("unlock_file", scope, args.DryRun, args.ConfirmToken, current)
+	if err != nil {
+		auditWrite("unlock_file", params, err)
+		return err
+	}
+
+	reply.Path = target
+	reply.Found = len(current)
+	reply.Kills = make([]KillResult, 0)
+	if plan != nil {
+		reply.Results = make([]UnlockHandleResult, 0)
+		reply.Plan = plan
+		params["dry_run"] = true
+		params["found"] = reply.Found
+		auditWrite("unlock_file", params, nil)
+		return nil
+	}
+
+	reply.Results = make([]UnlockHandleResult, 0, len(targets))
+	failedPids := make([]uint32, 0)
+	failedSeen := make(map[uint32]struct{})
+	for _, pt := range targets {
+		h := matched[pt.key()]
+		res := UnlockHandleResult{
+			ProcessId:   h.ProcessId,
+			ProcessName: pt.ImageName,
+			Handle:      h.Handle,
+			ObjectName:  h.ObjectName,
+			DosPath:     h.DosPath,
+		}
+
+		err := t.guardTarget("unlock_file", h.ProcessId, pt.ImageName, args.Force)
+		if err == nil {
+			err = closeHandleViaDriver(t.Driver, h.ProcessId, h.Handle)
+			if err != nil {
+				err = fmt.Errorf("关闭句柄失败: %w", err)
+			}
+		}
+		if err != nil {
+			res.Error = err.Error()
+			reply.Failed++
+			if _, ok := failedSeen[h.ProcessId]; !ok {
+				failedSeen[h.ProcessId] = struct{}{}
+				failedPids = append(failedPids, h.ProcessId)
+			}
+		} else {
+			res.Success = true
+			reply.Closed++
+		}
+		reply.Results = append(reply.Results, res)
+	}
+
+	if args.KillOnFailure {
+		for _, pid := range failedPids {
+			if err := t.guardTarget("unlock_file", pid, names[pid], args.Force); err != nil {
+				reply.Kills = append(reply.Kills, KillResult{ProcessId: pid, Error: err.Error()})
+				continue
+			}
+			result, err := executeKillProcess(t.Driver, pid)
+			kill := KillResult{ProcessId: pid, UsedMethod: result.UsedMethod, NTStatus: result.NTStatus, Success: err == nil}
+			if err != nil {
+				kill.Error = err.Error()
+			}
+			reply.Kills = append(reply.Kills, kill)
+		}
+	}
+
+	params["found"] = reply.Found
+	params["closed"] = reply.Closed
+	params["failed"] = reply.Failed
+	params["killed"] = len(reply.Kills)
+	auditWrite("unlock_file", params, nil)
+	return nil
+}
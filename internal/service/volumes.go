@@ -0,0 +1,46 @@
+package service
+
+import (
+	"fmt"
+	"time"
+)
+
+// fileExtInfo 目录项在 os.FileInfo 之外的 Windows 属性。
+type fileExtInfo struct {
+	attributes uint32
+	createTime time.Time
+	accessTime time.Time
+	linkType   string
+	linkTarget string
+}
+
+// VolumeModel 一个盘符根目录。
+type VolumeModel struct {
+	Root       string `json:"root"`
+	Device     string `json:"device,omitempty"`
+	Label      string `json:"label"`
+	FileSystem string `json:"file_system"`
+	DriveType  string `json:"drive_type"`
+	TotalBytes uint64 `json:"total_bytes"`
+	FreeBytes  uint64 `json:"free_bytes"`
+	Ready      bool   `json:"ready"`
+	IsSystem   bool   `json:"is_system"`
+}
+
+// ListVolumesArgs 盘符列表请求参数
+type ListVolumesArgs struct{}
+
+// ListVolumesReply 盘符列表响应
+type ListVolumesReply struct {
+	Volumes []VolumeModel `json:"volumes"`
+}
+
+// ListVolumes 列出所有盘符根目录及其卷标、文件系统与容量
+func (t *ToolkitService) ListVolumes(_ *ListVolumesArgs, reply *ListVolumesReply) error {
+	volumes, err := listVolumes()
+	if err != nil {
+		return fmt.Errorf("枚举盘符失败: %w", err)
+	}
+	reply.Volumes = volumes
+	return nil
+}
@@ -0,0 +1,9 @@
+//go:build !windows
+
+package service
+
+import "fmt"
+
+func listVolumes() ([]VolumeModel, error) {
+	return nil, fmt.Errorf("仅支持 Windows")
+}
@@ -0,0 +1,64 @@
+//go:build windows
+
+package service
+
+import (
+	"os"
+	"strings"
+
+	"golang.org/x/sys/windows"
+)
+
+var driveTypeNames = map[uint32]string{
+	windows.DRIVE_REMOVABLE: "removable",
+	windows.DRIVE_FIXED:     "fixed",
+	windows.DRIVE_REMOTE:    "network",
+	windows.DRIVE_CDROM:     "cdrom",
+	windows.DRIVE_RAMDISK:   "ramdisk",
+}
+
+// listVolumes 按盘符顺序枚举根目录；未就绪的卷（如空光驱）只返回盘符与类型。
+func listVolumes() ([]VolumeModel, error) {
+	mask, err := windows.GetLogicalDrives()
+	if err != nil {
+		return nil, err
+	}
+	systemDrive := strings.ToUpper(os.Getenv("SystemDrive"))
+
+	volumes := make([]VolumeModel, 0, 8)
+	for i := 0; i < 26; i++ {
+		if mask&(1<<uint(i)) == 0 {
+			continue
+		}
+		drive := string(rune('A'+i)) + ":"
+		root := drive + `\`
+		rootPtr, _ := windows.UTF16PtrFromString(root)
+
+		vol := VolumeModel{Root: root, IsSystem: drive == systemDrive, DriveType: "unknown"}
+		if name, ok := driveTypeNames[windows.GetDriveType(rootPtr)]; ok {
+			vol.DriveType = name
+		}
+		drivePtr, _ := windows.UTF16PtrFromString(drive)
+		device := make([]uint16, windows.MAX_PATH)
+		if n, err := windows.QueryDosDevice(drivePtr, &device[0], uint32(len(device))); err == nil && n > 0 {
+			vol.Device = windows.UTF16ToString(device[:n])
+		}
+
+		label := make([]uint16, windows.MAX_PATH+1)
+		fsName := make([]uint16, windows.MAX_PATH+1)
+		if err := windows.GetVolumeInformation(rootPtr, &label[0], uint32(len(label)), nil, nil, nil, &fsName[0], uint32(len(fsName))); err == nil {
+			vol.Ready = true
+			vol.Label = windows.UTF16ToString(label)
+			vol.FileSystem = windows.UTF16ToString(fsName)
+		}
+		if vol.Ready {
+			var free, total, totalFree uint64
+			if err := windows.GetDiskFreeSpaceEx(rootPtr, &free, &total, &totalFree); err == nil {
+				vol.TotalBytes = total
+				vol.FreeBytes = free
+			}
+		}
+		volumes = append(volumes, vol)
+	}
+	return volumes, nil
+}
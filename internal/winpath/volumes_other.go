@@ -0,0 +1,15 @@
+//go:build !windows
+
+package winpath
+
+// SystemVolumes 非 Windows 平台没有盘符映射。
+func SystemVolumes() []Volume {
+	return nil
+}
+
+var defaultTranslator = New(SystemVolumes, "")
+
+// Default 返回不做盘符映射的转换器。
+func Default() *Translator {
+	return defaultTranslator
+}
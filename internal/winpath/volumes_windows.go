@@ -0,0 +1,56 @@
+//go:build windows
+
+package winpath
+
+import (
+	"os"
+	"sync"
+	"time"
+
+	"golang.org/x/sys/windows"
+)
+
+const volumeCacheTTL = 30 * time.Second
+
+var volumeCache struct {
+	mu        sync.Mutex
+	volumes   []Volume
+	refreshed time.Time
+}
+
+// SystemVolumes 通过 QueryDosDevice 取得 A: 到 Z: 的设备映射，短时间缓存以免每个句柄都查询。
+func SystemVolumes() []Volume {
+	volumeCache.mu.Lock()
+	defer volumeCache.mu.Unlock()
+	if volumeCache.volumes != nil && time.Since(volumeCache.refreshed) < volumeCacheTTL {
+		return volumeCache.volumes
+	}
+
+	volumes := make([]Volume, 0, 8)
+	if mask, err := windows.GetLogicalDrives(); err == nil {
+		buf := make([]uint16, windows.MAX_PATH)
+		for i := 0; i < 26; i++ {
+			if mask&(1<<uint(i)) == 0 {
+				continue
+			}
+			drive := string(rune('A'+i)) + ":"
+			name, _ := windows.UTF16PtrFromString(drive)
+			n, err := windows.QueryDosDevice(name, &buf[0], uint32(len(buf)))
+			if err != nil || n == 0 {
+				continue
+			}
+			// 结果为 REG_MULTI_SZ 风格，第一项即当前映射
+			volumes = append(volumes, Volume{Drive: drive, Target: windows.UTF16ToString(buf[:n])})
+		}
+	}
+	volumeCache.volumes = volumes
+	volumeCache.refreshed = time.Now()
+	return volumes
+}
+
+var defaultTranslator = New(SystemVolumes, os.Getenv("SystemRoot"))
+
+// Default 返回使用本机盘符映射与 %SystemRoot% 的转换器。
+func Default() *Translator {
+	return defaultTranslator
+}
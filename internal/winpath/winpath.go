@@ -0,0 +1,375 @@
+// Package winpath 在 Win32（C:\x、\\server\share\x）、长路径（\\?\C:\x、\\?\UNC\...）、
+// NT 命名空间（\??\C:\x、\??\UNC\...）与设备路径（\Device\HarddiskVolume3\x、\Device\Mup\...）之间转换。
+// 盘符与设备的对应关系来自可替换的 VolumeSource，转换本身是纯字符串处理，不访问文件系统。
+package winpath
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var (
+	// ErrEmpty 路径为空。
+	ErrEmpty = errors.New("路径为空")
+	// ErrRelative 相对路径（含 "C:x" 这类盘符相对路径与 "\x" 这类根相对路径），需先转为绝对路径。
+	ErrRelative = errors.New("不是绝对路径")
+	// ErrUnmapped 设备或卷在映射表中没有对应的盘符。
+	ErrUnmapped = errors.New("没有对应的盘符")
+	// ErrUnsupported 无法识别的路径形式。
+	ErrUnsupported = errors.New("无法识别的路径形式")
+)
+
+// maxSubstDepth subst 盘展开的最大层数，防止映射表成环。
+const maxSubstDepth = 8
+
+// Form 路径形式。
+type Form int
+
+const (
+	FormUnknown    Form = iota
+	FormRelative        // x\y、\x、C:x
+	FormDrive           // C:\x
+	FormUNC             // \\server\share\x
+	FormLongDrive       // \\?\C:\x、\\.\C:\x
+	FormLongUNC         // \\?\UNC\server\share\x
+	FormNTDrive         // \??\C:\x、\GLOBAL??\C:\x、\DosDevices\C:\x
+	FormNTUNC           // \??\UNC\server\share\x
+	FormDevice          // \Device\HarddiskVolume3\x、\Device\Mup\server\share\x
+	FormSystemRoot      // \SystemRoot\x
+)
+
+var formNames = map[Form]string{
+	FormUnknown:    "unknown",
+	FormRelative:   "relative",
+	FormDrive:      "drive",
+	FormUNC:        "unc",
+	FormLongDrive:  "long_drive",
+	FormLongUNC:    "long_unc",
+	FormNTDrive:    "nt_drive",
+	FormNTUNC:      "nt_unc",
+	FormDevice:     "device",
+	FormSystemRoot: "system_root",
+}
+
+func (f Form) String() string {
+	if name, ok := formNames[f]; ok {
+		return name
+	}
+	return "unknown"
+}
+
+// Volume 一个盘符的 DOS 设备映射，即 QueryDosDevice 的结果。
+// 普通卷为 \Device\HarddiskVolume3，subst 盘为 \??\C:\dir，网络映射盘为 \Device\LanmanRedirector\;Z:0...\server\share。
+type Volume struct {
+	Drive  string
+	Target string
+}
+
+// VolumeSource 返回当前的盘符映射；每次转换都会调用，实现方自行缓存。
+type VolumeSource func() []Volume
+
+// Static 返回固定映射表，用于测试或离线转换。
+func Static(volumes ...Volume) VolumeSource {
+	vs := append([]Volume(nil), volumes...)
+	return func() []Volume { return vs }
+}
+
+// Translator 按给定的盘符映射与 SystemRoot 转换路径。
+type Translator struct {
+	volumes    VolumeSource
+	systemRoot string
+}
+
+// New 创建转换器；volumes 为 nil 时不做任何盘符/设备映射，systemRoot 为空时不支持 \SystemRoot\。
+func New(volumes VolumeSource, systemRoot string) *Translator {
+	if volumes == nil {
+		volumes = Static()
+	}
+	return &Translator{volumes: volumes, systemRoot: strings.TrimRight(systemRoot, `\`)}
+}
+
+// hasPrefixFold 不区分大小写的前缀判断。
+func hasPrefixFold(s, prefix string) bool {
+	return len(s) >= len(prefix) && strings.EqualFold(s[:len(prefix)], prefix)
+}
+
+// isDriveRoot 判断 s 是否以 "X:" 开头且其后为路径结束或反斜杠。
+func isDriveRoot(s string) bool {
+	if len(s) < 2 || s[1] != ':' {
+		return false
+	}
+	c := s[0] | 0x20
+	if c < 'a' || c > 'z' {
+		return false
+	}
+	return len(s) == 2 || s[2] == '\\'
+}
+
+// ntDosPrefixes \??\ 的等价写法，按长度从长到短匹配。
+var ntDosPrefixes = []string{`\DosDevices\`, `\GLOBAL??\`, `\??\`}
+
+// Classify 判断路径形式，不做映射。
+func Classify(p string) Form {
+	p = strings.ReplaceAll(strings.TrimSpace(p), "/", `\`)
+	switch {
+	case p == "":
+		return FormUnknown
+	case hasPrefixFold(p, `\\?\UNC\`), hasPrefixFold(p, `\\.\UNC\`):
+		return FormLongUNC
+	case hasPrefixFold(p, `\\?\`), hasPrefixFold(p, `\\.\`):
+		return FormLongDrive
+	case strings.HasPrefix(p, `\\`):
+		return FormUNC
+	case hasPrefixFold(p, `\Device\`):
+		return FormDevice
+	case hasPrefixFold(p, `\SystemRoot\`), strings.EqualFold(p, `\SystemRoot`):
+		return FormSystemRoot
+	case isDriveRoot(p):
+		return FormDrive
+	}
+	for _, prefix := range ntDosPrefixes {
+		if hasPrefixFold(p, prefix) {
+			if hasPrefixFold(p[len(prefix):], `UNC\`) {
+				return FormNTUNC
+			}
+			return FormNTDrive
+		}
+	}
+	return FormRelative
+}
+
+// IsNT 判断路径是否属于内核对象命名空间（\??\、\Device\、\SystemRoot\ 等）。
+func IsNT(p string) bool {
+	switch Classify(p) {
+	case FormNTDrive, FormNTUNC, FormDevice, FormSystemRoot:
+		return true
+	}
+	return false
+}
+
+// ToWin32 把任意形式转为 Win32 路径：盘符路径 "C:\x" 或 UNC "\\server\share\x"。
+// subst 盘展开为实际路径；网络映射盘保留盘符；结果会折叠多余的反斜杠并处理 "." 与 ".."。
+func (t *Translator) ToWin32(p string) (string, error) {
+	return t.toWin32(p, 0)
+}
+
+func (t *Translator) toWin32(p string, depth int) (string, error) {
+	p = strings.ReplaceAll(strings.TrimSpace(p), "/", `\`)
+	if p == "" {
+		return "", ErrEmpty
+	}
+
+	switch Classify(p) {
+	case FormLongUNC, FormNTUNC:
+		return cleanUNC(p[strings.Index(strings.ToUpper(p), `UNC\`)+4:])
+	case FormLongDrive:
+		return t.driveOnly(p[4:], p, depth)
+	case FormNTDrive:
+		for _, prefix := range ntDosPrefixes {
+			if hasPrefixFold(p, prefix) {
+				return t.driveOnly(p[len(prefix):], p, depth)
+			}
+		}
+	case FormUNC:
+		return cleanUNC(p[2:])
+	case FormSystemRoot:
+		if t.systemRoot == "" {
+			return "", fmt.Errorf("%w: %s（未配置 SystemRoot）", ErrUnmapped, p)
+		}
+		return t.toWin32(t.systemRoot+p[len(`\SystemRoot`):], depth)
+	case FormDevice:
+		return t.deviceToWin32(p)
+	case FormDrive:
+		return t.expandSubst(p, depth)
+	}
+	return "", fmt.Errorf("%w: %s", ErrRelative, p)
+}
+
+// driveOnly 处理 \\?\ 与 \??\ 之后的部分：只接受盘符路径，卷 GUID 等其他目标视为无法映射。
+func (t *Translator) driveOnly(rest string, original string, depth int) (string, error) {
+	if !isDriveRoot(rest) {
+		return "", fmt.Errorf("%w: %s", ErrUnmapped, original)
+	}
+	return t.expandSubst(rest, depth)
+}
+
+// expandSubst 若盘符是 subst 盘（映射目标为 \??\X:\dir），替换为实际路径后继续展开。
+func (t *Translator) expandSubst(p string, depth int) (string, error) {
+	drive := strings.ToUpper(p[:2])
+	if depth < maxSubstDepth {
+		for _, v := range t.volumes() {
+			if !strings.EqualFold(v.Drive, drive) {
+				continue
+			}
+			for _, prefix := range ntDosPrefixes {
+				if hasPrefixFold(v.Target, prefix) && isDriveRoot(v.Target[len(prefix):]) {
+					return t.toWin32(v.Target[len(prefix):]+`\`+p[2:], depth+1)
+				}
+			}
+			break
+		}
+	}
+	return cleanRooted(drive+`\`, p[2:]), nil
+}
+
+// deviceToWin32 网络重定向器设备转 UNC，其余设备按映射表中最长匹配的卷设备名换成盘符。
+func (t *Translator) deviceToWin32(p string) (string, error) {
+	if unc, ok := redirectorUNC(p); ok {
+		return cleanUNC(unc)
+	}
+	best, bestDrive := "", ""
+	for _, v := range t.volumes() {
+		target := strings.TrimRight(v.Target, `\`)
+		if !hasPrefixFold(target, `\Device\`) || len(target) <= len(best) {
+			continue
+		}
+		if len(p) == len(target) || len(p) > len(target) && p[len(target)] == '\\' {
+			if hasPrefixFold(p, target) {
+				best, bestDrive = target, strings.ToUpper(v.Drive)
+			}
+		}
+	}
+	if best == "" {
+		return "", fmt.Errorf("%w: %s", ErrUnmapped, p)
+	}
+	return cleanRooted(bestDrive+`\`, p[len(best):]), nil
+}
+
+// redirectorNames 网络重定向器设备，其后可能带有 ";LanmanRedirector"、";Z:0000..." 这类内部段。
+var redirectorNames = []string{`\Device\Mup\`, `\Device\LanmanRedirector\`, `\Device\WebDavRedirector\`}
+
+// redirectorUNC 从重定向器设备路径中取出 "server\share\..." 部分。
+func redirectorUNC(p string) (string, bool) {
+	for _, prefix := range redirectorNames {
+		if !hasPrefixFold(p, prefix) {
+			continue
+		}
+		rest := p[len(prefix):]
+		for strings.HasPrefix(rest, ";") {
+			i := strings.IndexByte(rest, '\\')
+			if i < 0 {
+				return "", false
+			}
+			rest = rest[i+1:]
+		}
+		return rest, true
+	}
+	return "", false
+}
+
+// ToNT 转为驱动可直接打开的 NT 路径：盘符路径为 \??\C:\x，UNC 与网络映射盘为 \??\UNC\server\share\x。
+// 映射表无法识别的 \Device\ 与 \??\Volume{GUID} 这类路径本身已是 NT 路径，原样返回（\\?\ 前缀换成 \??\）。
+func (t *Translator) ToNT(p string) (string, error) {
+	w, err := t.ToWin32(p)
+	if err != nil {
+		if !errors.Is(err, ErrUnmapped) {
+			return "", err
+		}
+		p = strings.ReplaceAll(strings.TrimSpace(p), "/", `\`)
+		switch Classify(p) {
+		case FormDevice, FormNTDrive:
+			return p, nil
+		case FormLongDrive:
+			return `\??\` + p[4:], nil
+		}
+		return "", err
+	}
+	if strings.HasPrefix(w, `\\`) {
+		return `\??\UNC\` + w[2:], nil
+	}
+	if unc, ok := t.mappedDriveUNC(w); ok {
+		return `\??\UNC\` + unc, nil
+	}
+	return `\??\` + w, nil
+}
+
+// ToDevice 转为 \Device\ 形式：卷上的路径使用映射表中的卷设备名，UNC 与网络映射盘使用 \Device\Mup。
+func (t *Translator) ToDevice(p string) (string, error) {
+	w, err := t.ToWin32(p)
+	if err != nil {
+		if Classify(p) == FormDevice && errors.Is(err, ErrUnmapped) {
+			return strings.TrimSpace(p), nil
+		}
+		return "", err
+	}
+	if strings.HasPrefix(w, `\\`) {
+		return `\Device\Mup\` + w[2:], nil
+	}
+	if unc, ok := t.mappedDriveUNC(w); ok {
+		return `\Device\Mup\` + unc, nil
+	}
+	drive := w[:2]
+	for _, v := range t.volumes() {
+		if strings.EqualFold(v.Drive, drive) && hasPrefixFold(v.Target, `\Device\`) {
+			return strings.TrimRight(v.Target, `\`) + w[2:], nil
+		}
+	}
+	return "", fmt.Errorf("%w: %s", ErrUnmapped, drive)
+}
+
+// ToLong 转为 \\?\ 长路径形式，可绕过 MAX_PATH 限制。
+func (t *Translator) ToLong(p string) (string, error) {
+	w, err := t.ToWin32(p)
+	if err != nil {
+		return "", err
+	}
+	if strings.HasPrefix(w, `\\`) {
+		return `\\?\UNC\` + w[2:], nil
+	}
+	return `\\?\` + w, nil
+}
+
+// mappedDriveUNC 盘符为网络映射盘时返回对应的 "server\share\..."。
+func (t *Translator) mappedDriveUNC(w string) (string, bool) {
+	for _, v := range t.volumes() {
+		if !strings.EqualFold(v.Drive, w[:2]) {
+			continue
+		}
+		unc, ok := redirectorUNC(v.Target)
+		if !ok || unc == "" {
+			return "", false
+		}
+		rest := strings.TrimPrefix(w[2:], `\`)
+		if rest == "" {
+			return strings.TrimRight(unc, `\`), true
+		}
+		return strings.TrimRight(unc, `\`) + `\` + rest, true
+	}
+	return "", false
+}
+
+// cleanUNC 规范化 "server\share\..."，server 与 share 都必须存在。
+func cleanUNC(rest string) (string, error) {
+	parts := strings.SplitN(strings.TrimLeft(rest, `\`), `\`, 3)
+	if len(parts) < 2 || parts[0] == "" || parts[1] == "" {
+		return "", fmt.Errorf("%w: UNC 路径缺少服务器或共享名: \\\\%s", ErrUnsupported, rest)
+	}
+	tail := ""
+	if len(parts) == 3 {
+		tail = parts[2]
+	}
+	return cleanRooted(`\\`+parts[0]+`\`+parts[1]+`\`, tail), nil
+}
+
+// cleanRooted 在根 root（以反斜杠结尾）下拼接 rest，折叠空段、"." 与 ".."（不越过根）。
+// 结果除盘符根目录 "C:\" 外不以反斜杠结尾。
+func cleanRooted(root string, rest string) string {
+	segs := make([]string, 0, 8)
+	for _, s := range strings.Split(rest, `\`) {
+		switch s {
+		case "", ".":
+		case "..":
+			if len(segs) > 0 {
+				segs = segs[:len(segs)-1]
+			}
+		default:
+			segs = append(segs, s)
+		}
+	}
+	out := root + strings.Join(segs, `\`)
+	if len(segs) == 0 && strings.HasPrefix(root, `\\`) {
+		out = strings.TrimRight(out, `\`)
+	}
+	return out
+}
@@ -0,0 +1,210 @@
+package winpath
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+// testVolumes 覆盖普通卷（含 Volume1/Volume10 前缀重叠）、subst 链、自引用 subst 与网络映射盘。
+var testVolumes = Static(
+	Volume{Drive: "C:", Target: `\Device\HarddiskVolume1`},
+	Volume{Drive: "D:", Target: `\Device\HarddiskVolume10`},
+	Volume{Drive: "E:", Target: `\Device\HarddiskVolume2\`},
+	Volume{Drive: "S:", Target: `\??\T:\inner`},
+	Volume{Drive: "T:", Target: `\??\C:\outer`},
+	Volume{Drive: "L:", Target: `\??\L:\loop`},
+	Volume{Drive: "Z:", Target: `\Device\LanmanRedirector\;Z:0000000000012345\server\share`},
+)
+
+func newTestTranslator() *Translator {
+	return New(testVolumes, `C:\Windows`)
+}
+
+func TestClassify(t *testing.T) {
+	cases := []struct {
+		in   string
+		want Form
+	}{
+		{``, FormUnknown},
+		{`x\y`, FormRelative},
+		{`\x`, FormRelative},
+		{`C:x`, FormRelative},
+		{`C:`, FormDrive},
+		{`c:\x`, FormDrive},
+		{`C:/x/y`, FormDrive},
+		{`\\server\share\x`, FormUNC},
+		{`\\?\C:\x`, FormLongDrive},
+		{`\\.\C:\x`, FormLongDrive},
+		{`\\?\Volume{0b1c}\x`, FormLongDrive},
+		{`\\?\UNC\server\share\x`, FormLongUNC},
+		{`\??\C:\x`, FormNTDrive},
+		{`\GLOBAL??\C:\x`, FormNTDrive},
+		{`\DosDevices\C:\x`, FormNTDrive},
+		{`\??\UNC\server\share\x`, FormNTUNC},
+		{`\Device\HarddiskVolume3\x`, FormDevice},
+		{`\SystemRoot\System32\x`, FormSystemRoot},
+		{`\SystemRoot`, FormSystemRoot},
+	}
+	for _, c := range cases {
+		if got := Classify(c.in); got != c.want {
+			t.Errorf("Classify(%q) = %v, want %v", c.in, got, c.want)
+		}
+	}
+}
+
+func TestToWin32(t *testing.T) {
+	tr := newTestTranslator()
+	cases := []struct {
+		in      string
+		want    string
+		wantErr error
+	}{
+		// 卷设备按完整路径段匹配，Volume1 不能吃掉 Volume10
+		{`\Device\HarddiskVolume1\x\y.dll`, `C:\x\y.dll`, nil},
+		{`\Device\HarddiskVolume10\x\y.dll`, `D:\x\y.dll`, nil},
+		{`\device\harddiskvolume10`, `D:\`, nil},
+		{`\Device\HarddiskVolume2\x`, `E:\x`, nil},
+		{`\Device\HarddiskVolume100\x`, "", ErrUnmapped},
+		{`\Device\HarddiskVolume1x\y`, "", ErrUnmapped},
+
+		// subst 链逐层展开；自引用的 subst 在 maxSubstDepth 层后停止
+		{`S:\f.txt`, `C:\outer\inner\f.txt`, nil},
+		{`T:\f.txt`, `C:\outer\f.txt`, nil},
+		{`L:\f`, `L:\` + strings.Repeat(`loop\`, maxSubstDepth) + `f`, nil},
+
+		// 长路径与 NT 前缀的各种写法
+		{`\\?\C:\x\y`, `C:\x\y`, nil},
+		{`\\?\UNC\server\share\x`, `\\server\share\x`, nil},
+		{`\??\UNC\server\share\x`, `\\server\share\x`, nil},
+		{`\??\C:\x`, `C:\x`, nil},
+		{`\GLOBAL??\C:\x`, `C:\x`, nil},
+		{`\DosDevices\C:\x`, `C:\x`, nil},
+		{`\??\S:\x`, `C:\outer\inner\x`, nil},
+		{`\\?\Volume{0b1c}\x`, "", ErrUnmapped},
+
+		// SystemRoot
+		{`\SystemRoot\System32\drivers\a.sys`, `C:\Windows\System32\drivers\a.sys`, nil},
+		{`\systemroot`, `C:\Windows`, nil},
+
+		// 网络重定向器
+		{`\Device\LanmanRedirector\;Z:0000000000012345\server\share\x`, `\\server\share\x`, nil},
+		{`\Device\Mup\server\share\x`, `\\server\share\x`, nil},
+		{`\Device\Mup\;LanmanRedirector\server\share\x`, `\\server\share\x`, nil},
+		{`\\server`, "", ErrUnsupported},
+
+		// "." 与 ".." 不越过根
+		{`C:\a\.\b\..\c`, `C:\a\c`, nil},
+		{`C:\..\..\x`, `C:\x`, nil},
+		{`\\server\share\..\..\x`, `\\server\share\x`, nil},
+		{`\Device\HarddiskVolume1\..\x`, `C:\x`, nil},
+		{`C:\\a\\\b\`, `C:\a\b`, nil},
+
+		// 相对与空路径
+		{`C:x`, "", ErrRelative},
+		{`x\y`, "", ErrRelative},
+		{`\x`, "", ErrRelative},
+		{`  `, "", ErrEmpty},
+	}
+	for _, c := range cases {
+		got, err := tr.ToWin32(c.in)
+		if c.wantErr != nil {
+			if !errors.Is(err, c.wantErr) {
+				t.Errorf("ToWin32(%q) error = %v, want %v", c.in, err, c.wantErr)
+			}
+			continue
+		}
+		if err != nil || got != c.want {
+			t.Errorf("ToWin32(%q) = %q, %v; want %q", c.in, got, err, c.want)
+		}
+	}
+}
+
+func TestToWin32WithoutSystemRoot(t *testing.T) {
+	tr := New(testVolumes, "")
+	if _, err := tr.ToWin32(`\SystemRoot\System32\x`); !errors.Is(err, ErrUnmapped) {
+		t.Fatalf("error = %v, want ErrUnmapped", err)
+	}
+}
+
+func TestToNT(t *testing.T) {
+	tr := newTestTranslator()
+	cases := []struct {
+		in      string
+		want    string
+		wantErr error
+	}{
+		{`C:\x`, `\??\C:\x`, nil},
+		{`\Device\HarddiskVolume10\x`, `\??\D:\x`, nil},
+		{`S:\x`, `\??\C:\outer\inner\x`, nil},
+		{`\\server\share\x`, `\??\UNC\server\share\x`, nil},
+		{`\\?\UNC\server\share\x`, `\??\UNC\server\share\x`, nil},
+		{`Z:\dir\f.txt`, `\??\UNC\server\share\dir\f.txt`, nil},
+		{`Z:\`, `\??\UNC\server\share`, nil},
+		{`\SystemRoot\System32\x`, `\??\C:\Windows\System32\x`, nil},
+
+		// 映射表中没有的 NT 路径原样交给驱动
+		{`\\?\Volume{0b1c}\x`, `\??\Volume{0b1c}\x`, nil},
+		{`\??\Volume{0b1c}\x`, `\??\Volume{0b1c}\x`, nil},
+		{`\Device\HarddiskVolume7\x`, `\Device\HarddiskVolume7\x`, nil},
+
+		{`C:x`, "", ErrRelative},
+	}
+	for _, c := range cases {
+		got, err := tr.ToNT(c.in)
+		if c.wantErr != nil {
+			if !errors.Is(err, c.wantErr) {
+				t.Errorf("ToNT(%q) error = %v, want %v", c.in, err, c.wantErr)
+			}
+			continue
+		}
+		if err != nil || got != c.want {
+			t.Errorf("ToNT(%q) = %q, %v; want %q", c.in, got, err, c.want)
+		}
+	}
+}
+
+func TestToDevice(t *testing.T) {
+	tr := newTestTranslator()
+	cases := []struct {
+		in      string
+		want    string
+		wantErr error
+	}{
+		{`C:\x`, `\Device\HarddiskVolume1\x`, nil},
+		{`D:\x`, `\Device\HarddiskVolume10\x`, nil},
+		{`E:\x`, `\Device\HarddiskVolume2\x`, nil},
+		{`S:\x`, `\Device\HarddiskVolume1\outer\inner\x`, nil},
+		{`Z:\dir\f.txt`, `\Device\Mup\server\share\dir\f.txt`, nil},
+		{`\\server\share\x`, `\Device\Mup\server\share\x`, nil},
+		{`\Device\HarddiskVolume7\x`, `\Device\HarddiskVolume7\x`, nil},
+		{`Q:\x`, "", ErrUnmapped},
+	}
+	for _, c := range cases {
+		got, err := tr.ToDevice(c.in)
+		if c.wantErr != nil {
+			if !errors.Is(err, c.wantErr) {
+				t.Errorf("ToDevice(%q) error = %v, want %v", c.in, err, c.wantErr)
+			}
+			continue
+		}
+		if err != nil || got != c.want {
+			t.Errorf("ToDevice(%q) = %q, %v; want %q", c.in, got, err, c.want)
+		}
+	}
+}
+
+func TestToLong(t *testing.T) {
+	tr := newTestTranslator()
+	cases := map[string]string{
+		`C:\x`:                       `\\?\C:\x`,
+		`\\server\share\x`:           `\\?\UNC\server\share\x`,
+		`\Device\HarddiskVolume1\x`:  `\\?\C:\x`,
+		`\Device\HarddiskVolume10\x`: `\\?\D:\x`,
+	}
+	for in, want := range cases {
+		if got, err := tr.ToLong(in); err != nil || got != want {
+			t.Errorf("ToLong(%q) = %q, %v; want %q", in, got, err, want)
+		}
+	}
+}